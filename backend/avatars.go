@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxAvatarSize maximum size of an uploaded avatar image, in bytes
+const MaxAvatarSize = 256 << 10
+
+var (
+	// ErrAvatarTooLarge uploaded image is larger than MaxAvatarSize
+	ErrAvatarTooLarge = errors.New("Avatar image must not be larger than 256KB")
+	// ErrUnsupportedAvatarType uploaded file isn't a png, jpeg or gif image
+	ErrUnsupportedAvatarType = errors.New("Avatar must be a png, jpeg or gif image")
+	// ErrAvatarNotFound no uploaded avatar with the given name
+	ErrAvatarNotFound = errors.New("Avatar not found")
+)
+
+var avatarExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+var avatarFileName = regexp.MustCompile(`^[0-9a-f]{24}\.(png|jpg|gif)$`)
+
+// AvatarStore stores uploaded avatar images on the local disk, one image per user
+type AvatarStore struct {
+	dir string
+}
+
+// NewAvatarStore creates a store keeping the images in dir, creating it if needed
+func NewAvatarStore(dir string) (*AvatarStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &AvatarStore{dir: dir}, nil
+}
+
+// Save stores the user's avatar, replacing any previous one, and returns its file name. The
+// image type is sniffed from its content rather than trusted from the client
+func (s *AvatarStore) Save(userID primitive.ObjectID, image []byte) (string, error) {
+	if len(image) > MaxAvatarSize {
+		return "", ErrAvatarTooLarge
+	}
+	extension, ok := avatarExtensions[http.DetectContentType(image)]
+	if !ok {
+		return "", ErrUnsupportedAvatarType
+	}
+	tmp, err := ioutil.TempFile(s.dir, "upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(image); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	fileName := userID.Hex() + extension
+	for _, other := range avatarExtensions {
+		if other != extension {
+			os.Remove(filepath.Join(s.dir, userID.Hex()+other))
+		}
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, fileName)); err != nil {
+		return "", err
+	}
+	return fileName, nil
+}
+
+// Path returns the path of a stored avatar, names that weren't produced by Save are rejected
+func (s *AvatarStore) Path(fileName string) (string, error) {
+	if !avatarFileName.MatchString(fileName) {
+		return "", ErrAvatarNotFound
+	}
+	path := filepath.Join(s.dir, fileName)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrAvatarNotFound
+	}
+	return path, nil
+}
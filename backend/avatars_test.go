@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// pngHeader is enough for content sniffing to detect a png image
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+func Test_AvatarStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avatars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, _ := NewAvatarStore(dir)
+	userID := primitive.NewObjectID()
+
+	fileName, err := store.Save(userID, pngHeader)
+	if err != nil || fileName != userID.Hex()+".png" {
+		t.Fatalf("Unexpected stored avatar %v (%v)", fileName, err)
+	}
+	if path, err := store.Path(fileName); err != nil || path != filepath.Join(dir, fileName) {
+		t.Errorf("Stored avatar should be found, %v", err)
+	}
+	if _, err := store.Save(userID, []byte("<html></html>")); err != ErrUnsupportedAvatarType {
+		t.Errorf("Expected unsupported type error, got %v", err)
+	}
+	if _, err := store.Save(userID, make([]byte, MaxAvatarSize+1)); err != ErrAvatarTooLarge {
+		t.Errorf("Expected too large error, got %v", err)
+	}
+	if _, err := store.Path("../" + fileName); err != ErrAvatarNotFound {
+		t.Errorf("Paths outside the store should be rejected")
+	}
+}
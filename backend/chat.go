@@ -0,0 +1,187 @@
+package backend
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// chatRate messages a user may send per second over time
+	chatRate = rate.Limit(0.5)
+	// chatBurst messages a user may send in a quick burst
+	chatBurst = 5
+)
+
+var (
+	// ErrChatEmpty chat message has no text
+	ErrChatEmpty = errors.New("Chat message is empty")
+
+	// ErrChatTooLong chat message is longer than allowed
+	ErrChatTooLong = errors.New("Chat message is too long")
+
+	// ErrChatRateLimited user sends chat messages too quickly
+	ErrChatRateLimited = errors.New("Sending chat messages too quickly")
+
+	// ErrChatMuted the owner muted the user
+	ErrChatMuted = errors.New("User is muted in this game")
+
+	// ErrChatRejected the profanity filter rejected the message
+	ErrChatRejected = errors.New("Chat message was rejected")
+
+	// ErrNotInAudience user is neither playing nor spectating the game
+	ErrNotInAudience = errors.New("User isn't playing or spectating the game")
+)
+
+// ProfanityFilter moderates chat messages before they are sent
+type ProfanityFilter interface {
+	// Clean returns the text to send, ok is false if the message must be rejected altogether
+	Clean(text string) (cleaned string, ok bool)
+}
+
+// WordListFilter masks every listed word, case insensitive
+type WordListFilter struct {
+	pattern *regexp.Regexp
+}
+
+// NewWordListFilter returns a filter masking the given words, an empty list lets everything through
+func NewWordListFilter(words []string) *WordListFilter {
+	if len(words) == 0 {
+		return &WordListFilter{}
+	}
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	return &WordListFilter{regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+// Clean implements ProfanityFilter
+func (f *WordListFilter) Clean(text string) (string, bool) {
+	if f.pattern == nil {
+		return text, true
+	}
+	return f.pattern.ReplaceAllStringFunc(text, func(word string) string {
+		return strings.Repeat("*", len(word))
+	}), true
+}
+
+// chatModerator rate limits chat per user and filters message content
+type chatModerator struct {
+	filter   ProfanityFilter
+	limiters map[primitive.ObjectID]*rate.Limiter
+	mtx      sync.Mutex
+}
+
+func newChatModerator() *chatModerator {
+	return &chatModerator{
+		filter:   NewWordListFilter(nil),
+		limiters: make(map[primitive.ObjectID]*rate.Limiter),
+	}
+}
+
+func (m *chatModerator) allow(userID primitive.ObjectID) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	limiter := m.limiters[userID]
+	if limiter == nil {
+		limiter = rate.NewLimiter(chatRate, chatBurst)
+		m.limiters[userID] = limiter
+	}
+	return limiter.Allow()
+}
+
+// SetProfanityFilter replaces the filter applied to chat messages
+func (g *GameController) SetProfanityFilter(filter ProfanityFilter) {
+	g.chat.mtx.Lock()
+	defer g.chat.mtx.Unlock()
+	g.chat.filter = filter
+}
+
+// SendChatMessage sends a chat message to the game's players and spectators and stores it with the game
+func (g *GameController) SendChatMessage(user *models.User, strGameID string, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ErrChatEmpty
+	}
+	if len(text) > websocket.MaxChatTextLength {
+		return ErrChatTooLong
+	}
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return err
+	}
+	if !isInAudience(game, user.ID) {
+		return ErrNotInAudience
+	}
+	if game.IsMuted(user.ID) {
+		return ErrChatMuted
+	}
+	if !g.chat.allow(user.ID) {
+		return ErrChatRateLimited
+	}
+	g.chat.mtx.Lock()
+	filter := g.chat.filter
+	g.chat.mtx.Unlock()
+	text, ok := filter.Clean(text)
+	if !ok {
+		return ErrChatRejected
+	}
+	message := &models.ChatMessage{
+		ID:       primitive.NewObjectID(),
+		UserID:   user.ID,
+		Username: user.Username,
+		Text:     text,
+		SentAt:   time.Now(),
+	}
+	if err := g.db.GamesDAO.AppendChatMessage(game, message); err != nil {
+		return err
+	}
+	g.sender.BroadcastMessageToUsers(game.Audience(), websocket.NewWSMessageChat(game, message))
+	return nil
+}
+
+// FetchChatHistory returns the stored chat of a game, only its players and spectators may read it
+func (g *GameController) FetchChatHistory(user *models.User, strGameID string) ([]models.ChatMessage, error) {
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return nil, err
+	}
+	if !isInAudience(game, user.ID) {
+		return nil, ErrNotInAudience
+	}
+	return append([]models.ChatMessage(nil), game.Chat...), nil
+}
+
+// MuteUser mutes or unmutes a user in the game's chat, only the game owner may mute
+func (g *GameController) MuteUser(owner *models.User, strGameID string, strUserID string, muted bool) error {
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return err
+	}
+	if game.Owner != owner.ID {
+		return ErrNotGameOwner
+	}
+	userID, err := primitive.ObjectIDFromHex(strUserID)
+	if err != nil || !isInAudience(game, userID) {
+		return ErrNotInAudience
+	}
+	return g.db.GamesDAO.SetUserMuted(game, userID, muted)
+}
+
+func isInAudience(game *models.KabooGame, userID primitive.ObjectID) bool {
+	for _, id := range game.Audience() {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_WordListFilter(t *testing.T) {
+	filter := NewWordListFilter([]string{"darn", "heck"})
+	cleaned, ok := filter.Clean("Darn it, what the heck! darnation")
+	if !ok || cleaned != "**** it, what the ****! darnation" {
+		t.Errorf("Unexpected filtered message %q", cleaned)
+	}
+	if cleaned, _ := NewWordListFilter(nil).Clean("darn"); cleaned != "darn" {
+		t.Errorf("Empty filter should let everything through")
+	}
+}
+
+func Test_ChatMessageValidation(t *testing.T) {
+	controller := &GameController{chat: newChatModerator()}
+	if err := controller.SendChatMessage(nil, "", "   "); err != ErrChatEmpty {
+		t.Errorf("Should have rejected an empty message, got %v", err)
+	}
+	if err := controller.SendChatMessage(nil, "", strings.Repeat("a", 1000)); err != ErrChatTooLong {
+		t.Errorf("Should have rejected a long message, got %v", err)
+	}
+}
+
+func Test_ChatRateLimit(t *testing.T) {
+	moderator := newChatModerator()
+	user := primitive.NewObjectID()
+	for i := 0; i < chatBurst; i++ {
+		if !moderator.allow(user) {
+			t.Fatalf("Burst of %d messages should be allowed", chatBurst)
+		}
+	}
+	if moderator.allow(user) {
+		t.Errorf("Should have rate limited the user")
+	}
+}
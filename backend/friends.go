@@ -0,0 +1,270 @@
+package backend
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GameInviteTTL how long a game invite can be accepted for
+const GameInviteTTL = 5 * time.Minute
+
+var (
+	// ErrUserNotFound no user with the given id
+	ErrUserNotFound = errors.New("User not found")
+	// ErrInviteNotFound the invite doesn't exist, expired or was sent to another user
+	ErrInviteNotFound = errors.New("Invite not found or expired")
+	// ErrNoGameToInviteTo the user isn't in a game waiting for players
+	ErrNoGameToInviteTo = errors.New("User isn't in a game waiting for players")
+)
+
+// PresenceTracker tells whether users are connected
+type PresenceTracker interface {
+	IsOnline(userID primitive.ObjectID) bool
+}
+
+// Friend a user related to another user along with his presence
+type Friend struct {
+	User   *models.User
+	Status models.FriendshipStatus
+	// Incoming the relation was requested by the other user
+	Incoming bool
+	Online   bool
+}
+
+type gameInvite struct {
+	gameID    primitive.ObjectID
+	from      primitive.ObjectID
+	to        primitive.ObjectID
+	expiresAt time.Time
+}
+
+// SetPresenceTracker sets the source of the users online presence
+func (g *GameController) SetPresenceTracker(presence PresenceTracker) {
+	g.gameMtx.Lock()
+	defer g.gameMtx.Unlock()
+	g.presence = presence
+}
+
+// SendFriendRequest sends a friend request, accepting it right away if the other user already sent one
+func (g *GameController) SendFriendRequest(user *models.User, strUserID string) (models.FriendshipStatus, error) {
+	other, err := g.fetchUser(strUserID)
+	if err != nil {
+		return "", err
+	}
+	status, err := g.db.FriendsDAO.RequestFriendship(user.ID, other.ID)
+	if err != nil {
+		return "", err
+	}
+	if status == models.FriendshipAccepted {
+		g.sender.BroadcastMessageToUsers([]primitive.ObjectID{other.ID}, websocket.NewWSMessageFriendAccepted(user))
+	} else {
+		g.sender.BroadcastMessageToUsers([]primitive.ObjectID{other.ID}, websocket.NewWSMessageFriendRequest(user))
+	}
+	return status, nil
+}
+
+// RespondToFriendRequest accepts or declines a friend request the user received
+func (g *GameController) RespondToFriendRequest(user *models.User, strUserID string, accept bool) error {
+	other, err := g.fetchUser(strUserID)
+	if err != nil {
+		return err
+	}
+	if err := g.db.FriendsDAO.RespondToFriendRequest(user.ID, other.ID, accept); err != nil {
+		return err
+	}
+	if accept {
+		g.sender.BroadcastMessageToUsers([]primitive.ObjectID{other.ID}, websocket.NewWSMessageFriendAccepted(user))
+	}
+	return nil
+}
+
+// RemoveFriend ends a friendship or cancels a pending request
+func (g *GameController) RemoveFriend(user *models.User, strUserID string) error {
+	otherID, err := primitive.ObjectIDFromHex(strUserID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	return g.db.FriendsDAO.RemoveFriend(user.ID, otherID)
+}
+
+// BlockUser blocks another user from befriending or inviting the user
+func (g *GameController) BlockUser(user *models.User, strUserID string) error {
+	other, err := g.fetchUser(strUserID)
+	if err != nil {
+		return err
+	}
+	return g.db.FriendsDAO.BlockUser(user.ID, other.ID)
+}
+
+// UnblockUser removes a block the user placed
+func (g *GameController) UnblockUser(user *models.User, strUserID string) error {
+	otherID, err := primitive.ObjectIDFromHex(strUserID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	return g.db.FriendsDAO.UnblockUser(user.ID, otherID)
+}
+
+// FetchFriends returns the user's friends, pending requests and the users he blocked
+func (g *GameController) FetchFriends(user *models.User) ([]Friend, error) {
+	friendships, err := g.db.FriendsDAO.FetchFriendships(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	friends := []Friend{}
+	for _, friendship := range friendships {
+		if friendship.Status == models.FriendshipBlocked && friendship.BlockedBy != user.ID {
+			// Users aren't told who blocked them
+			continue
+		}
+		other, err := g.db.UserDAO.FetchUserByID(friendship.Other(user.ID))
+		if err != nil {
+			log.Debugf("Skipping missing friend %v, %v\n", friendship.Other(user.ID).Hex(), err)
+			continue
+		}
+		friends = append(friends, Friend{
+			User:     other,
+			Status:   friendship.Status,
+			Incoming: friendship.RequestedBy != user.ID,
+			Online:   friendship.Status == models.FriendshipAccepted && g.isOnline(other.ID),
+		})
+	}
+	return friends, nil
+}
+
+// HandlePresenceChange tells the user's friends he connected or disconnected
+func (g *GameController) HandlePresenceChange(userID primitive.ObjectID, online bool) {
+	friends, err := g.db.FriendsDAO.FetchFriends(userID)
+	if err != nil {
+		log.Errorf("Failed fetching %v friends, %v\n", userID.Hex(), err)
+		return
+	}
+	if len(friends) > 0 {
+		g.sender.BroadcastMessageToUsers(friends, websocket.NewWSMessageFriendPresence(userID.Hex(), online))
+	}
+}
+
+// InviteFriend invites a friend to the game the user owns and is waiting in, returning the invite id
+func (g *GameController) InviteFriend(user *models.User, strUserID string) (string, error) {
+	friendID, err := primitive.ObjectIDFromHex(strUserID)
+	if err != nil {
+		return "", ErrUserNotFound
+	}
+	friends, err := g.db.FriendsDAO.AreFriends(user.ID, friendID)
+	if err != nil {
+		return "", err
+	}
+	if !friends {
+		return "", models.ErrNotFriends
+	}
+	stored, err := g.db.GamesDAO.FetchActiveGameOfPlayer(user.ID)
+	if err != nil {
+		return "", err
+	}
+	if stored == nil {
+		return "", ErrNoGameToInviteTo
+	}
+	game, err := g.activeGame(stored.ID.Hex())
+	if err != nil || game.State != models.GameStateWaitingForPlayers {
+		return "", ErrNoGameToInviteTo
+	}
+	if game.Owner != user.ID {
+		return "", ErrNotGameOwner
+	}
+	inviteID, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	invite := &gameInvite{gameID: game.ID, from: user.ID, to: friendID, expiresAt: time.Now().Add(GameInviteTTL)}
+	g.gameMtx.Lock()
+	for id, other := range g.invites {
+		if time.Now().After(other.expiresAt) {
+			delete(g.invites, id)
+		}
+	}
+	g.invites[inviteID] = invite
+	g.gameMtx.Unlock()
+	g.sender.BroadcastMessageToUsers([]primitive.ObjectID{friendID},
+		websocket.NewWSMessageGameInvite(inviteID, user, game, invite.expiresAt))
+	return inviteID, nil
+}
+
+// AcceptGameInvite joins the game the user was invited to, no password is needed. Returns the game id
+func (g *GameController) AcceptGameInvite(user *models.User, inviteID string) (string, error) {
+	invite, err := g.takeInvite(user, inviteID)
+	if err != nil {
+		return "", err
+	}
+	if g.db.GamesDAO.IsPlayerInActiveGame(user.ID) {
+		return "", ErrAlreadyInGame
+	}
+	game, err := g.activeGame(invite.gameID.Hex())
+	if err != nil {
+		return "", err
+	}
+	if game.State != models.GameStateWaitingForPlayers {
+		return "", ErrJoinGameAlreadyStarted
+	}
+	if _, err := g.joinGame(user, game, ""); err != nil {
+		return "", err
+	}
+	return game.ID.Hex(), nil
+}
+
+// DeclineGameInvite discards an invite the user received
+func (g *GameController) DeclineGameInvite(user *models.User, inviteID string) error {
+	_, err := g.takeInvite(user, inviteID)
+	return err
+}
+
+// takeInvite removes and returns a valid invite sent to the user
+func (g *GameController) takeInvite(user *models.User, inviteID string) (*gameInvite, error) {
+	g.gameMtx.Lock()
+	defer g.gameMtx.Unlock()
+	invite := g.invites[inviteID]
+	if invite == nil || invite.to != user.ID {
+		return nil, ErrInviteNotFound
+	}
+	delete(g.invites, inviteID)
+	if time.Now().After(invite.expiresAt) {
+		return nil, ErrInviteNotFound
+	}
+	return invite, nil
+}
+
+// dropInvites forgets the pending invites to a game, only those sent by or to userID unless it's nil.
+// Must be called holding gameMtx
+func (g *GameController) dropInvites(gameID primitive.ObjectID, userID primitive.ObjectID) {
+	for id, invite := range g.invites {
+		if invite.gameID != gameID {
+			continue
+		}
+		if userID.IsZero() || invite.from == userID || invite.to == userID {
+			delete(g.invites, id)
+		}
+	}
+}
+
+func (g *GameController) isOnline(userID primitive.ObjectID) bool {
+	g.gameMtx.Lock()
+	presence := g.presence
+	g.gameMtx.Unlock()
+	return presence != nil && presence.IsOnline(userID)
+}
+
+func (g *GameController) fetchUser(strUserID string) (*models.User, error) {
+	userID, err := primitive.ObjectIDFromHex(strUserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := g.db.UserDAO.FetchUserByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
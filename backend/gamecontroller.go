@@ -2,8 +2,11 @@ package backend
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 
+	"github.com/ngutman/kaboo-server-go/bots"
+	"github.com/ngutman/kaboo-server-go/engine"
 	"github.com/ngutman/kaboo-server-go/models"
 	"github.com/ngutman/kaboo-server-go/transport/websocket"
 	log "github.com/sirupsen/logrus"
@@ -25,8 +28,55 @@ var (
 
 	// ErrGameDoesntExist game doesn't exist
 	ErrGameDoesntExist = errors.New("Game doesn't exist")
+
+	// ErrNotGameOwner only the game owner is allowed to perform the action
+	ErrNotGameOwner = errors.New("User isn't the game owner")
+
+	// ErrGameNotRunning game isn't being played
+	ErrGameNotRunning = errors.New("Game isn't running")
+
+	// ErrNotInGame user isn't playing in the game
+	ErrNotInGame = errors.New("User isn't playing in game")
+
+	// ErrGameNotEnded game is still being played
+	ErrGameNotEnded = errors.New("Game hasn't ended")
+
+	// ErrInvalidPerspective replay perspective isn't a seat in the game
+	ErrInvalidPerspective = errors.New("Invalid replay perspective")
+
+	// ErrAlreadyPlaying user is seated in the game
+	ErrAlreadyPlaying = errors.New("User is playing in this game")
+
+	// ErrUnknownBotDifficulty no such bot difficulty
+	ErrUnknownBotDifficulty = errors.New("Unknown bot difficulty")
+
+	// ErrOwnerCannotLeave the owner can't leave a game waiting for players, he should transfer ownership instead
+	ErrOwnerCannotLeave = errors.New("Owner can't leave the game")
 )
 
+// ReplayStep the state of an ended game after a given step, as known from a perspective
+type ReplayStep struct {
+	Step   int
+	Steps  int
+	Action *engine.Action
+	Events []engine.Event
+	State  engine.Snapshot
+}
+
+// GameOptions optional settings of a new game
+type GameOptions struct {
+	// Entropy contributed by the owner, mixed into the shuffle seed
+	Entropy string
+	// Spectators who may watch the game and how delayed their stream is
+	Spectators models.SpectatorSettings
+	// Unrated excludes the game from rated play
+	Unrated bool
+	// Visibility who can find the game, public when empty
+	Visibility models.GameVisibility
+	// Rules the house rules to play by, the default rules when nil
+	Rules *engine.Ruleset
+}
+
 // MessageSender websocket message sender interface
 type MessageSender interface {
 	BroadcastMessageToUsers(users []primitive.ObjectID, message interface{})
@@ -36,8 +86,15 @@ type MessageSender interface {
 type GameController struct {
 	userToActiveGames map[primitive.ObjectID]*models.KabooGame
 	activeGames       map[primitive.ObjectID]*models.KabooGame
+	sessions          map[primitive.ObjectID]*gameSession
 	db                *models.Db
 	sender            MessageSender
+	chat              *chatModerator
+	passwords         *passwordGuard
+	ratingPolicy      RatingPolicy
+	presence          PresenceTracker
+	invites           map[string]*gameInvite
+	leaderboards      *leaderboardRefresher
 	gameMtx           *sync.Mutex
 }
 
@@ -46,10 +103,15 @@ func NewGameController(db *models.Db, sender MessageSender) *GameController {
 	controller := GameController{
 		userToActiveGames: make(map[primitive.ObjectID]*models.KabooGame),
 		activeGames:       make(map[primitive.ObjectID]*models.KabooGame),
+		sessions:          make(map[primitive.ObjectID]*gameSession),
+		invites:           make(map[string]*gameInvite),
 		db:                db,
 		sender:            sender,
+		chat:              newChatModerator(),
+		passwords:         newPasswordGuard(),
 		gameMtx:           &sync.Mutex{},
 	}
+	controller.leaderboards = newLeaderboardRefresher(db.LeaderboardDAO.Refresh, leaderboardRefreshInterval)
 	controller.loadGames()
 	return &controller
 }
@@ -57,23 +119,60 @@ func NewGameController(db *models.Db, sender MessageSender) *GameController {
 // NewGame create a new game returning the created game id on success
 // A player can only create a game if he's not participating in any running games
 func (g *GameController) NewGame(user *models.User, name string,
-	maxPlayers int, password string) (string, error) {
+	maxPlayers int, password string, options GameOptions) (string, error) {
+	name, err := models.ValidateNewGame(name, maxPlayers, password)
+	if err != nil {
+		return "", err
+	}
+	if len(options.Entropy) > models.MaxEntropyLength {
+		return "", models.ErrEntropyTooLong
+	}
+	if err := options.Spectators.Validate(); err != nil {
+		return "", err
+	}
+	if options.Visibility != "" {
+		if err := options.Visibility.Validate(); err != nil {
+			return "", err
+		}
+	}
+	if options.Rules != nil {
+		if err := options.Rules.Validate(); err != nil {
+			return "", err
+		}
+	}
 	if g.db.GamesDAO.IsPlayerInActiveGame(user.ID) {
 		log.Debugf("User %v (%v) already participating in a game\n", user.Username, user.ID.Hex())
 		return "", ErrAlreadyInGame
 	}
-	game, err := g.db.GamesDAO.CreateGame(user, name, maxPlayers, password)
+	game, err := models.NewKabooGame(user, name, maxPlayers, password)
 	if err != nil {
 		return "", ErrCreateGame
 	}
+	if options.Entropy != "" {
+		game.Entropy = append(game.Entropy, models.PlayerEntropy{UserID: user.ID, Value: options.Entropy})
+	}
+	game.Unrated = options.Unrated
+	if options.Visibility != "" {
+		game.Visibility = options.Visibility
+	}
+	if options.Rules != nil {
+		game.Rules = *options.Rules
+	}
+	game.SpectatorSettings = options.Spectators
+	if err := g.db.GamesDAO.CreateGame(game); err != nil {
+		return "", ErrCreateGame
+	}
 
 	g.registerActiveGame(game)
 
 	return game.ID.Hex(), nil
 }
 
-// JoinGameByGameID the user asks to join a specific game
-func (g *GameController) JoinGameByGameID(user *models.User, strGameID string, password string) (bool, error) {
+// JoinGameByGameID the user asks to join a specific game, entropy is optional and is mixed into the shuffle seed
+func (g *GameController) JoinGameByGameID(user *models.User, strGameID string, password string, entropy string) (bool, error) {
+	if len(entropy) > models.MaxEntropyLength {
+		return false, models.ErrEntropyTooLong
+	}
 	if g.db.GamesDAO.IsPlayerInActiveGame(user.ID) {
 		log.Debugf("User %v (%v) already participating in a game\n", user.Username, user.ID.Hex())
 		return false, ErrAlreadyInGame
@@ -86,17 +185,381 @@ func (g *GameController) JoinGameByGameID(user *models.User, strGameID string, p
 	if game.State != models.GameStateWaitingForPlayers {
 		return false, ErrJoinGameAlreadyStarted
 	}
+	if err := g.passwords.check(game.ID); err != nil {
+		return false, err
+	}
 	if password != game.Password {
+		g.passwords.fail(game.ID)
 		return false, ErrWrongGamePassword
 	}
+	g.passwords.forget(game.ID)
+	if game.GameVisibility() == models.GameVisibilityFriendsOnly {
+		friends, err := g.db.FriendsDAO.AreFriends(game.Owner, user.ID)
+		if err != nil {
+			return false, err
+		}
+		if !friends {
+			return false, ErrFriendsOnlyGame
+		}
+	}
+	return g.joinGame(user, game, entropy)
+}
+
+// joinGame seats the user in a game waiting for players and tells everyone watching the lobby
+func (g *GameController) joinGame(user *models.User, game *models.KabooGame, entropy string) (bool, error) {
+	if game.IsBanned(user.ID) {
+		return false, models.ErrBannedFromGame
+	}
 	success, err := g.db.GamesDAO.TryToAddPlayerToGame(game, user)
 	if err != nil {
 		return false, err
 	}
-	g.sender.BroadcastMessageToUsers(game.Players, websocket.NewWSMessageUserJoinedGame(game, user))
+	if entropy != "" {
+		if err := g.db.GamesDAO.AddPlayerEntropy(game, user, entropy); err != nil {
+			return false, err
+		}
+	}
+	g.sender.BroadcastMessageToUsers(game.Audience(), websocket.NewWSMessageUserJoinedGame(game, user))
 	return success, nil
 }
 
+// SpectateGame subscribes the user to the game's public event stream without seating him
+func (g *GameController) SpectateGame(user *models.User, strGameID string) error {
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return err
+	}
+	for _, seat := range game.Seats() {
+		if seat == user.ID {
+			return ErrAlreadyPlaying
+		}
+	}
+	if game.IsBanned(user.ID) {
+		return models.ErrBannedFromGame
+	}
+	// The spectator feed reads the spectators under the same lock
+	g.gameMtx.Lock()
+	defer g.gameMtx.Unlock()
+	return g.db.GamesDAO.TryToAddSpectatorToGame(game, user)
+}
+
+// StopSpectating unsubscribes the user from the game's event stream
+func (g *GameController) StopSpectating(user *models.User, strGameID string) error {
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return err
+	}
+	g.gameMtx.Lock()
+	defer g.gameMtx.Unlock()
+	return g.db.GamesDAO.RemoveSpectatorFromGame(game, user)
+}
+
+// AddBot seats a bot with the given difficulty in a game waiting for players, returning the bot id
+// Only the game owner may add bots
+func (g *GameController) AddBot(user *models.User, strGameID string, difficulty string) (string, error) {
+	if !bots.IsValidDifficulty(bots.Difficulty(difficulty)) {
+		return "", ErrUnknownBotDifficulty
+	}
+	game, err := g.ownedWaitingGame(user, strGameID)
+	if err != nil {
+		return "", err
+	}
+	bot := models.BotPlayer{
+		ID:         primitive.NewObjectID(),
+		Name:       fmt.Sprintf("Bot %d (%s)", len(game.Bots)+1, difficulty),
+		Difficulty: difficulty,
+	}
+	if _, err := g.db.GamesDAO.TryToAddBotToGame(game, bot); err != nil {
+		return "", err
+	}
+	return bot.ID.Hex(), nil
+}
+
+// StartGame deals the first round of a game waiting for players, only the game owner may start it
+func (g *GameController) StartGame(user *models.User, strGameID string) error {
+	game, err := g.ownedWaitingGame(user, strGameID)
+	if err != nil {
+		return err
+	}
+	session, events, err := newGameSession(game, g.db.GameEventsDAO)
+	if err != nil {
+		return err
+	}
+	if err := g.db.GamesDAO.UpdateGameState(game, models.GameStateOngoing); err != nil {
+		return err
+	}
+	g.gameMtx.Lock()
+	g.dropInvites(game.ID, primitive.NilObjectID)
+	g.gameMtx.Unlock()
+	g.registerSession(session)
+	g.handleSessionEvents(session, events)
+	return nil
+}
+
+// ApplyAction plays an action on behalf of the user in a running game
+func (g *GameController) ApplyAction(user *models.User, strGameID string, action engine.Action) error {
+	gameID, _ := primitive.ObjectIDFromHex(strGameID)
+	g.gameMtx.Lock()
+	session := g.sessions[gameID]
+	g.gameMtx.Unlock()
+	if session == nil {
+		return ErrGameNotRunning
+	}
+	seat, ok := session.seatOf(user.ID)
+	if !ok {
+		return ErrNotInGame
+	}
+	action.Seat = seat
+	events, err := session.apply(action)
+	if len(events) > 0 {
+		g.handleSessionEvents(session, events)
+	}
+	return err
+}
+
+// LeaveGame unseats the user from a game waiting for players. Leaving a running game abandons it,
+// the game ends for everyone without results and isn't rated
+func (g *GameController) LeaveGame(user *models.User, strGameID string) error {
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return err
+	}
+	if !game.IsPlayer(user.ID) {
+		return models.ErrPlayerNotInGame
+	}
+	if game.State == models.GameStateWaitingForPlayers {
+		if game.Owner == user.ID {
+			return ErrOwnerCannotLeave
+		}
+		if err := g.db.GamesDAO.RemovePlayerFromGame(game, user.ID, false); err != nil {
+			return err
+		}
+		g.gameMtx.Lock()
+		g.dropInvites(game.ID, user.ID)
+		g.gameMtx.Unlock()
+		g.sender.BroadcastMessageToUsers(append(game.Audience(), user.ID),
+			websocket.NewWSMessageLobbyUpdate(game, websocket.LobbyUpdatePlayerLeft, user.ID.Hex()))
+		return nil
+	}
+
+	g.gameMtx.Lock()
+	session := g.sessions[game.ID]
+	g.gameMtx.Unlock()
+	if session == nil {
+		return ErrGameNotRunning
+	}
+	// No action is applied once the game is abandoned
+	session.mtx.Lock()
+	if session.abandoned {
+		session.mtx.Unlock()
+		return ErrGameNotRunning
+	}
+	if err := g.db.GamesDAO.AbandonGame(game, user.ID); err != nil {
+		session.mtx.Unlock()
+		return err
+	}
+	session.abandoned = true
+	session.mtx.Unlock()
+
+	g.sender.BroadcastMessageToUsers(game.Players, websocket.NewWSMessageLobbyUpdate(game, websocket.LobbyUpdatePlayerLeft, user.ID.Hex()))
+	g.sender.BroadcastMessageToUsers(game.Players, websocket.NewWSMessageGameSeedRevealed(game))
+	session.feed.publish(websocket.NewWSMessageGameSeedRevealed(game))
+	g.unregisterGame(game)
+	return nil
+}
+
+// SyncGame sends the user a snapshot of a running game he plays, clients request it when they
+// miss a delta version
+func (g *GameController) SyncGame(userID primitive.ObjectID, strGameID string) error {
+	gameID, _ := primitive.ObjectIDFromHex(strGameID)
+	g.gameMtx.Lock()
+	session := g.sessions[gameID]
+	g.gameMtx.Unlock()
+	if session == nil {
+		return ErrGameNotRunning
+	}
+	seat, ok := session.seatOf(userID)
+	if !ok || seat >= len(session.game.Players) {
+		return ErrNotInGame
+	}
+	// Sent under the updates lock so the snapshot is ordered between the deltas it's versioned with
+	session.updatesMtx.Lock()
+	defer session.updatesMtx.Unlock()
+	session.mtx.Lock()
+	view := session.engine.View(seat)
+	session.views[seat] = &view
+	snapshot := websocket.NewWSMessageGameSnapshot(session.game, session.version, session.engine.Snapshot(seat))
+	session.mtx.Unlock()
+	g.sender.BroadcastMessageToUsers([]primitive.ObjectID{userID}, snapshot)
+	return nil
+}
+
+// FetchGame returns a game by its id, active or not
+func (g *GameController) FetchGame(strGameID string) (*models.KabooGame, error) {
+	gameID, _ := primitive.ObjectIDFromHex(strGameID)
+	g.gameMtx.Lock()
+	game := g.activeGames[gameID]
+	g.gameMtx.Unlock()
+	if game != nil {
+		return game, nil
+	}
+	game, err := g.db.GamesDAO.FetchGameByID(gameID)
+	if err != nil {
+		return nil, ErrGameDoesntExist
+	}
+	return game, nil
+}
+
+// FetchVisibleGame returns a game by its id, active or not, if the user may look at it
+func (g *GameController) FetchVisibleGame(user *models.User, strGameID string) (*models.KabooGame, error) {
+	game, err := g.FetchGame(strGameID)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.checkVisibility(user, game); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// checkVisibility returns an error if the user may not look at the game. The players, spectators and owner
+// may always look at it, friends only games are visible to the owner's friends and other games to everyone
+func (g *GameController) checkVisibility(user *models.User, game *models.KabooGame) error {
+	if game.Owner == user.ID || game.IsPlayer(user.ID) || game.IsSpectator(user.ID) {
+		return nil
+	}
+	if game.GameVisibility() != models.GameVisibilityFriendsOnly {
+		return nil
+	}
+	friends, err := g.db.FriendsDAO.AreFriends(game.Owner, user.ID)
+	if err != nil {
+		return err
+	}
+	if !friends {
+		return ErrFriendsOnlyGame
+	}
+	return nil
+}
+
+// FetchReplay returns an ended game the user may look at along with its event log. Replays of
+// private games, protected by a password, are only available to the users who took part in them
+func (g *GameController) FetchReplay(user *models.User, strGameID string) (*models.KabooGame, []*models.GameLogEntry, error) {
+	gameID, _ := primitive.ObjectIDFromHex(strGameID)
+	game, err := g.db.GamesDAO.FetchGameByID(gameID)
+	if err != nil {
+		return nil, nil, ErrGameDoesntExist
+	}
+	if err := g.checkVisibility(user, game); err != nil {
+		return nil, nil, err
+	}
+	if game.Password != "" && game.Owner != user.ID && !game.IsPlayer(user.ID) && !game.IsSpectator(user.ID) {
+		return nil, nil, ErrNotInGame
+	}
+	if game.State != models.GameStateEnded {
+		return nil, nil, ErrGameNotEnded
+	}
+	entries, err := g.db.GameEventsDAO.FetchGameLog(gameID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return game, entries, nil
+}
+
+// FetchReplayStep reconstructs an ended game the user may look at after the given step from the game's seed and actions.
+// Perspective is a seat, whose knowledge limits what is revealed, or engine.Omniscient
+func (g *GameController) FetchReplayStep(user *models.User, strGameID string, step int, perspective int) (*ReplayStep, error) {
+	game, entries, err := g.FetchReplay(user, strGameID)
+	if err != nil {
+		return nil, err
+	}
+	seats := len(game.Seats())
+	if perspective != engine.Omniscient && (perspective < 0 || perspective >= seats) {
+		return nil, ErrInvalidPerspective
+	}
+	actions := models.LogActions(entries)
+	eng, events, err := engine.ReplayStep(game.ShuffleSeed(), seats, game.Ruleset(), actions, step)
+	if err != nil {
+		return nil, err
+	}
+	replayStep := &ReplayStep{
+		Step:   step,
+		Steps:  len(actions),
+		Events: engine.MaskEvents(events, perspective),
+		State:  eng.Snapshot(perspective),
+	}
+	if step > 0 {
+		replayStep.Action = &actions[step-1]
+	}
+	return replayStep, nil
+}
+
+func (g *GameController) activeGame(strGameID string) (*models.KabooGame, error) {
+	gameID, _ := primitive.ObjectIDFromHex(strGameID)
+	g.gameMtx.Lock()
+	defer g.gameMtx.Unlock()
+	game := g.activeGames[gameID]
+	if game == nil {
+		return nil, ErrGameDoesntExist
+	}
+	return game, nil
+}
+
+func (g *GameController) ownedWaitingGame(user *models.User, strGameID string) (*models.KabooGame, error) {
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return nil, err
+	}
+	if game.Owner != user.ID {
+		return nil, ErrNotGameOwner
+	}
+	if game.State != models.GameStateWaitingForPlayers {
+		return nil, ErrJoinGameAlreadyStarted
+	}
+	return game, nil
+}
+
+// handleSessionEvents sends every human player the events he's allowed to see and
+// ends the game once the engine is done. Players get both the events with their whole view
+// and a versioned delta, the hub only delivers the one their protocol version supports
+func (g *GameController) handleSessionEvents(session *gameSession, events []engine.Event) {
+	session.updatesMtx.Lock()
+	session.mtx.Lock()
+	session.version++
+	players := session.seats[:len(session.game.Players)]
+	messages := make([][]interface{}, len(players))
+	for seat := range players {
+		messages[seat] = []interface{}{
+			websocket.NewWSMessageGameEvents(session.game, engine.MaskEvents(events, seat), session.engine.View(seat)),
+			session.playerUpdate(seat, events),
+		}
+	}
+	session.mtx.Unlock()
+	for seat, userID := range players {
+		for _, message := range messages[seat] {
+			g.sender.BroadcastMessageToUsers([]primitive.ObjectID{userID}, message)
+		}
+	}
+	session.updatesMtx.Unlock()
+	session.feed.publish(websocket.NewWSMessageGameEvents(session.game,
+		engine.MaskEvents(events, engine.Spectator), session.engine.View(engine.Spectator)))
+	if session.engine.IsOver() {
+		results := models.NewPlayerResults(session.game, session.engine.Scores(), session.engine.Rounds())
+		if err := g.db.GamesDAO.SetGameResults(session.game, results); err != nil {
+			log.Errorf("Failed recording game %v results, %v\n", session.game.ID.Hex(), err)
+		}
+		if err := g.db.GamesDAO.UpdateGameState(session.game, models.GameStateEnded); err != nil {
+			log.Errorf("Failed ending game %v, %v\n", session.game.ID.Hex(), err)
+		}
+		if err := g.updateRatings(session.game, session.engine.Scores()); err != nil {
+			log.Errorf("Failed updating game %v ratings, %v\n", session.game.ID.Hex(), err)
+		}
+		g.leaderboards.request()
+		g.sender.BroadcastMessageToUsers(session.game.Players, websocket.NewWSMessageGameSeedRevealed(session.game))
+		session.feed.publish(websocket.NewWSMessageGameSeedRevealed(session.game))
+		g.unregisterGame(session.game)
+	}
+}
+
 func (g *GameController) loadGames() error {
 	games, err := g.db.GamesDAO.FetchActiveGames()
 	if err != nil {
@@ -104,6 +567,18 @@ func (g *GameController) loadGames() error {
 	}
 	for _, game := range games {
 		g.registerActiveGame(game)
+		if game.State == models.GameStateOngoing {
+			session, events, err := restoreGameSession(game, g.db.GameEventsDAO)
+			if err != nil {
+				log.Errorf("Failed restoring game %v, %v\n", game.ID.Hex(), err)
+				continue
+			}
+			g.registerSession(session)
+			// The game may have ended with the bots' turns or before the server stopped finishing it
+			if len(events) > 0 || session.engine.IsOver() {
+				g.handleSessionEvents(session, events)
+			}
+		}
 	}
 	log.Infof("Loaded %d active games\n", len(games))
 	return nil
@@ -116,3 +591,43 @@ func (g *GameController) registerActiveGame(game *models.KabooGame) {
 	g.userToActiveGames[game.Owner] = game
 	g.activeGames[game.ID] = game
 }
+
+// cancelGame deletes a game that never started and forgets it, freeing its players
+func (g *GameController) cancelGame(strGameID string) error {
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return err
+	}
+	if game.State != models.GameStateWaitingForPlayers {
+		return ErrJoinGameAlreadyStarted
+	}
+	if err := g.db.GamesDAO.DeleteGame(game); err != nil {
+		return err
+	}
+	g.unregisterGame(game)
+	return nil
+}
+
+// registerSession opens the spectator feed of a running game and routes its actions to it
+func (g *GameController) registerSession(session *gameSession) {
+	session.feed = newSpectatorFeed(session.game, g.sender, g.gameMtx)
+	g.gameMtx.Lock()
+	defer g.gameMtx.Unlock()
+
+	g.sessions[session.game.ID] = session
+}
+
+// unregisterGame forgets a game and its invites, closing its spectator feed once the queued messages are delivered
+func (g *GameController) unregisterGame(game *models.KabooGame) {
+	g.gameMtx.Lock()
+	defer g.gameMtx.Unlock()
+
+	delete(g.userToActiveGames, game.Owner)
+	delete(g.activeGames, game.ID)
+	g.dropInvites(game.ID, primitive.NilObjectID)
+	if session := g.sessions[game.ID]; session != nil {
+		session.feed.close()
+		delete(g.sessions, game.ID)
+	}
+	g.passwords.forget(game.ID)
+}
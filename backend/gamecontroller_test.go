@@ -2,9 +2,14 @@ package backend
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/ngutman/kaboo-server-go/engine"
 	"github.com/ngutman/kaboo-server-go/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -24,18 +29,19 @@ func (m *MockSender) BroadcastMessageToUsers(users []primitive.ObjectID, message
 }
 
 func Test_CreatingNewGame(t *testing.T) {
-	db, client := clearAndOpenDb(t)
-	user := addUserToDB(t, client, "userid123", "user", "user@user.com")
-	sender := &MockSender{}
-
-	controller := NewGameController(db, sender)
-	gameID, err := controller.NewGame(user, "game1", 5, "password")
+	f := newControllerFixture(t, 1)
+	client, controller, user := f.client, f.controller, f.users[0]
+	var validationErr *models.ValidationError
+	if _, err := controller.NewGame(user, "", 10000, "password", GameOptions{}); !errors.As(err, &validationErr) || len(validationErr.Fields) != 2 {
+		t.Errorf("Should have rejected the name and max players, got %v", err)
+	}
+	gameID, err := controller.NewGame(user, "game1", 5, "password", GameOptions{})
 	createdGameID, _ := primitive.ObjectIDFromHex(gameID)
 	if err != nil {
 		t.Errorf("Error creating a new game, %v\n", err)
 	}
 	t.Logf("Created a new game result %v\n", gameID)
-	_, err = controller.NewGame(user, "game1", 5, "password")
+	_, err = controller.NewGame(user, "game1", 5, "password", GameOptions{})
 	if err == nil {
 		t.Errorf("Should have failed creating a new game for user")
 	}
@@ -48,38 +54,32 @@ func Test_CreatingNewGame(t *testing.T) {
 }
 
 func Test_LoadingActiveGames(t *testing.T) {
-	db, client := clearAndOpenDb(t)
-	user := addUserToDB(t, client, "userid123", "user", "user@user.com")
-	sender := &MockSender{}
-	// Create a game before loading the controller
-	game, _ := db.GamesDAO.CreateGame(user, "game1", 4, "password")
-	controller := NewGameController(db, sender)
+	f := newControllerFixture(t, 1)
+	user := f.users[0]
+	// Create a game before loading another controller
+	game, _ := models.NewKabooGame(user, "game1", 4, "password")
+	f.db.GamesDAO.CreateGame(game)
+	controller := NewGameController(f.db, &MockSender{})
 	if controller.userToActiveGames[user.ID] == nil || controller.activeGames[game.ID] == nil {
 		t.Errorf("Should have loaded active game from db")
 	}
 }
 
 func Test_JoinGameSuccessfully(t *testing.T) {
-	db, client := clearAndOpenDb(t)
-	sender := &MockSender{}
-	user1 := addUserToDB(t, client, "userid1", "user1", "user1@user.com")
-	user2 := addUserToDB(t, client, "userid2", "user2", "user2@user.com")
-	game, _ := db.GamesDAO.CreateGame(user1, "game1", 2, "password")
-	controller := NewGameController(db, sender)
-	success, err := controller.JoinGameByGameID(user2, game.ID.Hex(), "password")
+	f := newControllerFixture(t, 2)
+	controller, user1, user2 := f.controller, f.users[0], f.users[1]
+	gameID, _ := controller.NewGame(user1, "game1", 2, "password", GameOptions{})
+	success, err := controller.JoinGameByGameID(user2, gameID, "password", "")
 	if err != nil || !success {
 		t.Errorf("Error joining game %v", err)
 	}
 }
 
 func Test_JoinGameWrongPassword(t *testing.T) {
-	db, client := clearAndOpenDb(t)
-	sender := &MockSender{}
-	user1 := addUserToDB(t, client, "userid1", "user1", "user1@user.com")
-	user2 := addUserToDB(t, client, "userid2", "user2", "user2@user.com")
-	game, _ := db.GamesDAO.CreateGame(user1, "game1", 2, "password")
-	controller := NewGameController(db, sender)
-	success, err := controller.JoinGameByGameID(user2, game.ID.Hex(), "WRONG")
+	f := newControllerFixture(t, 2)
+	controller, user1, user2 := f.controller, f.users[0], f.users[1]
+	gameID, _ := controller.NewGame(user1, "game1", 2, "password", GameOptions{})
+	success, err := controller.JoinGameByGameID(user2, gameID, "WRONG", "")
 	if success {
 		t.Errorf("Should have failed joining game")
 	} else if !strings.Contains(err.Error(), "Wrong password") {
@@ -88,23 +88,366 @@ func Test_JoinGameWrongPassword(t *testing.T) {
 }
 
 func Test_JoinGameTooManyPlayers(t *testing.T) {
-	db, client := clearAndOpenDb(t)
-	sender := &MockSender{}
-	user1 := addUserToDB(t, client, "userid1", "user1", "user1@user.com")
-	user2 := addUserToDB(t, client, "userid2", "user2", "user2@user.com")
-	user3 := addUserToDB(t, client, "userid3", "user3", "user2@user.com")
-	game, _ := db.GamesDAO.CreateGame(user1, "game1", 2, "password")
-	controller := NewGameController(db, sender)
-	if success, err := controller.JoinGameByGameID(user2, game.ID.Hex(), "password"); !success {
+	f := newControllerFixture(t, 3)
+	controller, user1, user2, user3 := f.controller, f.users[0], f.users[1], f.users[2]
+	gameID, _ := controller.NewGame(user1, "game1", 2, "password", GameOptions{})
+	if success, err := controller.JoinGameByGameID(user2, gameID, "password", ""); !success {
 		t.Errorf("Error joining game %v", err)
 	}
-	if success, err := controller.JoinGameByGameID(user3, game.ID.Hex(), "password"); success {
+	if success, err := controller.JoinGameByGameID(user3, gameID, "password", ""); success {
 		if !strings.Contains(err.Error(), "Too many players in game") {
 			t.Errorf("Should have failed joining game!")
 		}
 	}
 }
 
+func Test_AddBotsAndStartGame(t *testing.T) {
+	f := newControllerFixture(t, 2)
+	controller, user1, user2 := f.controller, f.users[0], f.users[1]
+	gameID, _ := controller.NewGame(user1, "game1", 3, "password", GameOptions{})
+	game, _ := controller.FetchGame(gameID)
+	if _, err := controller.AddBot(user2, game.ID.Hex(), "memory"); err != ErrNotGameOwner {
+		t.Errorf("Only the owner should be able to add bots")
+	}
+	if _, err := controller.AddBot(user1, game.ID.Hex(), "genius"); err != ErrUnknownBotDifficulty {
+		t.Errorf("Should have failed adding a bot with unknown difficulty")
+	}
+	for _, difficulty := range []string{"random", "expected"} {
+		if _, err := controller.AddBot(user1, game.ID.Hex(), difficulty); err != nil {
+			t.Errorf("Error adding bot %v", err)
+		}
+	}
+	if err := controller.StartGame(user1, game.ID.Hex()); err != nil {
+		t.Errorf("Error starting game %v", err)
+	}
+	if game.State != models.GameStateOngoing {
+		t.Errorf("Game should be ongoing")
+	}
+}
+
+func Test_ReplayEndedGame(t *testing.T) {
+	f := newControllerFixture(t, 2)
+	controller, user, stranger := f.controller, f.users[0], f.users[1]
+	gameID, _ := controller.NewGame(user, "game1", 2, "password", GameOptions{})
+	game, _ := controller.FetchGame(gameID)
+	controller.AddBot(user, game.ID.Hex(), "memory")
+	controller.StartGame(user, game.ID.Hex())
+	if _, err := controller.FetchReplayStep(user, game.ID.Hex(), 0, engine.Omniscient); err != ErrGameNotEnded {
+		t.Errorf("Replay of a running game shouldn't be available")
+	}
+	session := controller.sessions[game.ID]
+	for !session.engine.IsOver() {
+		legal := session.engine.LegalActions(0)
+		if err := controller.ApplyAction(user, game.ID.Hex(), legal[0]); err != nil {
+			t.Fatalf("Error applying action %v", err)
+		}
+	}
+	if _, _, err := controller.FetchReplay(stranger, game.ID.Hex()); err != ErrNotInGame {
+		t.Errorf("Replay of a password protected game should only be available to its players, got %v", err)
+	}
+	_, entries, err := controller.FetchReplay(user, game.ID.Hex())
+	if err != nil || len(entries) != len(session.engine.History())+1 {
+		t.Fatalf("Event log should hold the deal and every action, %v", err)
+	}
+	last, err := controller.FetchReplayStep(user, game.ID.Hex(), len(entries)-1, 0)
+	if err != nil || !reflect.DeepEqual(last.State.Scores, session.engine.Scores()) {
+		t.Errorf("Replaying the log should reach the final scores, %v", err)
+	}
+	first, _ := controller.FetchReplayStep(user, game.ID.Hex(), 0, 0)
+	if first.State.Hands[1][0] != nil {
+		t.Errorf("Player perspective shouldn't reveal opponent cards")
+	}
+}
+
+func Test_SpectateGame(t *testing.T) {
+	f := newControllerFixture(t, 3)
+	controller, user1, user2, user3 := f.controller, f.users[0], f.users[1], f.users[2]
+	gameID, _ := controller.NewGame(user1, "game1", 2, "password", GameOptions{
+		Spectators: models.SpectatorSettings{MaxSpectators: 1},
+	})
+	if err := controller.SpectateGame(user1, gameID); err != ErrAlreadyPlaying {
+		t.Errorf("Players shouldn't be able to spectate their own game")
+	}
+	if err := controller.SpectateGame(user2, gameID); err != nil {
+		t.Errorf("Error spectating game %v", err)
+	}
+	if err := controller.SpectateGame(user3, gameID); err != models.ErrTooManySpectators {
+		t.Errorf("Spectator cap should have been enforced")
+	}
+	controller.StopSpectating(user2, gameID)
+	if err := controller.SpectateGame(user3, gameID); err != nil {
+		t.Errorf("Error spectating game after a spectator left %v", err)
+	}
+}
+
+func Test_RatingsUpdatedAfterRatedGame(t *testing.T) {
+	f := newControllerFixture(t, 2)
+	db, controller, user1, user2 := f.db, f.controller, f.users[0], f.users[1]
+	gameID, _ := controller.NewGame(user1, "game1", 2, "password", GameOptions{})
+	controller.JoinGameByGameID(user2, gameID, "password", "")
+	controller.StartGame(user1, gameID)
+	game, _ := controller.FetchGame(gameID)
+	session := controller.sessions[game.ID]
+	users := []*models.User{user1, user2}
+	for !session.engine.IsOver() {
+		seat := session.engine.CurrentSeat()
+		legal := session.engine.LegalActions(seat)
+		if err := controller.ApplyAction(users[seat], gameID, legal[len(legal)-1]); err != nil {
+			t.Fatalf("Error applying action %v", err)
+		}
+	}
+	for _, user := range users {
+		updated, _ := db.UserDAO.FetchUserByID(user.ID)
+		if updated.RatedGames != 1 || len(updated.RatingHistory) != 1 {
+			t.Errorf("Rated game should have updated the rating of %v", user.Username)
+		}
+	}
+	// Leaderboards are refreshed in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entries, err := db.LeaderboardDAO.FetchLeaderboard(models.LeaderboardWeekly, models.LeaderboardByRating, 1, 10)
+		if err == nil && len(entries) == 2 && entries[0].Rating >= entries[1].Rating {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Leaderboard should have been refreshed when the game ended (%v)", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_LeavingRunningGameAbandonsIt(t *testing.T) {
+	f := newControllerFixture(t, 2)
+	db, controller, user1, user2 := f.db, f.controller, f.users[0], f.users[1]
+	gameID, _ := controller.NewGame(user1, "game1", 2, "password", GameOptions{})
+	controller.JoinGameByGameID(user2, gameID, "password", "")
+	if err := controller.LeaveGame(user1, gameID); err != ErrOwnerCannotLeave {
+		t.Errorf("Owner shouldn't leave a game waiting for players, got %v", err)
+	}
+	controller.StartGame(user1, gameID)
+	game, _ := controller.FetchGame(gameID)
+	session := controller.sessions[game.ID]
+
+	if err := controller.LeaveGame(user2, gameID); err != nil {
+		t.Fatalf("Error leaving game %v", err)
+	}
+	seat := session.engine.CurrentSeat()
+	if err := controller.ApplyAction([]*models.User{user1, user2}[seat], gameID, session.engine.LegalActions(seat)[0]); err != ErrGameNotRunning {
+		t.Errorf("Abandoned game shouldn't be played, got %v", err)
+	}
+	game, _ = controller.FetchGame(gameID)
+	if game.State != models.GameStateEnded || game.AbandonedBy != user2.ID || game.IsRated(true) {
+		t.Errorf("Game should have ended abandoned and unrated, got %+v", game)
+	}
+	for _, user := range []*models.User{user1, user2} {
+		updated, _ := db.UserDAO.FetchUserByID(user.ID)
+		if updated.RatedGames != 0 || db.GamesDAO.IsPlayerInActiveGame(user.ID) {
+			t.Errorf("Abandoned game should free %v without rating him", user.Username)
+		}
+	}
+}
+
+func Test_PlayerHistoryAndStats(t *testing.T) {
+	f := newControllerFixture(t, 1)
+	db, controller, user1 := f.db, f.controller, f.users[0]
+	gameID, _ := controller.NewGame(user1, "game1", 2, "", GameOptions{})
+	controller.AddBot(user1, gameID, "random")
+	controller.StartGame(user1, gameID)
+	game, _ := controller.FetchGame(gameID)
+	session := controller.sessions[game.ID]
+	for !session.engine.IsOver() {
+		legal := session.engine.LegalActions(0)
+		if err := controller.ApplyAction(user1, gameID, legal[len(legal)-1]); err != nil {
+			t.Fatalf("Error applying action %v", err)
+		}
+	}
+	games, total, err := db.GamesDAO.FetchPlayerHistory(user1.ID, 0, 10)
+	if err != nil || total != 1 || len(games) != 1 || games[0].ResultFor(user1.ID) == nil {
+		t.Fatalf("Ended game should be in the player's history (%v)", err)
+	}
+	stats, err := db.GamesDAO.AggregatePlayerStats(user1.ID)
+	if err != nil || stats.GamesPlayed != 1 || stats.BestRound == nil {
+		t.Errorf("Unexpected stats %+v (%v)", stats, err)
+	}
+}
+
+func Test_UsernameIsUniqueIgnoringCase(t *testing.T) {
+	f := newControllerFixture(t, 0)
+	addUserToDB(t, f.client, "userid1", "Alice", "alice@user.com")
+	user2 := addUserToDB(t, f.client, "userid2", "bob", "bob@user.com")
+	if err := f.db.UserDAO.UpdateUsername(user2, "alice"); err != models.ErrUsernameTaken {
+		t.Errorf("Expected username taken error, got %v", err)
+	}
+	if err := f.db.UserDAO.UpdateUsername(user2, "Bobby"); err != nil || user2.Username != "Bobby" {
+		t.Errorf("Should have changed username, %v", err)
+	}
+}
+
+func Test_FriendsAndGameInvites(t *testing.T) {
+	f := newControllerFixture(t, 2)
+	controller, user1, user2 := f.controller, f.users[0], f.users[1]
+	if status, err := controller.SendFriendRequest(user1, user2.ID.Hex()); err != nil || status != models.FriendshipPending {
+		t.Fatalf("Unexpected friend request result %v (%v)", status, err)
+	}
+	if _, err := controller.SendFriendRequest(user1, user2.ID.Hex()); err != models.ErrFriendRequestPending {
+		t.Errorf("Expected pending request error, got %v", err)
+	}
+	if err := controller.RespondToFriendRequest(user2, user1.ID.Hex(), true); err != nil {
+		t.Fatalf("Error accepting friend request %v", err)
+	}
+	friends, _ := controller.FetchFriends(user2)
+	if len(friends) != 1 || friends[0].User.ID != user1.ID || friends[0].Status != models.FriendshipAccepted || !friends[0].Incoming {
+		t.Errorf("Unexpected friends %+v", friends)
+	}
+
+	gameID, _ := controller.NewGame(user1, "game1", 4, "secret", GameOptions{})
+	inviteID, err := controller.InviteFriend(user1, user2.ID.Hex())
+	if err != nil {
+		t.Fatalf("Error inviting friend %v", err)
+	}
+	if _, err := controller.AcceptGameInvite(user1, inviteID); err != ErrInviteNotFound {
+		t.Errorf("Only the invited user can accept, got %v", err)
+	}
+	if joined, err := controller.AcceptGameInvite(user2, inviteID); err != nil || joined != gameID {
+		t.Errorf("Invited friend should have joined without the password (%v)", err)
+	}
+	if _, err := controller.AcceptGameInvite(user2, inviteID); err != ErrInviteNotFound {
+		t.Errorf("Invites can only be used once, got %v", err)
+	}
+
+	if err := controller.BlockUser(user2, user1.ID.Hex()); err != nil {
+		t.Fatalf("Error blocking user %v", err)
+	}
+	if _, err := controller.SendFriendRequest(user1, user2.ID.Hex()); err != models.ErrUserBlocked {
+		t.Errorf("Blocked user shouldn't be able to send requests, got %v", err)
+	}
+	if friends, _ := controller.FetchFriends(user1); len(friends) != 0 {
+		t.Errorf("Blocked user shouldn't see who blocked him")
+	}
+}
+
+func Test_GameInvitesDropped(t *testing.T) {
+	f := newControllerFixture(t, 3)
+	controller, user1, user2, user3 := f.controller, f.users[0], f.users[1], f.users[2]
+	for _, pair := range [][2]*models.User{{user1, user2}, {user1, user3}, {user2, user3}} {
+		controller.SendFriendRequest(pair[0], pair[1].ID.Hex())
+		controller.RespondToFriendRequest(pair[1], pair[0].ID.Hex(), true)
+	}
+
+	gameID, _ := controller.NewGame(user1, "game1", 4, "", GameOptions{})
+	inviteID, _ := controller.InviteFriend(user1, user2.ID.Hex())
+	controller.AcceptGameInvite(user2, inviteID)
+	if _, err := controller.InviteFriend(user2, user3.ID.Hex()); err != ErrNotGameOwner {
+		t.Errorf("Only the owner may invite, got %v", err)
+	}
+
+	// Invites a player sent are dropped once he's kicked
+	controller.TransferOwnership(user1, gameID, user2.ID.Hex())
+	inviteID, _ = controller.InviteFriend(user2, user3.ID.Hex())
+	controller.TransferOwnership(user2, gameID, user1.ID.Hex())
+	controller.KickPlayer(user1, gameID, user2.ID.Hex(), false)
+	if _, err := controller.AcceptGameInvite(user3, inviteID); err != ErrInviteNotFound {
+		t.Errorf("Invites of a kicked player should be dropped, got %v", err)
+	}
+
+	// Or once he leaves
+	inviteID, _ = controller.InviteFriend(user1, user2.ID.Hex())
+	controller.AcceptGameInvite(user2, inviteID)
+	controller.TransferOwnership(user1, gameID, user2.ID.Hex())
+	inviteID, _ = controller.InviteFriend(user2, user3.ID.Hex())
+	controller.TransferOwnership(user2, gameID, user1.ID.Hex())
+	if err := controller.LeaveGame(user2, gameID); err != nil {
+		t.Fatalf("Error leaving game %v", err)
+	}
+	if _, err := controller.AcceptGameInvite(user3, inviteID); err != ErrInviteNotFound {
+		t.Errorf("Invites of a player who left should be dropped, got %v", err)
+	}
+
+	// A game's invites are dropped once it starts
+	inviteID, _ = controller.InviteFriend(user1, user3.ID.Hex())
+	controller.AddBot(user1, gameID, "random")
+	if err := controller.StartGame(user1, gameID); err != nil {
+		t.Fatalf("Error starting game %v", err)
+	}
+	if _, err := controller.AcceptGameInvite(user3, inviteID); err != ErrInviteNotFound {
+		t.Errorf("Invites to a started game should be dropped, got %v", err)
+	}
+
+	// Or once it's cancelled
+	gameID, _ = controller.NewGame(user2, "game2", 4, "", GameOptions{})
+	inviteID, _ = controller.InviteFriend(user2, user3.ID.Hex())
+	controller.cancelGame(gameID)
+	controller.gameMtx.Lock()
+	pending := len(controller.invites)
+	controller.gameMtx.Unlock()
+	if pending != 0 {
+		t.Errorf("Invites to a cancelled game should be dropped, %d are pending", pending)
+	}
+}
+
+func Test_OwnerLobbyControls(t *testing.T) {
+	f := newControllerFixture(t, 3)
+	controller, user1, user2, user3 := f.controller, f.users[0], f.users[1], f.users[2]
+	gameID, _ := controller.NewGame(user1, "game1", 4, "", GameOptions{Visibility: models.GameVisibilityFriendsOnly})
+	if games, _ := controller.ListGames(user2); len(games) != 0 {
+		t.Errorf("Friends only game shouldn't be listed to strangers")
+	}
+	if _, err := controller.JoinGameByGameID(user2, gameID, "", ""); err != ErrFriendsOnlyGame {
+		t.Errorf("Expected friends only error, got %v", err)
+	}
+	if _, err := controller.FetchVisibleGame(user2, gameID); err != ErrFriendsOnlyGame {
+		t.Errorf("Friends only game shouldn't be visible to strangers, got %v", err)
+	}
+	public := models.GameVisibilityPublic
+	if err := controller.UpdateLobbySettings(user2, gameID, LobbySettings{Visibility: &public}); err != ErrNotGameOwner {
+		t.Errorf("Only the owner may change the lobby, got %v", err)
+	}
+	name, maxPlayers := "renamed", 3
+	if err := controller.UpdateLobbySettings(user1, gameID, LobbySettings{Name: &name, MaxPlayers: &maxPlayers, Visibility: &public}); err != nil {
+		t.Fatalf("Error updating lobby %v", err)
+	}
+	if games, _ := controller.ListGames(user2); len(games) != 1 || games[0].Name != name {
+		t.Errorf("Public game should be listed")
+	}
+	controller.JoinGameByGameID(user2, gameID, "", "")
+	controller.JoinGameByGameID(user3, gameID, "", "")
+	if err := controller.KickPlayer(user1, gameID, user3.ID.Hex(), true); err != nil {
+		t.Fatalf("Error kicking player %v", err)
+	}
+	if _, err := controller.JoinGameByGameID(user3, gameID, "", ""); err != models.ErrBannedFromGame {
+		t.Errorf("Banned player shouldn't be able to rejoin, got %v", err)
+	}
+	if err := controller.TransferOwnership(user1, gameID, user3.ID.Hex()); err != models.ErrPlayerNotInGame {
+		t.Errorf("Ownership can only go to seated players, got %v", err)
+	}
+	if err := controller.TransferOwnership(user1, gameID, user2.ID.Hex()); err != nil {
+		t.Fatalf("Error transferring ownership %v", err)
+	}
+	if err := controller.KickPlayer(user1, gameID, user2.ID.Hex(), false); err != ErrNotGameOwner {
+		t.Errorf("Previous owner shouldn't be able to kick, got %v", err)
+	}
+}
+
+func Test_HouseRules(t *testing.T) {
+	f := newControllerFixture(t, 1)
+	controller, user1 := f.controller, f.users[0]
+	rules := engine.DefaultRuleset()
+	rules.HandSize = engine.MaxHandSize + 1
+	if _, err := controller.NewGame(user1, "game1", 2, "", GameOptions{Rules: &rules}); !errors.Is(err, engine.ErrInvalidRuleset) {
+		t.Errorf("Expected invalid ruleset, got %v", err)
+	}
+	rules.HandSize, rules.Snapping, rules.TargetScore = 5, true, 50
+	gameID, err := controller.NewGame(user1, "game1", 2, "", GameOptions{Rules: &rules})
+	if err != nil {
+		t.Fatalf("Error creating game %v", err)
+	}
+	storedID, _ := primitive.ObjectIDFromHex(gameID)
+	game, _ := f.db.GamesDAO.FetchGameByID(storedID)
+	if !reflect.DeepEqual(game.Ruleset(), rules) {
+		t.Errorf("Expected stored rules %+v, got %+v", rules, game.Ruleset())
+	}
+}
+
 func clearAndOpenDb(t *testing.T) (*models.Db, *mongo.Client) {
 	clientOptions := options.Client().ApplyURI(TestingURI)
 	client, _ := mongo.Connect(context.Background(), clientOptions)
@@ -131,3 +474,21 @@ func addUserToDB(t *testing.T, client *mongo.Client, externalUserID string, user
 	}
 	return &user
 }
+
+// controllerFixture a cleared database, a controller over it and users named user1, user2...
+type controllerFixture struct {
+	db         *models.Db
+	client     *mongo.Client
+	controller *GameController
+	users      []*models.User
+}
+
+func newControllerFixture(t *testing.T, users int) *controllerFixture {
+	db, client := clearAndOpenDb(t)
+	f := &controllerFixture{db: db, client: client, controller: NewGameController(db, &MockSender{})}
+	for i := 1; i <= users; i++ {
+		name := fmt.Sprintf("user%d", i)
+		f.users = append(f.users, addUserToDB(t, client, fmt.Sprintf("userid%d", i), name, name+"@user.com"))
+	}
+	return f
+}
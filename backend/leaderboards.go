@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// leaderboardRefreshInterval how often the leaderboards are refreshed when no game ends, so the
+// weekly and monthly windows roll past games that fell out of them
+const leaderboardRefreshInterval = 15 * time.Minute
+
+// leaderboardRefresher recomputes the leaderboards on its own goroutine, one refresh at a time.
+// Refreshes requested while one is running are merged into a single refresh that follows it
+type leaderboardRefresher struct {
+	refresh func() error
+	// requests holds at most one pending refresh request
+	requests chan struct{}
+}
+
+func newLeaderboardRefresher(refresh func() error, interval time.Duration) *leaderboardRefresher {
+	r := &leaderboardRefresher{
+		refresh:  refresh,
+		requests: make(chan struct{}, 1),
+	}
+	go r.run(interval)
+	return r
+}
+
+// request asks for a refresh without blocking
+func (r *leaderboardRefresher) request() {
+	select {
+	case r.requests <- struct{}{}:
+	default:
+	}
+}
+
+func (r *leaderboardRefresher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.requests:
+		case <-ticker.C:
+		}
+		if err := r.refresh(); err != nil {
+			log.Errorf("Failed refreshing leaderboards, %v\n", err)
+		}
+	}
+}
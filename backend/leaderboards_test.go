@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_LeaderboardRefreshesMerged(t *testing.T) {
+	release := make(chan struct{})
+	var refreshes int32
+	refresher := newLeaderboardRefresher(func() error {
+		atomic.AddInt32(&refreshes, 1)
+		<-release
+		return nil
+	}, time.Hour)
+
+	refresher.request()
+	for atomic.LoadInt32(&refreshes) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Requests made while a refresh runs never block and are merged into one refresh
+	for i := 0; i < 100; i++ {
+		refresher.request()
+	}
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+	if count := atomic.LoadInt32(&refreshes); count != 2 {
+		t.Errorf("Expected 2 refreshes, got %d", count)
+	}
+}
+
+func Test_LeaderboardRefreshedPeriodically(t *testing.T) {
+	refreshed := make(chan struct{}, 10)
+	newLeaderboardRefresher(func() error {
+		refreshed <- struct{}{}
+		return nil
+	}, 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-refreshed:
+		case <-time.After(time.Second):
+			t.Fatalf("Leaderboards weren't refreshed on time")
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package backend
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrCannotKickOwner the owner can't kick himself, he should transfer ownership instead
+	ErrCannotKickOwner = errors.New("Owner can't be kicked")
+	// ErrFriendsOnlyGame only friends of the owner may join the game
+	ErrFriendsOnlyGame = errors.New("Game is open to the owner's friends only")
+)
+
+// LobbySettings the settings the owner may change before the game starts, nil fields are left unchanged
+type LobbySettings struct {
+	Name       *string
+	MaxPlayers *int
+	Visibility *models.GameVisibility
+}
+
+// ListGames returns the games waiting for players the user may find, newest first. Public games are
+// listed to everyone and friends only games to the owner's friends
+func (g *GameController) ListGames(user *models.User) ([]*models.KabooGame, error) {
+	g.gameMtx.Lock()
+	var candidates []*models.KabooGame
+	for _, game := range g.activeGames {
+		if game.State == models.GameStateWaitingForPlayers && !game.IsBanned(user.ID) {
+			candidates = append(candidates, game)
+		}
+	}
+	g.gameMtx.Unlock()
+
+	games := []*models.KabooGame{}
+	for _, game := range candidates {
+		switch game.GameVisibility() {
+		case models.GameVisibilityPublic:
+			games = append(games, game)
+		case models.GameVisibilityFriendsOnly:
+			friends, err := g.db.FriendsDAO.AreFriends(game.Owner, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if friends || game.Owner == user.ID {
+				games = append(games, game)
+			}
+		}
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].ID.Timestamp().After(games[j].ID.Timestamp())
+	})
+	return games, nil
+}
+
+// UpdateLobbySettings renames the game, changes its seats or its visibility before it starts
+func (g *GameController) UpdateLobbySettings(user *models.User, strGameID string, settings LobbySettings) error {
+	game, err := g.ownedWaitingGame(user, strGameID)
+	if err != nil {
+		return err
+	}
+	if settings.Visibility != nil {
+		if err := settings.Visibility.Validate(); err != nil {
+			return err
+		}
+	}
+	var name string
+	if settings.Name != nil {
+		if name, err = models.NormalizeGameName(*settings.Name); err != nil {
+			return &models.ValidationError{Fields: []models.FieldError{{Field: "name", Message: err.Error()}}}
+		}
+	}
+	if settings.MaxPlayers != nil {
+		if err := g.db.GamesDAO.UpdateMaxPlayers(game, *settings.MaxPlayers); err != nil {
+			return err
+		}
+	}
+	if settings.Name != nil {
+		if err := g.db.GamesDAO.RenameGame(game, name); err != nil {
+			return err
+		}
+	}
+	if settings.Visibility != nil {
+		if err := g.db.GamesDAO.SetVisibility(game, *settings.Visibility); err != nil {
+			return err
+		}
+	}
+	g.sender.BroadcastMessageToUsers(game.Audience(),
+		websocket.NewWSMessageLobbyUpdate(game, websocket.LobbyUpdateSettingsChanged, ""))
+	return nil
+}
+
+// KickPlayer removes a player from a game waiting for players, a banned player can't rejoin
+func (g *GameController) KickPlayer(user *models.User, strGameID string, strPlayerID string, ban bool) error {
+	game, err := g.ownedWaitingGame(user, strGameID)
+	if err != nil {
+		return err
+	}
+	playerID, err := primitive.ObjectIDFromHex(strPlayerID)
+	if err != nil {
+		return models.ErrPlayerNotInGame
+	}
+	if playerID == game.Owner {
+		return ErrCannotKickOwner
+	}
+	if err := g.db.GamesDAO.RemovePlayerFromGame(game, playerID, ban); err != nil {
+		return err
+	}
+	g.gameMtx.Lock()
+	g.dropInvites(game.ID, playerID)
+	g.gameMtx.Unlock()
+	update := websocket.LobbyUpdatePlayerKicked
+	if ban {
+		update = websocket.LobbyUpdatePlayerBanned
+	}
+	// The kicked player is no longer part of the audience but should know he was removed
+	g.sender.BroadcastMessageToUsers(append(game.Audience(), playerID),
+		websocket.NewWSMessageLobbyUpdate(game, update, playerID.Hex()))
+	return nil
+}
+
+// TransferOwnership hands the game over to another seated player
+func (g *GameController) TransferOwnership(user *models.User, strGameID string, strPlayerID string) error {
+	game, err := g.activeGame(strGameID)
+	if err != nil {
+		return err
+	}
+	if game.Owner != user.ID {
+		return ErrNotGameOwner
+	}
+	playerID, err := primitive.ObjectIDFromHex(strPlayerID)
+	if err != nil {
+		return models.ErrPlayerNotInGame
+	}
+	g.gameMtx.Lock()
+	previous := game.Owner
+	err = g.db.GamesDAO.TransferOwnership(game, playerID)
+	if err == nil {
+		delete(g.userToActiveGames, previous)
+		g.userToActiveGames[game.Owner] = game
+	}
+	g.gameMtx.Unlock()
+	if err != nil {
+		return err
+	}
+	g.sender.BroadcastMessageToUsers(game.Audience(),
+		websocket.NewWSMessageLobbyUpdate(game, websocket.LobbyUpdateOwnerChanged, playerID.Hex()))
+	return nil
+}
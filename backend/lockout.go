@@ -0,0 +1,108 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// freePasswordAttempts wrong passwords allowed before joining the game is locked
+	freePasswordAttempts = 3
+	// minPasswordLockout lockout after the first wrong password past the free attempts, doubled on every later one
+	minPasswordLockout = 5 * time.Second
+	// maxPasswordLockout longest lockout, however many wrong passwords were tried
+	maxPasswordLockout = 15 * time.Minute
+)
+
+var (
+	// ErrTooManyWrongPasswords joining the game is locked after repeated wrong passwords
+	ErrTooManyWrongPasswords = errors.New("Too many wrong passwords")
+)
+
+// LockoutError joining a game is locked, wraps ErrTooManyWrongPasswords
+type LockoutError struct {
+	// Wait until joining is unlocked
+	Wait time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%v, try again in %v", ErrTooManyWrongPasswords, e.Wait.Round(time.Second))
+}
+
+// Unwrap returns ErrTooManyWrongPasswords
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyWrongPasswords
+}
+
+// RetryAfter returns how long until joining is unlocked
+func (e *LockoutError) RetryAfter() time.Duration {
+	return e.Wait
+}
+
+// passwordAttempts wrong passwords tried on a single game
+type passwordAttempts struct {
+	failures    int
+	lockedUntil time.Time
+}
+
+// passwordGuard locks joining a game for exponentially longer after repeated wrong passwords. Attempts are
+// counted per game rather than per user so brute forcing doesn't get faster with more accounts
+type passwordGuard struct {
+	games map[primitive.ObjectID]*passwordAttempts
+	mtx   sync.Mutex
+	now   func() time.Time
+}
+
+func newPasswordGuard() *passwordGuard {
+	return &passwordGuard{
+		games: make(map[primitive.ObjectID]*passwordAttempts),
+		now:   time.Now,
+	}
+}
+
+// check returns a LockoutError if joining the game is locked
+func (p *passwordGuard) check(gameID primitive.ObjectID) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	attempts := p.games[gameID]
+	if attempts == nil {
+		return nil
+	}
+	if wait := attempts.lockedUntil.Sub(p.now()); wait > 0 {
+		return &LockoutError{wait}
+	}
+	return nil
+}
+
+// fail records a wrong password, locking the game once the free attempts are used up
+func (p *passwordGuard) fail(gameID primitive.ObjectID) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	attempts := p.games[gameID]
+	if attempts == nil {
+		attempts = &passwordAttempts{}
+		p.games[gameID] = attempts
+	}
+	attempts.failures++
+	if attempts.failures < freePasswordAttempts {
+		return
+	}
+	lockout := maxPasswordLockout
+	if shift := uint(attempts.failures - freePasswordAttempts); shift < 16 && minPasswordLockout<<shift < maxPasswordLockout {
+		lockout = minPasswordLockout << shift
+	}
+	attempts.lockedUntil = p.now().Add(lockout)
+}
+
+// forget clears the game's wrong passwords, after a correct one or when the game is no longer joinable
+func (p *passwordGuard) forget(gameID primitive.ObjectID) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	delete(p.games, gameID)
+}
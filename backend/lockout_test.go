@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_PasswordLockout(t *testing.T) {
+	now := time.Now()
+	guard := newPasswordGuard()
+	guard.now = func() time.Time { return now }
+	game, other := primitive.NewObjectID(), primitive.NewObjectID()
+
+	for i := 1; i < freePasswordAttempts; i++ {
+		guard.fail(game)
+		if err := guard.check(game); err != nil {
+			t.Fatalf("Wrong password %d shouldn't lock the game, got %v", i, err)
+		}
+	}
+	expected := []time.Duration{minPasswordLockout, 2 * minPasswordLockout, 4 * minPasswordLockout}
+	for _, lockout := range expected {
+		guard.fail(game)
+		var lockoutErr *LockoutError
+		if err := guard.check(game); !errors.As(err, &lockoutErr) || !errors.Is(err, ErrTooManyWrongPasswords) ||
+			lockoutErr.RetryAfter() != lockout {
+			t.Fatalf("Game should be locked for %v, got %v", lockout, err)
+		}
+		now = now.Add(lockout)
+		if err := guard.check(game); err != nil {
+			t.Fatalf("Lockout should expire after %v, got %v", lockout, err)
+		}
+	}
+	if err := guard.check(other); err != nil {
+		t.Errorf("Other games shouldn't be locked, got %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		guard.fail(game)
+	}
+	if err := guard.check(game).(*LockoutError); err.Wait != maxPasswordLockout {
+		t.Errorf("Lockout should be capped at %v, got %v", maxPasswordLockout, err.Wait)
+	}
+	guard.forget(game)
+	if err := guard.check(game); err != nil {
+		t.Errorf("Forgotten game shouldn't be locked, got %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/bots"
+	"github.com/ngutman/kaboo-server-go/engine"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultRuleset ruleset used when a quick match request doesn't name one, the engine's default rules
+	DefaultRuleset = "standard"
+	// DefaultBotFillTimeout time a user waits before a quick match is filled with bots
+	DefaultBotFillTimeout = 30 * time.Second
+	// matchmakingInterval how often the queue is checked for timed out requests
+	matchmakingInterval = time.Second
+	// quickMatchName name of the games created by the matchmaker
+	quickMatchName = "Quick match"
+	// baseRatingWindow rating difference allowed between matched users
+	baseRatingWindow = 150.0
+	// ratingWindowGrowth rating difference added for every second the oldest user waits
+	ratingWindowGrowth = 10.0
+)
+
+var (
+	// ErrAlreadyQueued user is already waiting for a match
+	ErrAlreadyQueued = errors.New("User already waiting for a match")
+
+	// ErrNotQueued user isn't waiting for a match
+	ErrNotQueued = errors.New("User isn't waiting for a match")
+
+	// ErrInvalidPlayerCount requested player count isn't supported
+	ErrInvalidPlayerCount = errors.New("Invalid number of players")
+
+	// ErrUnknownRuleset no such ruleset
+	ErrUnknownRuleset = errors.New("Unknown ruleset")
+)
+
+// quickMatchRulesets the rules quick matches may be played by, by name. Users are only matched
+// with users asking for the same ruleset
+var quickMatchRulesets = map[string]engine.Ruleset{
+	DefaultRuleset: engine.DefaultRuleset(),
+}
+
+// matchRequest a user waiting for a quick match
+type matchRequest struct {
+	user     *models.User
+	players  int
+	ruleset  string
+	rating   float64
+	queuedAt time.Time
+}
+
+// match requests grouped into a game, missing seats are filled with bots
+type match struct {
+	requests []*matchRequest
+	bots     int
+}
+
+// Matchmaker queues users asking to play now and forms games once enough
+// compatible users are waiting, filling the game with bots after a timeout
+type Matchmaker struct {
+	controller     *GameController
+	sender         MessageSender
+	botFillTimeout time.Duration
+	botDifficulty  bots.Difficulty
+	queue          []*matchRequest
+	mtx            sync.Mutex
+}
+
+// NewMatchmaker returns a new matchmaker creating games through the given controller
+func NewMatchmaker(controller *GameController, sender MessageSender, botFillTimeout time.Duration) *Matchmaker {
+	return &Matchmaker{
+		controller:     controller,
+		sender:         sender,
+		botFillTimeout: botFillTimeout,
+		botDifficulty:  bots.DifficultyExpected,
+	}
+}
+
+// Enqueue adds the user to the quick match queue
+func (m *Matchmaker) Enqueue(user *models.User, players int, ruleset string) error {
+	if players < engine.MinPlayers || players > engine.MaxPlayers {
+		return ErrInvalidPlayerCount
+	}
+	if ruleset == "" {
+		ruleset = DefaultRuleset
+	}
+	if _, ok := quickMatchRulesets[ruleset]; !ok {
+		return ErrUnknownRuleset
+	}
+	if m.controller.db.GamesDAO.IsPlayerInActiveGame(user.ID) {
+		return ErrAlreadyInGame
+	}
+	m.mtx.Lock()
+	for _, request := range m.queue {
+		if request.user.ID == user.ID {
+			m.mtx.Unlock()
+			return ErrAlreadyQueued
+		}
+	}
+	m.queue = append(m.queue, &matchRequest{
+		user:     user,
+		players:  players,
+		ruleset:  ruleset,
+		rating:   user.CurrentRating(),
+		queuedAt: time.Now(),
+	})
+	m.mtx.Unlock()
+
+	m.matchQueued(time.Now())
+	return nil
+}
+
+// Dequeue removes the user from the quick match queue
+func (m *Matchmaker) Dequeue(user *models.User) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for i, request := range m.queue {
+		if request.user.ID == user.ID {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotQueued
+}
+
+// Run periodically fills matches that waited too long with bots
+func (m *Matchmaker) Run() {
+	ticker := time.NewTicker(matchmakingInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.matchQueued(now)
+	}
+}
+
+func (m *Matchmaker) matchQueued(now time.Time) {
+	m.mtx.Lock()
+	var matches []match
+	matches, m.queue = findMatches(m.queue, now, m.botFillTimeout)
+	m.mtx.Unlock()
+
+	for _, match := range matches {
+		if err := m.startMatch(match); err != nil {
+			log.Errorf("Failed starting quick match, %v\n", err)
+		}
+	}
+}
+
+// findMatches groups compatible requests, returning the formed matches and the
+// requests still waiting. The longest waiting user is matched first with users
+// whose rating is within a window that widens the longer he waits.
+func findMatches(queue []*matchRequest, now time.Time, botFillTimeout time.Duration) ([]match, []*matchRequest) {
+	type key struct {
+		players int
+		ruleset string
+	}
+	groups := make(map[key][]*matchRequest)
+	var order []key
+	for _, request := range queue {
+		k := key{request.players, request.ruleset}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], request)
+	}
+	var matches []match
+	var waiting []*matchRequest
+	for _, k := range order {
+		group := groups[k]
+		for len(group) > 0 {
+			anchor := group[0]
+			waited := now.Sub(anchor.queuedAt)
+			window := baseRatingWindow + ratingWindowGrowth*waited.Seconds()
+			var picked, rest []*matchRequest
+			for _, request := range group {
+				if len(picked) < k.players && math.Abs(request.rating-anchor.rating) <= window {
+					picked = append(picked, request)
+				} else {
+					rest = append(rest, request)
+				}
+			}
+			switch {
+			case len(picked) == k.players:
+				matches = append(matches, match{requests: picked})
+				group = rest
+			case waited >= botFillTimeout:
+				matches = append(matches, match{requests: picked, bots: k.players - len(picked)})
+				group = rest
+			default:
+				waiting = append(waiting, anchor)
+				group = group[1:]
+			}
+		}
+	}
+	return matches, waiting
+}
+
+// startMatch creates an unlisted, password protected game owned by the longest waiting user, seats everyone and starts it.
+// When the game can't be set up it's deleted and the users still free to play are queued again
+func (m *Matchmaker) startMatch(match match) error {
+	gameID, err := m.setUpMatch(match)
+	if err == nil {
+		return nil
+	}
+	if gameID != "" {
+		if err := m.controller.cancelGame(gameID); err != nil {
+			log.Errorf("Failed deleting quick match %v, %v\n", gameID, err)
+		}
+	}
+	m.requeue(match.requests)
+	return err
+}
+
+func (m *Matchmaker) setUpMatch(match match) (string, error) {
+	owner := match.requests[0].user
+	password, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	rules := quickMatchRulesets[match.requests[0].ruleset]
+	// Quick matches are only reachable by the matched users, they aren't listed in the lobby
+	gameID, err := m.controller.NewGame(owner, quickMatchName, len(match.requests)+match.bots, password,
+		GameOptions{Rules: &rules, Visibility: models.GameVisibilityUnlisted})
+	if err != nil {
+		return "", err
+	}
+	users := []primitive.ObjectID{owner.ID}
+	for _, request := range match.requests[1:] {
+		if _, err := m.controller.JoinGameByGameID(request.user, gameID, password, ""); err != nil {
+			return gameID, err
+		}
+		users = append(users, request.user.ID)
+	}
+	for i := 0; i < match.bots; i++ {
+		if _, err := m.controller.AddBot(owner, gameID, string(m.botDifficulty)); err != nil {
+			return gameID, err
+		}
+	}
+	m.sender.BroadcastMessageToUsers(users, websocket.NewWSMessageMatchFound(gameID, match.bots))
+	return gameID, m.controller.StartGame(owner, gameID)
+}
+
+// requeue puts the requests of a failed match back at the head of the queue, keeping their waiting
+// time. Users who joined another game or queued again meanwhile are left out
+func (m *Matchmaker) requeue(requests []*matchRequest) {
+	var requeued []*matchRequest
+	for _, request := range requests {
+		if m.controller.db.GamesDAO.IsPlayerInActiveGame(request.user.ID) {
+			m.sender.BroadcastMessageToUsers([]primitive.ObjectID{request.user.ID}, websocket.NewWSMessageMatchFailed(false))
+			continue
+		}
+		requeued = append(requeued, request)
+	}
+
+	m.mtx.Lock()
+	queued := make(map[primitive.ObjectID]bool)
+	for _, request := range m.queue {
+		queued[request.user.ID] = true
+	}
+	var head []*matchRequest
+	for _, request := range requeued {
+		if !queued[request.user.ID] {
+			head = append(head, request)
+		}
+	}
+	m.queue = append(head, m.queue...)
+	m.mtx.Unlock()
+
+	for _, request := range requeued {
+		m.sender.BroadcastMessageToUsers([]primitive.ObjectID{request.user.ID}, websocket.NewWSMessageMatchFailed(true))
+	}
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
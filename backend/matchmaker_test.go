@@ -0,0 +1,161 @@
+package backend
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestRequest(players int, queuedAt time.Time) *matchRequest {
+	return &matchRequest{
+		user:     &models.User{ID: primitive.NewObjectID()},
+		players:  players,
+		ruleset:  DefaultRuleset,
+		rating:   1500,
+		queuedAt: queuedAt,
+	}
+}
+
+func Test_FindMatchesGroupsCompatibleRequests(t *testing.T) {
+	now := time.Now()
+	queue := []*matchRequest{
+		newTestRequest(2, now),
+		newTestRequest(3, now),
+		newTestRequest(2, now),
+		newTestRequest(3, now),
+	}
+	matches, waiting := findMatches(queue, now, time.Minute)
+	if len(matches) != 1 || matches[0].requests[0] != queue[0] || matches[0].requests[1] != queue[2] {
+		t.Errorf("Should have matched the two users asking for 2 players, got %v", matches)
+	}
+	if len(waiting) != 2 || waiting[0] != queue[1] || waiting[1] != queue[3] {
+		t.Errorf("Users asking for 3 players should keep waiting")
+	}
+}
+
+func Test_FindMatchesFillsWithBotsAfterTimeout(t *testing.T) {
+	now := time.Now()
+	queue := []*matchRequest{
+		newTestRequest(4, now.Add(-time.Minute)),
+		newTestRequest(4, now),
+	}
+	if matches, _ := findMatches(queue, now, 2*time.Minute); len(matches) != 0 {
+		t.Errorf("Shouldn't fill with bots before the timeout")
+	}
+	matches, waiting := findMatches(queue, now, 30*time.Second)
+	if len(matches) != 1 || len(matches[0].requests) != 2 || matches[0].bots != 2 || len(waiting) != 0 {
+		t.Errorf("Should have filled the match with 2 bots, got %v", matches)
+	}
+}
+
+func Test_FindMatchesRespectsRatingWindow(t *testing.T) {
+	now := time.Now()
+	strong := newTestRequest(2, now)
+	strong.rating = 2200
+	queue := []*matchRequest{newTestRequest(2, now), strong}
+	if matches, _ := findMatches(queue, now, time.Hour); len(matches) != 0 {
+		t.Errorf("Users far apart in rating shouldn't be matched right away")
+	}
+	queue[0].queuedAt = now.Add(-time.Minute)
+	if matches, _ := findMatches(queue, now, time.Hour); len(matches) != 1 {
+		t.Errorf("Rating window should widen as the user waits")
+	}
+}
+
+func Test_FindMatchesGroupsByRuleset(t *testing.T) {
+	now := time.Now()
+	other := newTestRequest(2, now)
+	other.ruleset = "house"
+	queue := []*matchRequest{newTestRequest(2, now), other}
+	if matches, waiting := findMatches(queue, now, time.Hour); len(matches) != 0 || len(waiting) != 2 {
+		t.Errorf("Users asking for different rulesets shouldn't be matched, got %v", matches)
+	}
+}
+
+func Test_QuickMatchRulesets(t *testing.T) {
+	if rules, ok := quickMatchRulesets[DefaultRuleset]; !ok || !reflect.DeepEqual(rules, engine.DefaultRuleset()) {
+		t.Errorf("Quick matches should be played by the default rules unless asked otherwise, got %v", rules)
+	}
+	if err := (&Matchmaker{}).Enqueue(&models.User{}, 2, "unknown"); !errors.Is(err, ErrUnknownRuleset) {
+		t.Errorf("Unknown rulesets should be rejected, got %v", err)
+	}
+}
+
+// recordingSender records the messages sent to every user
+type recordingSender struct {
+	mtx      sync.Mutex
+	messages map[primitive.ObjectID][]interface{}
+}
+
+func (s *recordingSender) BroadcastMessageToUsers(users []primitive.ObjectID, message interface{}) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, user := range users {
+		s.messages[user] = append(s.messages[user], message)
+	}
+}
+
+func Test_FailedMatchIsDeletedAndRequeued(t *testing.T) {
+	f := newControllerFixture(t, 2)
+	db, controller, user1, user2 := f.db, f.controller, f.users[0], f.users[1]
+	sender := &recordingSender{messages: make(map[primitive.ObjectID][]interface{})}
+	controller.sender = sender
+	matchmaker := NewMatchmaker(controller, sender, time.Minute)
+
+	// user2 created a game of his own after being matched, his join fails
+	if _, err := controller.NewGame(user2, "game1", 2, "", GameOptions{}); err != nil {
+		t.Fatalf("Failed creating game, %v", err)
+	}
+	queuedAt := time.Now().Add(-time.Minute)
+	request1, request2 := newTestRequest(2, queuedAt), newTestRequest(2, queuedAt)
+	request1.user, request2.user = user1, user2
+	if err := matchmaker.startMatch(match{requests: []*matchRequest{request1, request2}}); err == nil {
+		t.Fatalf("Match with a user already playing should fail")
+	}
+
+	if db.GamesDAO.IsPlayerInActiveGame(user1.ID) {
+		t.Errorf("The failed match's game should be deleted")
+	}
+	if len(matchmaker.queue) != 1 || matchmaker.queue[0] != request1 {
+		t.Errorf("Only the user still free to play should be requeued, got %v", matchmaker.queue)
+	}
+	expected := map[primitive.ObjectID]websocket.WSMessageMatchFailed{
+		user1.ID: websocket.NewWSMessageMatchFailed(true),
+		user2.ID: websocket.NewWSMessageMatchFailed(false),
+	}
+	for userID, failed := range expected {
+		messages := sender.messages[userID]
+		if len(messages) == 0 || messages[len(messages)-1] != failed {
+			t.Errorf("User %v should be told the match failed with %v, got %v", userID.Hex(), failed, messages)
+		}
+	}
+}
+
+func Test_QuickMatchGamesAreUnlisted(t *testing.T) {
+	f := newControllerFixture(t, 3)
+	sender := &recordingSender{messages: make(map[primitive.ObjectID][]interface{})}
+	matchmaker := NewMatchmaker(f.controller, sender, time.Minute)
+	queuedAt := time.Now().Add(-time.Minute)
+	request1, request2 := newTestRequest(2, queuedAt), newTestRequest(2, queuedAt)
+	request1.user, request2.user = f.users[0], f.users[1]
+	if err := matchmaker.startMatch(match{requests: []*matchRequest{request1, request2}}); err != nil {
+		t.Fatalf("Failed starting match, %v", err)
+	}
+	stored, _ := f.db.GamesDAO.FetchActiveGameOfPlayer(f.users[0].ID)
+	if stored == nil || stored.GameVisibility() != models.GameVisibilityUnlisted {
+		t.Fatalf("Quick match game should be unlisted, got %v", stored)
+	}
+	games, _ := f.controller.ListGames(f.users[2])
+	for _, game := range games {
+		if game.ID == stored.ID {
+			t.Errorf("Quick match game shouldn't be listed to other users")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/rating"
+)
+
+// RatingPolicy decides which games are rated
+type RatingPolicy struct {
+	// IncludeBotGames rate games that had bots seated, bots themselves are never rated
+	IncludeBotGames bool
+}
+
+// SetRatingPolicy replaces the policy deciding which games are rated
+func (g *GameController) SetRatingPolicy(policy RatingPolicy) {
+	g.gameMtx.Lock()
+	defer g.gameMtx.Unlock()
+	g.ratingPolicy = policy
+}
+
+// updateRatings updates the rating of every human player of an ended rated game from its final scores
+func (g *GameController) updateRatings(game *models.KabooGame, scores []int) error {
+	g.gameMtx.Lock()
+	policy := g.ratingPolicy
+	g.gameMtx.Unlock()
+	if !game.IsRated(policy.IncludeBotGames) {
+		return nil
+	}
+	ratings := make([]float64, len(scores))
+	for seat := range ratings {
+		ratings[seat] = rating.DefaultRating
+	}
+	for seat, userID := range game.Players {
+		user, err := g.db.UserDAO.FetchUserByID(userID)
+		if err != nil {
+			return err
+		}
+		ratings[seat] = user.CurrentRating()
+	}
+	updated := rating.Update(ratings, scores)
+	now := time.Now()
+	for seat, userID := range game.Players {
+		change := models.RatingChange{GameID: game.ID, Before: ratings[seat], After: updated[seat], At: now}
+		if err := g.db.UserDAO.ApplyRatingChange(userID, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ngutman/kaboo-server-go/bots"
+	"github.com/ngutman/kaboo-server-go/engine"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// gameLog where a session appends its actions and events
+type gameLog interface {
+	AppendEntry(entry *models.GameLogEntry) error
+}
+
+// gameSession a running game, binds the engine seats to the game players.
+// Every applied action is appended to the game's event log.
+type gameSession struct {
+	game     *models.KabooGame
+	engine   *engine.Game
+	seats    []primitive.ObjectID
+	bots     map[int]bots.Bot
+	eventLog gameLog
+	feed     *spectatorFeed
+	mtx      sync.Mutex
+	// version of the last update sent to the players
+	version int
+	// views the last view sent to each human seat, nil until the seat got a snapshot
+	views []*engine.PlayerView
+	// updatesMtx orders the updates sent to the players, taken before mtx
+	updatesMtx sync.Mutex
+	// seq sequence number of the next log entry
+	seq int
+	// abandoned set once a player left the game, no more actions are applied
+	abandoned bool
+}
+
+// newGameSession deals a new engine game for the given game, returning the deal events
+func newGameSession(game *models.KabooGame, eventLog *models.GameEventsDAO) (*gameSession, []engine.Event, error) {
+	eng, events, err := engine.NewGame(game.ShuffleSeed(), len(game.Seats()), game.Ruleset())
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := buildGameSession(game, eng, eventLog)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := session.commit(nil, events); err != nil {
+		return nil, nil, err
+	}
+	botEvents, err := session.playBots()
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, append(events, botEvents...), nil
+}
+
+// restoreGameSession rebuilds a running game from its seed and event log, returning the events
+// of the bot turns played since the server may have stopped before the bots finished their turns
+func restoreGameSession(game *models.KabooGame, eventLog *models.GameEventsDAO) (*gameSession, []engine.Event, error) {
+	entries, err := eventLog.FetchGameLog(game.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	eng, events, err := engine.Replay(game.ShuffleSeed(), len(game.Seats()), game.Ruleset(), models.LogActions(entries))
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := buildGameSession(game, eng, eventLog)
+	if err != nil {
+		return nil, nil, err
+	}
+	session.seq = len(entries)
+	session.observe(events)
+	botEvents, err := session.playBots()
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, botEvents, nil
+}
+
+func buildGameSession(game *models.KabooGame, eng *engine.Game, eventLog *models.GameEventsDAO) (*gameSession, error) {
+	seats := game.Seats()
+	session := &gameSession{
+		game:     game,
+		engine:   eng,
+		seats:    seats,
+		bots:     make(map[int]bots.Bot),
+		eventLog: eventLog,
+		views:    make([]*engine.PlayerView, len(game.Players)),
+	}
+	for i, bot := range game.Bots {
+		seat := len(game.Players) + i
+		b, err := bots.New(bots.Difficulty(bot.Difficulty), seat, len(seats), game.Ruleset(), bots.SeedFor(game.Seed, seat))
+		if err != nil {
+			return nil, err
+		}
+		session.bots[seat] = b
+	}
+	return session, nil
+}
+
+// seatOf returns the seat of the given user
+func (s *gameSession) seatOf(userID primitive.ObjectID) (int, bool) {
+	for seat, id := range s.seats {
+		if id == userID {
+			return seat, true
+		}
+	}
+	return 0, false
+}
+
+// apply applies a human player's action and lets the bots play until it's a
+// human's turn again, returning every resulting event. When a bot fails the events
+// committed before it are returned along with the error
+func (s *gameSession) apply(action engine.Action) ([]engine.Event, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.abandoned {
+		return nil, ErrGameNotRunning
+	}
+	events, err := s.engine.Apply(action)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.commit(&action, events); err != nil {
+		return nil, err
+	}
+	botEvents, err := s.playBots()
+	return append(events, botEvents...), err
+}
+
+// playBots plays the bots' turns until it's a human's turn or the game is over. An error means
+// a bot couldn't act, which leaves the game stuck on the bot's turn, the events of the turns
+// committed before it are returned along with it
+func (s *gameSession) playBots() ([]engine.Event, error) {
+	var events []engine.Event
+	for !s.engine.IsOver() {
+		// Bots may snap out of turn, even before a human's turn
+		action, snapped := bots.OutOfTurnSnap(s.engine, func(seat int) bots.Bot { return s.bots[seat] })
+		seat := action.Seat
+		if !snapped {
+			seat = s.engine.CurrentSeat()
+			bot, ok := s.bots[seat]
+			if !ok {
+				break
+			}
+			var err error
+			if action, err = bot.Act(s.engine.View(seat), s.engine.LegalActions(seat)); err != nil {
+				return events, fmt.Errorf("Bot at seat %d of game %v can't act, %w", seat, s.game.ID.Hex(), err)
+			}
+		}
+		botEvents, err := s.engine.Apply(action)
+		if err != nil {
+			return events, fmt.Errorf("Bot at seat %d of game %v made an illegal move, %w", seat, s.game.ID.Hex(), err)
+		}
+		if err := s.commit(&action, botEvents); err != nil {
+			return events, err
+		}
+		events = append(events, botEvents...)
+	}
+	return events, nil
+}
+
+// commit records an action the engine applied, the deal being recorded with no action. The action
+// only counts once its log entry is written so the seed and the log always rebuild the game, when
+// writing fails the engine is rebuilt without it and the bots never observe it
+func (s *gameSession) commit(action *engine.Action, events []engine.Event) error {
+	err := s.eventLog.AppendEntry(&models.GameLogEntry{
+		GameID: s.game.ID,
+		Seq:    s.seq,
+		Action: action,
+		Events: events,
+	})
+	if err != nil {
+		if action != nil {
+			s.rollback()
+		}
+		return err
+	}
+	s.seq++
+	s.observe(events)
+	return nil
+}
+
+// rollback rebuilds the engine without its last action
+func (s *gameSession) rollback() {
+	history := s.engine.History()
+	eng, _, err := engine.Replay(s.game.ShuffleSeed(), len(s.seats), s.game.Ruleset(), history[:len(history)-1])
+	if err != nil {
+		log.Errorf("Failed rolling back game %v, %v\n", s.game.ID.Hex(), err)
+		return
+	}
+	s.engine = eng
+}
+
+func (s *gameSession) observe(events []engine.Event) {
+	for seat, bot := range s.bots {
+		for _, e := range events {
+			bot.Observe(e.Masked(seat))
+		}
+	}
+}
+
+// playerUpdate the message a human seat receives for a batch of events, a delta from the last
+// view sent to the seat or a snapshot when the seat wasn't sent any yet. Called holding both locks
+func (s *gameSession) playerUpdate(seat int, events []engine.Event) interface{} {
+	view := s.engine.View(seat)
+	prev := s.views[seat]
+	s.views[seat] = &view
+	if prev == nil {
+		return websocket.NewWSMessageGameSnapshot(s.game, s.version, s.engine.Snapshot(seat))
+	}
+	return websocket.NewWSMessageGameDelta(s.game, s.version, engine.MaskEvents(events, seat), engine.Diff(*prev, view))
+}
@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ngutman/kaboo-server-go/bots"
+	"github.com/ngutman/kaboo-server-go/engine"
+	"github.com/ngutman/kaboo-server-go/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stuckBot a bot that never finds an action
+type stuckBot struct{}
+
+func (stuckBot) Observe(e engine.Event) {}
+
+func (stuckBot) Act(view engine.PlayerView, legal []engine.Action) (engine.Action, error) {
+	return engine.Action{}, bots.ErrNoLegalAction
+}
+
+func (stuckBot) Snap(view engine.PlayerView, legal []engine.Action) (engine.Action, bool) {
+	return engine.Action{}, false
+}
+
+func Test_StuckBotReturnsError(t *testing.T) {
+	eng, _, err := engine.NewGame("seed", 2, engine.DefaultRuleset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &gameSession{
+		game:   &models.KabooGame{ID: primitive.NewObjectID()},
+		engine: eng,
+		bots:   map[int]bots.Bot{eng.CurrentSeat(): stuckBot{}},
+	}
+	if _, err := session.playBots(); !errors.Is(err, bots.ErrNoLegalAction) {
+		t.Errorf("A bot that can't act should be reported, got %v", err)
+	}
+}
+
+// memoryLog a game log kept in memory, failing writes while broken is set
+type memoryLog struct {
+	entries []*models.GameLogEntry
+	broken  bool
+}
+
+func (l *memoryLog) AppendEntry(entry *models.GameLogEntry) error {
+	if l.broken {
+		return errors.New("write failed")
+	}
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func Test_UnloggedActionIsRolledBack(t *testing.T) {
+	game := &models.KabooGame{ID: primitive.NewObjectID(), Seed: "seed", Players: []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}}
+	eng, events, err := engine.NewGame(game.ShuffleSeed(), 2, game.Ruleset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventLog := &memoryLog{}
+	session := &gameSession{game: game, engine: eng, seats: game.Seats(), eventLog: eventLog}
+	if err := session.commit(nil, events); err != nil {
+		t.Fatal(err)
+	}
+
+	seat := eng.CurrentSeat()
+	action := engine.Action{Type: engine.ActionDrawFromDeck, Seat: seat}
+	eventLog.broken = true
+	if _, err := session.apply(action); err == nil {
+		t.Fatalf("Failing to log the action should fail it")
+	}
+	if len(session.engine.History()) != 0 || session.engine.Phase() != engine.PhaseTurnStart {
+		t.Errorf("Unlogged action should be rolled back, history %v", session.engine.History())
+	}
+
+	eventLog.broken = false
+	if _, err := session.apply(action); err != nil {
+		t.Fatalf("Action should be applied once the log works, %v", err)
+	}
+	for seq, entry := range eventLog.entries {
+		if entry.Seq != seq {
+			t.Errorf("Log entry %d has sequence %d", seq, entry.Seq)
+		}
+	}
+	replayed, _, err := engine.Replay(game.ShuffleSeed(), 2, game.Ruleset(), models.LogActions(eventLog.entries))
+	if err != nil || !reflect.DeepEqual(replayed.View(seat), session.engine.View(seat)) {
+		t.Errorf("The log should rebuild the game, %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// spectatorFeed delivers a game's public messages to its spectators, delayed
+// by the game's spectator delay. Messages are delivered in order.
+type spectatorFeed struct {
+	game   *models.KabooGame
+	sender MessageSender
+	// gameMtx the controller's lock guarding the game's spectators
+	gameMtx sync.Locker
+
+	mtx     sync.Mutex
+	pending []spectatorMessage
+	closed  bool
+	// wake signals run that a message was queued or the feed was closed
+	wake chan struct{}
+}
+
+type spectatorMessage struct {
+	due     time.Time
+	message interface{}
+}
+
+func newSpectatorFeed(game *models.KabooGame, sender MessageSender, gameMtx sync.Locker) *spectatorFeed {
+	feed := &spectatorFeed{
+		game:    game,
+		sender:  sender,
+		gameMtx: gameMtx,
+		wake:    make(chan struct{}, 1),
+	}
+	go feed.run()
+	return feed
+}
+
+// publish queues a message for the spectators without blocking, it must not contain hidden information
+func (f *spectatorFeed) publish(message interface{}) {
+	f.mtx.Lock()
+	if f.closed {
+		f.mtx.Unlock()
+		return
+	}
+	f.pending = append(f.pending, spectatorMessage{due: time.Now().Add(f.game.SpectatorSettings.Delay()), message: message})
+	f.mtx.Unlock()
+	f.signal()
+}
+
+// close stops the feed once every queued message was delivered, closing it again does nothing
+func (f *spectatorFeed) close() {
+	f.mtx.Lock()
+	f.closed = true
+	f.mtx.Unlock()
+	f.signal()
+}
+
+func (f *spectatorFeed) signal() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (f *spectatorFeed) run() {
+	for {
+		f.mtx.Lock()
+		if len(f.pending) == 0 {
+			closed := f.closed
+			f.mtx.Unlock()
+			if closed {
+				return
+			}
+			<-f.wake
+			continue
+		}
+		m := f.pending[0]
+		f.pending = f.pending[1:]
+		f.mtx.Unlock()
+
+		time.Sleep(time.Until(m.due))
+		if spectators := f.spectators(); len(spectators) > 0 {
+			f.sender.BroadcastMessageToUsers(spectators, m.message)
+		}
+	}
+}
+
+// spectators copies the game's spectators under the controller's lock
+func (f *spectatorFeed) spectators() []primitive.ObjectID {
+	f.gameMtx.Lock()
+	defer f.gameMtx.Unlock()
+	return append([]primitive.ObjectID(nil), f.game.Spectators...)
+}
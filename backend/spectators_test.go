@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// blockedSender records broadcasts, each one waits until release is closed
+type blockedSender struct {
+	release  chan struct{}
+	mtx      sync.Mutex
+	messages []interface{}
+}
+
+func (s *blockedSender) BroadcastMessageToUsers(users []primitive.ObjectID, message interface{}) {
+	<-s.release
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+func Test_SpectatorFeedDoesntBlockPublishers(t *testing.T) {
+	game := &models.KabooGame{Spectators: []primitive.ObjectID{primitive.NewObjectID()}}
+	sender := &blockedSender{release: make(chan struct{})}
+	feed := newSpectatorFeed(game, sender, &sync.Mutex{})
+
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			feed.publish(i)
+		}
+		feed.close()
+		feed.close()
+		feed.publish(-1)
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Publishing shouldn't wait for the spectators")
+	}
+
+	close(sender.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sender.mtx.Lock()
+		delivered := len(sender.messages)
+		sender.mtx.Unlock()
+		if delivered == 1000 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	sender.mtx.Lock()
+	defer sender.mtx.Unlock()
+	if len(sender.messages) != 1000 {
+		t.Fatalf("Every message published before closing should be delivered, got %d", len(sender.messages))
+	}
+	for i, message := range sender.messages {
+		if message != i {
+			t.Fatalf("Messages should be delivered in order, got %v at %d", message, i)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package bots implements computer players. Bots act through the same engine
+// actions as human players and only ever see events masked for their seat.
+package bots
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+)
+
+// Difficulty bot playing strategy
+type Difficulty string
+
+// Bot difficulties
+const (
+	// DifficultyRandom plays a random legal action
+	DifficultyRandom Difficulty = "random"
+	// DifficultyMemory remembers peeked cards and calls Kaboo on a known low hand
+	DifficultyMemory Difficulty = "memory"
+	// DifficultyExpected estimates unknown cards and calls Kaboo when it expects to win
+	DifficultyExpected Difficulty = "expected"
+)
+
+var (
+	// ErrUnknownDifficulty no such bot difficulty
+	ErrUnknownDifficulty = errors.New("Unknown bot difficulty")
+
+	// ErrNoLegalAction the bot was asked to act without any legal action
+	ErrNoLegalAction = errors.New("No legal action")
+)
+
+// Bot plays a single seat
+type Bot interface {
+	// Observe is called with every game event, masked for the bot's seat
+	Observe(e engine.Event)
+	// Act chooses one of the legal actions given the bot's view of the game, returns
+	// ErrNoLegalAction when there's none
+	Act(view engine.PlayerView, legal []engine.Action) (engine.Action, error)
+	// Snap is asked at the start of every other seat's turn whether the bot snaps out of turn,
+	// legal holds the bot's snap actions. ok is false when the bot doesn't snap
+	Snap(view engine.PlayerView, legal []engine.Action) (action engine.Action, ok bool)
+}
+
+// Difficulties returns every supported difficulty
+func Difficulties() []Difficulty {
+	return []Difficulty{DifficultyRandom, DifficultyMemory, DifficultyExpected}
+}
+
+// IsValidDifficulty returns whether the given difficulty is supported
+func IsValidDifficulty(difficulty Difficulty) bool {
+	for _, d := range Difficulties() {
+		if d == difficulty {
+			return true
+		}
+	}
+	return false
+}
+
+// New creates a bot for the given seat playing by the given rules, seed drives the bot's own random choices
+func New(difficulty Difficulty, seat int, players int, rules engine.Ruleset, seed int64) (Bot, error) {
+	rng := rand.New(rand.NewSource(seed))
+	switch difficulty {
+	case DifficultyRandom:
+		return &randomBot{rng: rng}, nil
+	case DifficultyMemory:
+		return &memoryBot{mem: newMemory(seat, players, rules), rng: rng}, nil
+	case DifficultyExpected:
+		return &memoryBot{mem: newMemory(seat, players, rules), rng: rng, expected: true}, nil
+	}
+	return nil, ErrUnknownDifficulty
+}
+
+// SeedFor derives the random seed of the bot sitting at seat from the game seed
+func SeedFor(gameSeed string, seat int) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(gameSeed + ":" + strconv.Itoa(seat)))
+	return int64(h.Sum64())
+}
+
+type randomBot struct {
+	rng *rand.Rand
+}
+
+func (b *randomBot) Observe(e engine.Event) {}
+
+func (b *randomBot) Act(view engine.PlayerView, legal []engine.Action) (engine.Action, error) {
+	return pickRandom(b.rng, legal)
+}
+
+// Snap never snaps out of turn, random snaps would only collect penalties
+func (b *randomBot) Snap(view engine.PlayerView, legal []engine.Action) (engine.Action, bool) {
+	return engine.Action{}, false
+}
+
+// OutOfTurnSnap offers a snap to the bot of every seat but the current one, in turn order, and
+// returns the first snap made. botAt returns nil for seats not played by a bot
+func OutOfTurnSnap(g *engine.Game, botAt func(seat int) Bot) (engine.Action, bool) {
+	current := g.CurrentSeat()
+	for i := 1; i < g.Players(); i++ {
+		seat := (current + i) % g.Players()
+		bot := botAt(seat)
+		if bot == nil {
+			continue
+		}
+		legal := g.LegalActions(seat)
+		if len(legal) == 0 {
+			continue
+		}
+		if action, ok := bot.Snap(g.View(seat), legal); ok {
+			return action, true
+		}
+	}
+	return engine.Action{}, false
+}
+
+// pickRandom returns a random legal action
+func pickRandom(rng *rand.Rand, legal []engine.Action) (engine.Action, error) {
+	if len(legal) == 0 {
+		return engine.Action{}, ErrNoLegalAction
+	}
+	return legal[rng.Intn(len(legal))], nil
+}
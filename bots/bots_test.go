@@ -0,0 +1,85 @@
+package bots
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+)
+
+func Test_BotsPlayFullGames(t *testing.T) {
+	houseRules := engine.DefaultRuleset()
+	houseRules.HandSize, houseRules.Jokers, houseRules.Snapping = 5, false, true
+	for _, rules := range []engine.Ruleset{engine.DefaultRuleset(), houseRules} {
+		playFullGames(t, rules)
+	}
+}
+
+func playFullGames(t *testing.T, rules engine.Ruleset) {
+	for _, difficulty := range Difficulties() {
+		for i := 0; i < 10; i++ {
+			g, events, _ := engine.NewGame(fmt.Sprintf("%s-%d", difficulty, i), 3, rules)
+			players := make([]Bot, g.Players())
+			for seat := range players {
+				players[seat], _ = New(difficulty, seat, g.Players(), g.Rules(), int64(i))
+			}
+			for !g.IsOver() {
+				for seat, bot := range players {
+					for _, e := range events {
+						bot.Observe(e.Masked(seat))
+					}
+				}
+				seat := g.CurrentSeat()
+				action, err := players[seat].Act(g.View(seat), g.LegalActions(seat))
+				if err != nil {
+					t.Fatalf("Bot %v didn't act, %v", difficulty, err)
+				}
+				if events, err = g.Apply(action); err != nil {
+					t.Fatalf("Bot %v made an illegal move %v, %v", difficulty, action, err)
+				}
+			}
+		}
+	}
+}
+
+func Test_SmarterBotsBeatRandom(t *testing.T) {
+	wins := map[Difficulty]int{}
+	for i := 0; i < 100; i++ {
+		difficulties := []Difficulty{DifficultyRandom, DifficultyExpected}
+		if i%2 == 1 {
+			difficulties[0], difficulties[1] = difficulties[1], difficulties[0]
+		}
+		g, events, _ := engine.NewGame(fmt.Sprintf("duel-%d", i), 2, engine.DefaultRuleset())
+		players := make([]Bot, 2)
+		for seat := range players {
+			players[seat], _ = New(difficulties[seat], seat, 2, g.Rules(), int64(i))
+		}
+		for !g.IsOver() {
+			for seat, bot := range players {
+				for _, e := range events {
+					bot.Observe(e.Masked(seat))
+				}
+			}
+			seat := g.CurrentSeat()
+			action, _ := players[seat].Act(g.View(seat), g.LegalActions(seat))
+			events, _ = g.Apply(action)
+		}
+		for _, winner := range g.Winners() {
+			wins[difficulties[winner]]++
+		}
+	}
+	if wins[DifficultyExpected] <= wins[DifficultyRandom] {
+		t.Errorf("Expected value bot should beat random play, wins %v", wins)
+	}
+}
+
+func Test_NoLegalAction(t *testing.T) {
+	g, _, _ := engine.NewGame("seed", 2, engine.DefaultRuleset())
+	for _, difficulty := range Difficulties() {
+		bot, _ := New(difficulty, 0, 2, g.Rules(), 1)
+		if _, err := bot.Act(g.View(0), nil); !errors.Is(err, ErrNoLegalAction) {
+			t.Errorf("Bot %v should report having no legal action, got %v", difficulty, err)
+		}
+	}
+}
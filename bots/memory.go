@@ -0,0 +1,108 @@
+package bots
+
+import "github.com/ngutman/kaboo-server-go/engine"
+
+// memory what a seat legitimately knows, rebuilt from masked events
+type memory struct {
+	seat    int
+	rules   engine.Ruleset
+	hands   [][]*engine.Card
+	discard []engine.Card
+	looked  []*engine.Card
+}
+
+func newMemory(seat int, players int, rules engine.Ruleset) *memory {
+	m := &memory{seat: seat, rules: rules, hands: make([][]*engine.Card, players)}
+	m.reset()
+	return m
+}
+
+func (m *memory) reset() {
+	for seat := range m.hands {
+		m.hands[seat] = make([]*engine.Card, m.rules.HandSize)
+	}
+	m.discard = nil
+	m.looked = nil
+}
+
+func (m *memory) observe(e engine.Event) {
+	switch e.Type {
+	case engine.EventDeal:
+		m.reset()
+		m.discard = append(m.discard, *e.Card)
+	case engine.EventTurn:
+		m.looked = nil
+	case engine.EventPeek:
+		if e.Card != nil {
+			m.hands[e.TargetSeat][e.TargetSlot] = e.Card
+			if e.Seat == m.seat {
+				m.looked = append(m.looked, e.Card)
+			}
+		}
+	case engine.EventDraw:
+		if e.Pile == engine.PileDiscard && len(m.discard) > 0 {
+			m.discard = m.discard[:len(m.discard)-1]
+		}
+	case engine.EventReplace:
+		m.hands[e.Seat][e.Slot] = e.Card
+		m.discard = append(m.discard, *e.Discarded)
+	case engine.EventDiscard:
+		m.discard = append(m.discard, *e.Card)
+	case engine.EventSwap:
+		hand, target := m.hands[e.Seat], m.hands[e.TargetSeat]
+		hand[e.Slot], target[e.TargetSlot] = target[e.TargetSlot], hand[e.Slot]
+	case engine.EventSnap:
+		if e.Penalty > 0 {
+			m.hands[e.Seat][e.Slot] = e.Card
+			break
+		}
+		hand := m.hands[e.Seat]
+		m.hands[e.Seat] = append(hand[:e.Slot:e.Slot], hand[e.Slot+1:]...)
+		m.discard = append(m.discard, *e.Card)
+	case engine.EventReshuffle:
+		if len(m.discard) > 0 {
+			m.discard = m.discard[len(m.discard)-1:]
+		}
+	}
+}
+
+// unseenMean average value of the cards this seat hasn't seen
+func (m *memory) unseenMean(drawn *engine.Card) float64 {
+	total, count := 0, 0
+	for _, card := range engine.NewDeck(m.rules.Jokers) {
+		total += card.Value()
+		count++
+	}
+	seen := append([]engine.Card(nil), m.discard...)
+	if drawn != nil {
+		seen = append(seen, *drawn)
+	}
+	for _, hand := range m.hands {
+		for _, card := range hand {
+			if card != nil {
+				seen = append(seen, *card)
+			}
+		}
+	}
+	for _, card := range seen {
+		total -= card.Value()
+		count--
+	}
+	if count <= 0 {
+		return averageCardValue
+	}
+	return float64(total) / float64(count)
+}
+
+// handEstimate expected hand value of seat, unknown cards are worth unknown
+func (m *memory) handEstimate(seat int, unknown float64) (estimate float64, known int) {
+	for _, card := range m.hands[seat] {
+		if card == nil {
+			estimate += unknown
+		} else {
+			estimate += float64(card.Value())
+			known++
+		}
+	}
+	return
+}
@@ -0,0 +1,217 @@
+package bots
+
+import (
+	"math/rand"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+)
+
+const (
+	// averageCardValue mean value of a full deck, used when nothing better is known
+	averageCardValue = 6.5
+	// memoryKabooThreshold memory bots call Kaboo once their fully known hand is at most this
+	memoryKabooThreshold = 5
+	// expectedKabooMargin expected bots call Kaboo when leading every opponent by this margin
+	expectedKabooMargin = 3.0
+	// expectedKabooCeiling expected bots never call Kaboo on a hand expected above this
+	expectedKabooCeiling = 12.0
+)
+
+// memoryBot keeps track of every card it saw. When expected is set unknown
+// cards are valued by the average of the cards not seen yet and Kaboo is
+// called by comparing expected hand values.
+type memoryBot struct {
+	mem      *memory
+	rng      *rand.Rand
+	expected bool
+}
+
+func (b *memoryBot) Observe(e engine.Event) {
+	b.mem.observe(e)
+}
+
+func (b *memoryBot) Act(view engine.PlayerView, legal []engine.Action) (engine.Action, error) {
+	action := b.choose(view)
+	for _, l := range legal {
+		if l == action {
+			return action, nil
+		}
+	}
+	return pickRandom(b.rng, legal)
+}
+
+// Snap snaps a known own card matching the discard pile's top card
+func (b *memoryBot) Snap(view engine.PlayerView, legal []engine.Action) (engine.Action, bool) {
+	slot, ok := b.matchingOwnCard(view.DiscardTop)
+	if !ok {
+		return engine.Action{}, false
+	}
+	action := engine.Action{Type: engine.ActionSnap, Seat: view.Seat, Slot: slot}
+	for _, l := range legal {
+		if l == action {
+			return action, true
+		}
+	}
+	return engine.Action{}, false
+}
+
+func (b *memoryBot) choose(view engine.PlayerView) engine.Action {
+	seat := view.Seat
+	unknown := b.unknownValue(view.Drawn)
+	switch view.Phase {
+	case engine.PhaseTurnStart:
+		if slot, ok := b.matchingOwnCard(view.DiscardTop); ok && b.mem.rules.Snapping && view.KabooCaller != seat {
+			return engine.Action{Type: engine.ActionSnap, Seat: seat, Slot: slot}
+		}
+		if view.KabooCaller < 0 && b.shouldCallKaboo(view, unknown) {
+			return engine.Action{Type: engine.ActionCallKaboo, Seat: seat}
+		}
+		if top := view.DiscardTop; top != nil {
+			if _, gain := b.bestReplacement(top.Value(), unknown); gain >= 2 {
+				return engine.Action{Type: engine.ActionDrawFromDiscard, Seat: seat}
+			}
+		}
+		return engine.Action{Type: engine.ActionDrawFromDeck, Seat: seat}
+	case engine.PhaseDrawn:
+		slot, gain := b.bestReplacement(view.Drawn.Value(), unknown)
+		if gain > 0 || view.DrawnFrom == engine.PileDiscard {
+			return engine.Action{Type: engine.ActionReplace, Seat: seat, Slot: slot}
+		}
+		return engine.Action{Type: engine.ActionDiscard, Seat: seat}
+	case engine.PhasePower:
+		return b.usePower(view, unknown)
+	case engine.PhaseLookDecision:
+		swap := len(b.mem.looked) == 2 && b.mem.looked[1].Value() < b.mem.looked[0].Value()
+		return engine.Action{Type: engine.ActionLookDecision, Seat: seat, Swap: swap}
+	}
+	return engine.Action{}
+}
+
+func (b *memoryBot) usePower(view engine.PlayerView, unknown float64) engine.Action {
+	seat := view.Seat
+	skip := engine.Action{Type: engine.ActionSkipPower, Seat: seat}
+	switch view.Power {
+	case engine.PowerPeek:
+		for slot, card := range b.mem.hands[seat] {
+			if card == nil {
+				return engine.Action{Type: engine.ActionPeek, Seat: seat, Slot: slot}
+			}
+		}
+	case engine.PowerSpy:
+		if target, targetSlot, ok := b.unknownOpponentCard(view); ok {
+			return engine.Action{Type: engine.ActionSpy, Seat: seat, TargetSeat: target, TargetSlot: targetSlot}
+		}
+	case engine.PowerBlindSwap:
+		slot, worst := b.worstOwnCard(unknown)
+		if target, targetSlot, value, ok := b.lowestKnownOpponentCard(view); ok && value < worst {
+			return engine.Action{Type: engine.ActionBlindSwap, Seat: seat, Slot: slot, TargetSeat: target, TargetSlot: targetSlot}
+		}
+		if b.mem.hands[seat][slot] != nil && worst > unknown+2 {
+			if target, targetSlot, ok := b.unknownOpponentCard(view); ok {
+				return engine.Action{Type: engine.ActionBlindSwap, Seat: seat, Slot: slot, TargetSeat: target, TargetSlot: targetSlot}
+			}
+		}
+	case engine.PowerLookAndSwap:
+		slot, _ := b.worstOwnCard(unknown)
+		target, targetSlot, _, ok := b.lowestKnownOpponentCard(view)
+		if !ok {
+			target, targetSlot, ok = b.unknownOpponentCard(view)
+		}
+		if ok {
+			return engine.Action{Type: engine.ActionLook, Seat: seat, Slot: slot, TargetSeat: target, TargetSlot: targetSlot}
+		}
+	}
+	return skip
+}
+
+func (b *memoryBot) shouldCallKaboo(view engine.PlayerView, unknown float64) bool {
+	own, known := b.mem.handEstimate(view.Seat, unknown)
+	if !b.expected {
+		return known == len(b.mem.hands[view.Seat]) && own <= memoryKabooThreshold
+	}
+	if own > expectedKabooCeiling {
+		return false
+	}
+	for seat := range b.mem.hands {
+		if seat == view.Seat {
+			continue
+		}
+		if estimate, _ := b.mem.handEstimate(seat, unknown); estimate-own < expectedKabooMargin {
+			return false
+		}
+	}
+	return true
+}
+
+// matchingOwnCard returns a known own card of the same rank as the discard pile's top card
+func (b *memoryBot) matchingOwnCard(top *engine.Card) (slot int, ok bool) {
+	if top == nil {
+		return 0, false
+	}
+	for slot, card := range b.mem.hands[b.mem.seat] {
+		if card != nil && card.Rank == top.Rank {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+func (b *memoryBot) unknownValue(drawn *engine.Card) float64 {
+	if b.expected {
+		return b.mem.unseenMean(drawn)
+	}
+	return averageCardValue
+}
+
+// bestReplacement returns the own slot where a card of the given value gains the most
+func (b *memoryBot) bestReplacement(value int, unknown float64) (slot int, gain float64) {
+	gain = -1 << 31
+	for s, card := range b.mem.hands[b.mem.seat] {
+		current := unknown
+		if card != nil {
+			current = float64(card.Value())
+		}
+		if g := current - float64(value); g > gain {
+			slot, gain = s, g
+		}
+	}
+	return
+}
+
+func (b *memoryBot) worstOwnCard(unknown float64) (slot int, value float64) {
+	slot, gain := b.bestReplacement(0, unknown)
+	return slot, gain
+}
+
+func (b *memoryBot) lowestKnownOpponentCard(view engine.PlayerView) (seat, slot int, value float64, ok bool) {
+	for target, hand := range b.mem.hands {
+		if target == view.Seat || target == view.KabooCaller {
+			continue
+		}
+		for s, card := range hand {
+			if card != nil && (!ok || float64(card.Value()) < value) {
+				seat, slot, value, ok = target, s, float64(card.Value()), true
+			}
+		}
+	}
+	return
+}
+
+func (b *memoryBot) unknownOpponentCard(view engine.PlayerView) (seat, slot int, ok bool) {
+	var candidates [][2]int
+	for target, hand := range b.mem.hands {
+		if target == view.Seat || target == view.KabooCaller {
+			continue
+		}
+		for s, card := range hand {
+			if card == nil {
+				candidates = append(candidates, [2]int{target, s})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, 0, false
+	}
+	c := candidates[b.rng.Intn(len(candidates))]
+	return c[0], c[1], true
+}
@@ -2,10 +2,16 @@ package main
 
 // Import our dependencies. We'll use the standard HTTP library as well as the gorilla router for this app
 import (
+	"io/ioutil"
 	"os"
+	"strings"
 
+	"github.com/ngutman/kaboo-server-go/backend"
 	"github.com/ngutman/kaboo-server-go/transport"
+	"github.com/ngutman/kaboo-server-go/transport/origin"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 
 	cli "github.com/urfave/cli/v2"
 )
@@ -24,6 +30,12 @@ func main() {
 	var restPort int
 	var auth0Domain string
 	var auth0Audience string
+	var chatBlocklist string
+	var avatarDir string
+	var allowedOrigins cli.StringSlice
+	httpConfig := transport.DefaultHTTPConfig()
+	wsConfig := websocket.DefaultConfig()
+	wsCommandRate := float64(wsConfig.CommandRate)
 	app := &cli.App{
 		Name: "kaboo",
 		Flags: []cli.Flag{
@@ -37,19 +49,156 @@ func main() {
 				Name:        "auth0-domain",
 				Usage:       "Auth0 Domain (e.g. \"dev-XXXXXX.auth0.com\")",
 				Destination: &auth0Domain,
-				Required:    true,
 			},
 			&cli.StringFlag{
 				Name:        "auth0-audience",
 				Usage:       "Auth0 Audience (e.g. \"https://myapp/api/\"",
 				Destination: &auth0Audience,
-				Required:    true,
+			},
+			&cli.DurationFlag{
+				Name:        "http-read-header-timeout",
+				Value:       httpConfig.ReadHeaderTimeout,
+				Usage:       "Longest time to read request headers",
+				Destination: &httpConfig.ReadHeaderTimeout,
+			},
+			&cli.DurationFlag{
+				Name:        "http-read-timeout",
+				Value:       httpConfig.ReadTimeout,
+				Usage:       "Longest time to read a whole request, body included",
+				Destination: &httpConfig.ReadTimeout,
+			},
+			&cli.DurationFlag{
+				Name:        "http-write-timeout",
+				Value:       httpConfig.WriteTimeout,
+				Usage:       "Longest time to write a response, doesn't apply to websocket connections",
+				Destination: &httpConfig.WriteTimeout,
+			},
+			&cli.DurationFlag{
+				Name:        "http-idle-timeout",
+				Value:       httpConfig.IdleTimeout,
+				Usage:       "How long idle keep-alive connections are kept open",
+				Destination: &httpConfig.IdleTimeout,
+			},
+			&cli.StringFlag{
+				Name:        "tls-cert",
+				Usage:       "PEM certificate chain file, serves HTTPS along with --tls-key",
+				Destination: &httpConfig.TLSCertFile,
+			},
+			&cli.StringFlag{
+				Name:        "tls-key",
+				Usage:       "PEM private key file of --tls-cert",
+				Destination: &httpConfig.TLSKeyFile,
+			},
+			&cli.BoolFlag{
+				Name:        "tls-self-signed",
+				Usage:       "Serve HTTPS with a certificate generated for localhost on start, for local development",
+				Destination: &httpConfig.SelfSigned,
+			},
+			&cli.IntFlag{
+				Name:        "http-redirect-port",
+				Usage:       "Port redirecting plain HTTP requests to HTTPS (e.g. 80), requires TLS",
+				Destination: &httpConfig.RedirectPort,
+			},
+			&cli.BoolFlag{
+				Name:        "http2",
+				Value:       httpConfig.HTTP2,
+				Usage:       "Negotiate HTTP/2 with TLS clients",
+				Destination: &httpConfig.HTTP2,
+			},
+			&cli.StringSliceFlag{
+				Name:        "allowed-origin",
+				Usage:       "Origin whose pages may use the API and websocket, e.g. \"https://kaboo.example.com\" or \"https://*.example.com\", repeat for more. Only the server's own origin is allowed by default, any origin in DEBUG mode",
+				EnvVars:     []string{"KABOO_ALLOWED_ORIGINS"},
+				Destination: &allowedOrigins,
+			},
+			&cli.StringFlag{
+				Name:        "chat-blocklist",
+				Usage:       "File listing words masked in chat, one per line",
+				Destination: &chatBlocklist,
+			},
+			&cli.StringFlag{
+				Name:        "avatar-dir",
+				Value:       "avatars",
+				Usage:       "Directory uploaded avatar images are stored in",
+				Destination: &avatarDir,
+			},
+			&cli.BoolFlag{
+				Name:        "ws-compression",
+				Value:       wsConfig.Compression,
+				Usage:       "Negotiate permessage-deflate compression with websocket clients",
+				Destination: &wsConfig.Compression,
+			},
+			&cli.IntFlag{
+				Name:        "ws-compression-level",
+				Value:       wsConfig.CompressionLevel,
+				Usage:       "Compression level of websocket messages, 1 (fastest) to 9 (smallest)",
+				Destination: &wsConfig.CompressionLevel,
+			},
+			&cli.IntFlag{
+				Name:        "ws-max-batch",
+				Value:       wsConfig.MaxBatchSize,
+				Usage:       "Most queued JSON messages written in a single websocket frame, 1 disables batching",
+				Destination: &wsConfig.MaxBatchSize,
+			},
+			&cli.DurationFlag{
+				Name:        "ws-flush-interval",
+				Value:       wsConfig.FlushInterval,
+				Usage:       "How long a queued websocket message waits for more to batch with it (e.g. \"20ms\")",
+				Destination: &wsConfig.FlushInterval,
+			},
+			&cli.Float64Flag{
+				Name:        "ws-command-rate",
+				Value:       wsCommandRate,
+				Usage:       "Commands per second a websocket client may send over time, faster ones are dropped",
+				Destination: &wsCommandRate,
+			},
+			&cli.IntFlag{
+				Name:        "ws-command-burst",
+				Value:       wsConfig.CommandBurst,
+				Usage:       "Commands a websocket client may send in a quick burst",
+				Destination: &wsConfig.CommandBurst,
 			},
 		},
 		Usage: "Kaboo server FTW",
+		Commands: []*cli.Command{
+			simulateCommand(),
+		},
 		Action: func(c *cli.Context) error {
-			server := transport.NewServer(restPort, auth0Domain, auth0Audience)
-			server.Start()
+			// Not marked as required so sub commands can run without them
+			if auth0Domain == "" || auth0Audience == "" {
+				return cli.Exit("Required flags \"auth0-domain, auth0-audience\" not set", 1)
+			}
+			if err := httpConfig.Validate(); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			wsConfig.CommandRate = rate.Limit(wsCommandRate)
+			if err := wsConfig.Validate(); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			origins := allowedOrigins.Value()
+			if len(origins) == 0 && os.Getenv("DEBUG") != "" {
+				origins = []string{origin.Any}
+			}
+			allowlist, err := origin.Parse(origins)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			server := transport.NewServer(restPort, auth0Domain, auth0Audience, allowlist, wsConfig)
+			if chatBlocklist != "" {
+				content, err := ioutil.ReadFile(chatBlocklist)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				server.SetProfanityFilter(backend.NewWordListFilter(strings.Fields(string(content))))
+			}
+			avatars, err := backend.NewAvatarStore(avatarDir)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			server.SetAvatarStore(avatars)
+			if err := server.Start(httpConfig); err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
 			return nil
 		},
 	}
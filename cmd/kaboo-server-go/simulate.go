@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/ngutman/kaboo-server-go/bots"
+	"github.com/ngutman/kaboo-server-go/engine"
+	"github.com/ngutman/kaboo-server-go/simulation"
+	cli "github.com/urfave/cli/v2"
+)
+
+func simulateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "simulate",
+		Usage: "Run bot games in memory and print aggregated statistics",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "games",
+				Value: 1000,
+				Usage: "Number of games to simulate",
+			},
+			&cli.StringSliceFlag{
+				Name:  "bots",
+				Value: cli.NewStringSlice(string(bots.DifficultyRandom), string(bots.DifficultyMemory), string(bots.DifficultyExpected)),
+				Usage: "Bot strategy of every seat (random, memory, expected)",
+			},
+			&cli.StringFlag{
+				Name:  "seed",
+				Value: "kaboo",
+				Usage: "Base seed, game i is dealt from \"<seed>-<i>\"",
+			},
+			&cli.StringFlag{
+				Name:  "rules",
+				Usage: "JSON file with the house rules to play by, unset settings keep their defaults",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Value: "text",
+				Usage: "Output format (text, json, csv)",
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "Write the report to a file instead of stdout",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			config := simulation.Config{
+				Games: c.Int("games"),
+				Seed:  c.String("seed"),
+			}
+			for _, strategy := range c.StringSlice("bots") {
+				config.Strategies = append(config.Strategies, bots.Difficulty(strategy))
+			}
+			if path := c.String("rules"); path != "" {
+				rules, err := readRules(path)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				config.Rules = rules
+			}
+			report, err := simulation.Run(config)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			out := io.Writer(os.Stdout)
+			if path := c.String("output"); path != "" {
+				f, err := os.Create(path)
+				if err != nil {
+					return cli.Exit(err.Error(), 1)
+				}
+				defer f.Close()
+				out = f
+			}
+			return writeReport(out, c.String("format"), report)
+		},
+	}
+}
+
+// readRules reads a JSON ruleset, settings missing from the file keep their defaults
+func readRules(path string) (engine.Ruleset, error) {
+	rules := engine.DefaultRuleset()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+	err = json.Unmarshal(data, &rules)
+	return rules, err
+}
+
+func writeReport(out io.Writer, format string, report *simulation.Report) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"seat", "strategy", "wins", "win_rate", "average_score", "kaboo_calls", "kaboo_success_rate"})
+		for _, s := range report.Seats {
+			w.Write([]string{
+				strconv.Itoa(s.Seat),
+				string(s.Strategy),
+				strconv.FormatFloat(s.Wins, 'f', 1, 64),
+				strconv.FormatFloat(s.WinRate, 'f', 4, 64),
+				strconv.FormatFloat(s.AverageScore, 'f', 2, 64),
+				strconv.Itoa(s.KabooCalls),
+				strconv.FormatFloat(s.KabooSuccessRate, 'f', 4, 64),
+			})
+		}
+		w.Flush()
+		return w.Error()
+	case "text":
+		fmt.Fprintf(out, "Games: %d (seed %q)\n", report.Games, report.Seed)
+		fmt.Fprintf(out, "Average game length: %.1f turns, %.1f rounds\n", report.AverageTurns, report.AverageRounds)
+		fmt.Fprintf(out, "Kaboo calls: %d (%.1f%% successful)\n\n", report.KabooCalls, report.KabooSuccessRate*100)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SEAT\tSTRATEGY\tWIN RATE\tAVG SCORE\tKABOO CALLS\tKABOO SUCCESS")
+		for _, s := range report.Seats {
+			fmt.Fprintf(w, "%d\t%s\t%.1f%%\t%.2f\t%d\t%.1f%%\n",
+				s.Seat, s.Strategy, s.WinRate*100, s.AverageScore, s.KabooCalls, s.KabooSuccessRate*100)
+		}
+		return w.Flush()
+	}
+	return cli.Exit(fmt.Sprintf("Unknown output format %q", format), 1)
+}
@@ -0,0 +1,42 @@
+package engine
+
+// ActionType type of a player action
+type ActionType string
+
+// Player actions
+const (
+	// ActionDrawFromDeck draw the top card of the deck
+	ActionDrawFromDeck ActionType = "draw_deck"
+	// ActionDrawFromDiscard take the top card of the discard pile, it must be swapped into the hand
+	ActionDrawFromDiscard ActionType = "draw_discard"
+	// ActionCallKaboo end the round after every other player had one last turn
+	ActionCallKaboo ActionType = "call_kaboo"
+	// ActionReplace put the drawn card in Slot, discarding the card that was there
+	ActionReplace ActionType = "replace"
+	// ActionDiscard discard the drawn card, activating its power if it came from the deck
+	ActionDiscard ActionType = "discard"
+	// ActionPeek look at one of your own cards (Slot)
+	ActionPeek ActionType = "peek"
+	// ActionSpy look at another player's card (TargetSeat, TargetSlot)
+	ActionSpy ActionType = "spy"
+	// ActionBlindSwap exchange Slot with another player's card without looking
+	ActionBlindSwap ActionType = "blind_swap"
+	// ActionLook look at Slot and another player's card before deciding whether to exchange them
+	ActionLook ActionType = "look"
+	// ActionLookDecision exchange (Swap) or keep the cards looked at
+	ActionLookDecision ActionType = "look_decision"
+	// ActionSkipPower give up the power of the discarded card
+	ActionSkipPower ActionType = "skip_power"
+	// ActionSnap throw Slot on the discard pile out of turn, it must match the pile's top card
+	ActionSnap ActionType = "snap"
+)
+
+// Action a move made by the player sitting at Seat
+type Action struct {
+	Type       ActionType `json:"type" bson:"type"`
+	Seat       int        `json:"seat" bson:"seat"`
+	Slot       int        `json:"slot" bson:"slot"`
+	TargetSeat int        `json:"targetSeat" bson:"target_seat"`
+	TargetSlot int        `json:"targetSlot" bson:"target_slot"`
+	Swap       bool       `json:"swap" bson:"swap"`
+}
@@ -0,0 +1,92 @@
+package engine
+
+import "fmt"
+
+// Suit of a card
+type Suit int
+
+// Card suits, jokers carry SuitNone
+const (
+	SuitNone Suit = iota
+	SuitClubs
+	SuitDiamonds
+	SuitHearts
+	SuitSpades
+)
+
+// Rank of a card
+type Rank int
+
+// Card ranks
+const (
+	RankJoker Rank = iota
+	RankAce
+	RankTwo
+	RankThree
+	RankFour
+	RankFive
+	RankSix
+	RankSeven
+	RankEight
+	RankNine
+	RankTen
+	RankJack
+	RankQueen
+	RankKing
+)
+
+// Power granted when a card drawn from the deck is discarded, the ruleset decides which ranks grant which power
+type Power string
+
+// Card powers
+const (
+	PowerNone        Power = ""
+	PowerPeek        Power = "peek"
+	PowerSpy         Power = "spy"
+	PowerBlindSwap   Power = "blind_swap"
+	PowerLookAndSwap Power = "look_and_swap"
+)
+
+const (
+	// JokersInDeck number of jokers added to a standard 52 cards deck
+	JokersInDeck = 2
+	// JokerValue points a joker is worth at the end of a round
+	JokerValue = 0
+)
+
+// Card a single playing card, ID is unique within a deck
+type Card struct {
+	ID   int  `json:"-" bson:"id"`
+	Rank Rank `json:"rank" bson:"rank"`
+	Suit Suit `json:"suit" bson:"suit"`
+}
+
+// Value returns the points the card is worth
+func (c Card) Value() int {
+	if c.Rank == RankJoker {
+		return JokerValue
+	}
+	return int(c.Rank)
+}
+
+func (c Card) String() string {
+	if c.Rank == RankJoker {
+		return "Joker"
+	}
+	suits := [...]string{"", "♣", "♦", "♥", "♠"}
+	return fmt.Sprintf("%s%s", c.Rank, suits[c.Suit])
+}
+
+// NewDeck returns an ordered deck, cards are numbered by their position
+func NewDeck(jokers bool) []Card {
+	deck := make([]Card, 0, 52+JokersInDeck)
+	for suit := SuitClubs; suit <= SuitSpades; suit++ {
+		for rank := RankAce; rank <= RankKing; rank++ {
+			deck = append(deck, Card{ID: len(deck), Rank: rank, Suit: suit})
+		}
+	}
+	for i := 0; jokers && i < JokersInDeck; i++ {
+		deck = append(deck, Card{ID: len(deck), Rank: RankJoker, Suit: SuitNone})
+	}
+	return deck
+}
@@ -0,0 +1,142 @@
+package engine
+
+// Clearable view fields, listed in ViewDelta.Cleared when they're removed from the view
+const (
+	FieldDiscardTop = "discardTop"
+	FieldDrawn      = "drawn"
+)
+
+// ViewDelta the changes between two views of the same seat. Only changed fields are set,
+// fields that were removed from the view are listed in Cleared
+type ViewDelta struct {
+	Round       *int     `json:"round,omitempty"`
+	Turn        *int     `json:"turn,omitempty"`
+	Phase       *Phase   `json:"phase,omitempty"`
+	CurrentSeat *int     `json:"currentSeat,omitempty"`
+	Power       *Power   `json:"power,omitempty"`
+	HandSizes   []int    `json:"handSizes,omitempty"`
+	DeckSize    *int     `json:"deckSize,omitempty"`
+	DiscardTop  *Card    `json:"discardTop,omitempty"`
+	Drawn       *Card    `json:"drawn,omitempty"`
+	DrawnFrom   *Pile    `json:"drawnFrom,omitempty"`
+	KabooCaller *int     `json:"kabooCaller,omitempty"`
+	Scores      []int    `json:"scores,omitempty"`
+	Winners     []int    `json:"winners,omitempty"`
+	Cleared     []string `json:"cleared,omitempty"`
+}
+
+// Diff returns the changes turning prev into next, both views must belong to the same seat.
+// Diffing against an empty view returns every set field
+func Diff(prev, next PlayerView) ViewDelta {
+	var d ViewDelta
+	d.Round = changedInt(prev.Round, next.Round)
+	d.Turn = changedInt(prev.Turn, next.Turn)
+	if prev.Phase != next.Phase {
+		d.Phase = &next.Phase
+	}
+	d.CurrentSeat = changedInt(prev.CurrentSeat, next.CurrentSeat)
+	if prev.Power != next.Power {
+		d.Power = &next.Power
+	}
+	if !equalInts(prev.HandSizes, next.HandSizes) {
+		d.HandSizes = next.HandSizes
+	}
+	d.DeckSize = changedInt(prev.DeckSize, next.DeckSize)
+	d.DiscardTop = d.changedCard(FieldDiscardTop, prev.DiscardTop, next.DiscardTop)
+	d.Drawn = d.changedCard(FieldDrawn, prev.Drawn, next.Drawn)
+	if prev.DrawnFrom != next.DrawnFrom {
+		d.DrawnFrom = &next.DrawnFrom
+	}
+	d.KabooCaller = changedInt(prev.KabooCaller, next.KabooCaller)
+	if !equalInts(prev.Scores, next.Scores) {
+		d.Scores = next.Scores
+	}
+	if !equalInts(prev.Winners, next.Winners) {
+		d.Winners = next.Winners
+	}
+	return d
+}
+
+// Apply returns the view with the delta's changes
+func (v PlayerView) Apply(d ViewDelta) PlayerView {
+	setInt(&v.Round, d.Round)
+	setInt(&v.Turn, d.Turn)
+	if d.Phase != nil {
+		v.Phase = *d.Phase
+	}
+	setInt(&v.CurrentSeat, d.CurrentSeat)
+	if d.Power != nil {
+		v.Power = *d.Power
+	}
+	if d.HandSizes != nil {
+		v.HandSizes = d.HandSizes
+	}
+	setInt(&v.DeckSize, d.DeckSize)
+	if d.DiscardTop != nil {
+		v.DiscardTop = d.DiscardTop
+	}
+	if d.Drawn != nil {
+		v.Drawn = d.Drawn
+	}
+	if d.DrawnFrom != nil {
+		v.DrawnFrom = *d.DrawnFrom
+	}
+	setInt(&v.KabooCaller, d.KabooCaller)
+	if d.Scores != nil {
+		v.Scores = d.Scores
+	}
+	if d.Winners != nil {
+		v.Winners = d.Winners
+	}
+	for _, field := range d.Cleared {
+		switch field {
+		case FieldDiscardTop:
+			v.DiscardTop = nil
+		case FieldDrawn:
+			v.Drawn = nil
+		}
+	}
+	return v
+}
+
+// IsEmpty returns whether nothing changed
+func (d ViewDelta) IsEmpty() bool {
+	return d.Round == nil && d.Turn == nil && d.Phase == nil && d.CurrentSeat == nil && d.Power == nil &&
+		d.HandSizes == nil && d.DeckSize == nil && d.DiscardTop == nil && d.Drawn == nil && d.DrawnFrom == nil &&
+		d.KabooCaller == nil && d.Scores == nil && d.Winners == nil && d.Cleared == nil
+}
+
+func (d *ViewDelta) changedCard(field string, prev, next *Card) *Card {
+	switch {
+	case next == nil && prev != nil:
+		d.Cleared = append(d.Cleared, field)
+	case next != nil && (prev == nil || *prev != *next):
+		return next
+	}
+	return nil
+}
+
+func changedInt(prev, next int) *int {
+	if prev == next {
+		return nil
+	}
+	return &next
+}
+
+func setInt(field *int, value *int) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
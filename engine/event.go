@@ -0,0 +1,82 @@
+package engine
+
+// EventType type of an engine event
+type EventType string
+
+// Engine events
+const (
+	EventDeal      EventType = "deal"
+	EventTurn      EventType = "turn"
+	EventDraw      EventType = "draw"
+	EventDiscard   EventType = "discard"
+	EventReplace   EventType = "replace"
+	EventPeek      EventType = "peek"
+	EventSwap      EventType = "swap"
+	EventKaboo     EventType = "kaboo"
+	EventSnap      EventType = "snap"
+	EventReshuffle EventType = "reshuffle"
+	EventRoundEnd  EventType = "round_end"
+	EventGameEnd   EventType = "game_end"
+)
+
+const (
+	// RevealedToAll marks a card every player is allowed to see
+	RevealedToAll = -1
+	// Omniscient viewer that is allowed to see every card
+	Omniscient = -2
+	// Spectator viewer that only sees public cards
+	Spectator = -3
+)
+
+// Pile a card source
+type Pile string
+
+// Card piles
+const (
+	PileDeck    Pile = "deck"
+	PileDiscard Pile = "discard"
+)
+
+// Event describes a change of the game state. Card is hidden information
+// that only the RevealedTo seat may see, use Masked before handing an event
+// to a player.
+type Event struct {
+	Type        EventType `json:"type" bson:"type"`
+	Round       int       `json:"round" bson:"round"`
+	Seat        int       `json:"seat" bson:"seat"`
+	Slot        int       `json:"slot" bson:"slot"`
+	TargetSeat  int       `json:"targetSeat" bson:"target_seat"`
+	TargetSlot  int       `json:"targetSlot" bson:"target_slot"`
+	Pile        Pile      `json:"pile,omitempty" bson:"pile,omitempty"`
+	Card        *Card     `json:"card,omitempty" bson:"card,omitempty"`
+	RevealedTo  int       `json:"revealedTo" bson:"revealed_to"`
+	Discarded   *Card     `json:"discarded,omitempty" bson:"discarded,omitempty"`
+	Hands       [][]Card  `json:"hands,omitempty" bson:"hands,omitempty"`
+	RoundScores []int     `json:"roundScores,omitempty" bson:"round_scores,omitempty"`
+	Scores      []int     `json:"scores,omitempty" bson:"scores,omitempty"`
+	Winners     []int     `json:"winners,omitempty" bson:"winners,omitempty"`
+	// Penalty points added for a wrong snap
+	Penalty int `json:"penalty,omitempty" bson:"penalty,omitempty"`
+}
+
+// Masked returns a copy of the event as seen by viewer
+func (e Event) Masked(viewer int) Event {
+	if e.Card != nil && !e.VisibleTo(viewer) {
+		e.Card = nil
+	}
+	return e
+}
+
+// VisibleTo returns whether viewer may see the event's card
+func (e Event) VisibleTo(viewer int) bool {
+	return viewer == Omniscient || e.RevealedTo == RevealedToAll || e.RevealedTo == viewer
+}
+
+// MaskEvents masks a list of events for viewer
+func MaskEvents(events []Event, viewer int) []Event {
+	masked := make([]Event, len(events))
+	for i, e := range events {
+		masked[i] = e.Masked(viewer)
+	}
+	return masked
+}
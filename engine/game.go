@@ -0,0 +1,636 @@
+package engine
+
+import "errors"
+
+// Phase of the current turn
+type Phase string
+
+// Turn phases
+const (
+	PhaseTurnStart    Phase = "turn_start"
+	PhaseDrawn        Phase = "drawn"
+	PhasePower        Phase = "power"
+	PhaseLookDecision Phase = "look_decision"
+	PhaseGameOver     Phase = "game_over"
+)
+
+const (
+	// MinPlayers minimal number of players in a game
+	MinPlayers = 2
+	// MaxPlayers maximal number of players in a game
+	MaxPlayers = 8
+	// DefaultHandSize number of cards dealt to each player under the standard rules
+	DefaultHandSize = 4
+	// DefaultInitialPeekCount number of own cards each player sees when the round is dealt
+	DefaultInitialPeekCount = 2
+	// DefaultKabooPenalty points added to a Kaboo caller that doesn't have the lowest hand
+	DefaultKabooPenalty = 10
+	// DefaultSnapPenalty points added for snapping a card that doesn't match the discard pile
+	DefaultSnapPenalty = 5
+	// DefaultTargetScore the game ends once a player reaches this score
+	DefaultTargetScore = 100
+	// MaxRoundTurns ends a round in which nobody called Kaboo
+	MaxRoundTurns = 200
+	// MaxRounds ends a game that never reaches the target score
+	MaxRounds = 50
+
+	noSeat = -1
+)
+
+var (
+	// ErrPlayerCount invalid number of players
+	ErrPlayerCount = errors.New("Invalid number of players")
+
+	// ErrGameOver the game already ended
+	ErrGameOver = errors.New("Game is over")
+
+	// ErrNotYourTurn action made out of turn
+	ErrNotYourTurn = errors.New("Not your turn")
+
+	// ErrIllegalAction action is not allowed in the current phase
+	ErrIllegalAction = errors.New("Illegal action")
+
+	// ErrInvalidSlot card slot doesn't exist
+	ErrInvalidSlot = errors.New("Invalid card slot")
+
+	// ErrInvalidTarget target player can't be chosen
+	ErrInvalidTarget = errors.New("Invalid target player")
+
+	// ErrInvalidStep replay step is out of range
+	ErrInvalidStep = errors.New("Invalid replay step")
+)
+
+// RoundResult the outcome of a finished round
+type RoundResult struct {
+	Round       int   `json:"round"`
+	KabooCaller int   `json:"kabooCaller"`
+	Scores      []int `json:"scores"`
+	// KabooSucceeded a Kaboo was called and the caller had the lowest hand
+	KabooSucceeded bool `json:"kabooSucceeded"`
+}
+
+// Game a single Kaboo match played over several rounds. The game is fully
+// deterministic given its seed and the sequence of applied actions.
+type Game struct {
+	seed        string
+	players     int
+	shuffler    *shuffler
+	round       int
+	turn        int
+	roundTurns  int
+	phase       Phase
+	current     int
+	hands       [][]Card
+	deck        []Card
+	discard     []Card
+	drawn       *Card
+	drawnFrom   Pile
+	power       Power
+	pendingLook *Action
+	kabooCaller int
+	scores      []int
+	winners     []int
+	rules       Ruleset
+	penalties   []int
+	rounds      []RoundResult
+	history     []Action
+	knowledge   []map[int]bool
+}
+
+// NewGame creates a game for the given number of players played by rules and deals the first round
+func NewGame(seed string, players int, rules Ruleset) (*Game, []Event, error) {
+	if players < MinPlayers || players > MaxPlayers {
+		return nil, nil, ErrPlayerCount
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, nil, err
+	}
+	g := &Game{
+		seed:        seed,
+		players:     players,
+		rules:       rules,
+		round:       1,
+		kabooCaller: noSeat,
+		scores:      make([]int, players),
+	}
+	events := g.deal()
+	g.learn(events)
+	return g, events, nil
+}
+
+// Seed returns the game seed
+func (g *Game) Seed() string {
+	return g.seed
+}
+
+// Players returns the number of seats in the game
+func (g *Game) Players() int {
+	return g.players
+}
+
+// Rules returns the ruleset the game is played by
+func (g *Game) Rules() Ruleset {
+	return g.rules
+}
+
+// Round returns the current round, starting at 1
+func (g *Game) Round() int {
+	return g.round
+}
+
+// Turn returns the number of turns played so far in the game
+func (g *Game) Turn() int {
+	return g.turn
+}
+
+// CurrentSeat returns the seat expected to act
+func (g *Game) CurrentSeat() int {
+	return g.current
+}
+
+// Phase returns the current turn phase
+func (g *Game) Phase() Phase {
+	return g.phase
+}
+
+// IsOver returns whether the game ended
+func (g *Game) IsOver() bool {
+	return g.phase == PhaseGameOver
+}
+
+// KabooCaller returns the seat that called Kaboo this round or -1
+func (g *Game) KabooCaller() int {
+	return g.kabooCaller
+}
+
+// Scores returns the accumulated score of every seat
+func (g *Game) Scores() []int {
+	return append([]int(nil), g.scores...)
+}
+
+// Winners returns the seats with the lowest score once the game is over
+func (g *Game) Winners() []int {
+	return append([]int(nil), g.winners...)
+}
+
+// Rounds returns the result of every finished round
+func (g *Game) Rounds() []RoundResult {
+	return append([]RoundResult(nil), g.rounds...)
+}
+
+// History returns every action applied so far
+func (g *Game) History() []Action {
+	return append([]Action(nil), g.history...)
+}
+
+// Apply validates and applies an action, returning the resulting events
+func (g *Game) Apply(a Action) ([]Event, error) {
+	if g.phase == PhaseGameOver {
+		return nil, ErrGameOver
+	}
+	if a.Type == ActionSnap {
+		// Snapping is the one action any player may make out of turn
+		events, err := g.applySnap(a)
+		if err != nil {
+			return nil, err
+		}
+		g.history = append(g.history, a)
+		g.learn(events)
+		return events, nil
+	}
+	if a.Seat != g.current {
+		return nil, ErrNotYourTurn
+	}
+	var events []Event
+	var err error
+	switch g.phase {
+	case PhaseTurnStart:
+		events, err = g.applyTurnStart(a)
+	case PhaseDrawn:
+		events, err = g.applyDrawn(a)
+	case PhasePower:
+		events, err = g.applyPower(a)
+	case PhaseLookDecision:
+		events, err = g.applyLookDecision(a)
+	}
+	if err != nil {
+		return nil, err
+	}
+	g.history = append(g.history, a)
+	g.learn(events)
+	return events, nil
+}
+
+// LegalActions returns every action the given seat may currently make
+func (g *Game) LegalActions(seat int) []Action {
+	if g.phase == PhaseGameOver || seat < 0 || seat >= g.players {
+		return nil
+	}
+	var actions []Action
+	if g.canSnap(seat) {
+		for slot := range g.hands[seat] {
+			actions = append(actions, Action{Type: ActionSnap, Seat: seat, Slot: slot})
+		}
+	}
+	if seat != g.current {
+		return actions
+	}
+	switch g.phase {
+	case PhaseTurnStart:
+		if g.canDrawFromDeck() {
+			actions = append(actions, Action{Type: ActionDrawFromDeck, Seat: seat})
+		}
+		if len(g.discard) > 0 {
+			actions = append(actions, Action{Type: ActionDrawFromDiscard, Seat: seat})
+		}
+		if g.kabooCaller == noSeat {
+			actions = append(actions, Action{Type: ActionCallKaboo, Seat: seat})
+		}
+	case PhaseDrawn:
+		for slot := range g.hands[seat] {
+			actions = append(actions, Action{Type: ActionReplace, Seat: seat, Slot: slot})
+		}
+		if g.drawnFrom == PileDeck {
+			actions = append(actions, Action{Type: ActionDiscard, Seat: seat})
+		}
+	case PhasePower:
+		actions = append(actions, Action{Type: ActionSkipPower, Seat: seat})
+		switch g.power {
+		case PowerPeek:
+			for slot := range g.hands[seat] {
+				actions = append(actions, Action{Type: ActionPeek, Seat: seat, Slot: slot})
+			}
+		case PowerSpy:
+			g.forEachTarget(seat, true, func(target, targetSlot int) {
+				actions = append(actions, Action{Type: ActionSpy, Seat: seat, TargetSeat: target, TargetSlot: targetSlot})
+			})
+		case PowerBlindSwap, PowerLookAndSwap:
+			actionType := ActionBlindSwap
+			if g.power == PowerLookAndSwap {
+				actionType = ActionLook
+			}
+			for slot := range g.hands[seat] {
+				g.forEachTarget(seat, false, func(target, targetSlot int) {
+					actions = append(actions, Action{Type: actionType, Seat: seat, Slot: slot, TargetSeat: target, TargetSlot: targetSlot})
+				})
+			}
+		}
+	case PhaseLookDecision:
+		actions = append(actions,
+			Action{Type: ActionLookDecision, Seat: seat, Swap: false},
+			Action{Type: ActionLookDecision, Seat: seat, Swap: true})
+	}
+	return actions
+}
+
+func (g *Game) applyTurnStart(a Action) ([]Event, error) {
+	switch a.Type {
+	case ActionDrawFromDeck:
+		if !g.canDrawFromDeck() {
+			return nil, ErrIllegalAction
+		}
+		card, events := g.popDeck()
+		g.drawn, g.drawnFrom, g.phase = &card, PileDeck, PhaseDrawn
+		e := g.newEvent(EventDraw, a.Seat)
+		e.Pile, e.Card, e.RevealedTo = PileDeck, &card, a.Seat
+		return append(events, e), nil
+	case ActionDrawFromDiscard:
+		if len(g.discard) == 0 {
+			return nil, ErrIllegalAction
+		}
+		card := g.discard[len(g.discard)-1]
+		g.discard = g.discard[:len(g.discard)-1]
+		g.drawn, g.drawnFrom, g.phase = &card, PileDiscard, PhaseDrawn
+		e := g.newEvent(EventDraw, a.Seat)
+		e.Pile, e.Card = PileDiscard, &card
+		return []Event{e}, nil
+	case ActionCallKaboo:
+		if g.kabooCaller != noSeat {
+			return nil, ErrIllegalAction
+		}
+		g.kabooCaller = a.Seat
+		return append([]Event{g.newEvent(EventKaboo, a.Seat)}, g.endTurn()...), nil
+	}
+	return nil, ErrIllegalAction
+}
+
+func (g *Game) applyDrawn(a Action) ([]Event, error) {
+	drawn := *g.drawn
+	switch a.Type {
+	case ActionReplace:
+		if !g.validSlot(a.Seat, a.Slot) {
+			return nil, ErrInvalidSlot
+		}
+		replaced := g.hands[a.Seat][a.Slot]
+		g.hands[a.Seat][a.Slot] = drawn
+		g.discard = append(g.discard, replaced)
+		e := g.newEvent(EventReplace, a.Seat)
+		e.Slot, e.Card, e.Discarded = a.Slot, &drawn, &replaced
+		if g.drawnFrom == PileDeck {
+			e.RevealedTo = a.Seat
+		}
+		return append([]Event{e}, g.endTurn()...), nil
+	case ActionDiscard:
+		if g.drawnFrom != PileDeck {
+			return nil, ErrIllegalAction
+		}
+		g.drawn = nil
+		g.discard = append(g.discard, drawn)
+		e := g.newEvent(EventDiscard, a.Seat)
+		e.Card = &drawn
+		if power := g.rules.Power(drawn.Rank); power != PowerNone {
+			g.power, g.phase = power, PhasePower
+			return []Event{e}, nil
+		}
+		return append([]Event{e}, g.endTurn()...), nil
+	}
+	return nil, ErrIllegalAction
+}
+
+func (g *Game) applyPower(a Action) ([]Event, error) {
+	if a.Type == ActionSkipPower {
+		return g.endTurn(), nil
+	}
+	switch {
+	case g.power == PowerPeek && a.Type == ActionPeek:
+		if !g.validSlot(a.Seat, a.Slot) {
+			return nil, ErrInvalidSlot
+		}
+		return append([]Event{g.peekEvent(a.Seat, a.Seat, a.Slot)}, g.endTurn()...), nil
+	case g.power == PowerSpy && a.Type == ActionSpy:
+		if err := g.validateTarget(a, true); err != nil {
+			return nil, err
+		}
+		return append([]Event{g.peekEvent(a.Seat, a.TargetSeat, a.TargetSlot)}, g.endTurn()...), nil
+	case g.power == PowerBlindSwap && a.Type == ActionBlindSwap:
+		if !g.validSlot(a.Seat, a.Slot) {
+			return nil, ErrInvalidSlot
+		}
+		if err := g.validateTarget(a, false); err != nil {
+			return nil, err
+		}
+		return append([]Event{g.swap(a)}, g.endTurn()...), nil
+	case g.power == PowerLookAndSwap && a.Type == ActionLook:
+		if !g.validSlot(a.Seat, a.Slot) {
+			return nil, ErrInvalidSlot
+		}
+		if err := g.validateTarget(a, false); err != nil {
+			return nil, err
+		}
+		look := a
+		g.pendingLook, g.phase = &look, PhaseLookDecision
+		return []Event{
+			g.peekEvent(a.Seat, a.Seat, a.Slot),
+			g.peekEvent(a.Seat, a.TargetSeat, a.TargetSlot),
+		}, nil
+	}
+	return nil, ErrIllegalAction
+}
+
+// canSnap returns whether seat may snap a card, which is possible from the moment a card
+// was discarded until the next player draws. The Kaboo caller's hand is locked, and the last
+// card of a hand can't be snapped since a seat without cards has nothing to replace
+func (g *Game) canSnap(seat int) bool {
+	return g.rules.Snapping && g.phase == PhaseTurnStart && len(g.discard) > 0 &&
+		seat != g.kabooCaller && len(g.hands[seat]) > 1
+}
+
+// applySnap throws the card at Slot on the discard pile if it matches the pile's top card,
+// otherwise the card is revealed, stays in the hand and the seat is penalized
+func (g *Game) applySnap(a Action) ([]Event, error) {
+	if a.Seat < 0 || a.Seat >= g.players || !g.canSnap(a.Seat) {
+		return nil, ErrIllegalAction
+	}
+	if !g.validSlot(a.Seat, a.Slot) {
+		return nil, ErrInvalidSlot
+	}
+	card := g.hands[a.Seat][a.Slot]
+	e := g.newEvent(EventSnap, a.Seat)
+	e.Slot, e.Card = a.Slot, &card
+	if card.Rank != g.discard[len(g.discard)-1].Rank {
+		g.penalties[a.Seat] += g.rules.SnapPenalty
+		e.Penalty = g.rules.SnapPenalty
+		return []Event{e}, nil
+	}
+	hand := g.hands[a.Seat]
+	g.hands[a.Seat] = append(hand[:a.Slot:a.Slot], hand[a.Slot+1:]...)
+	g.discard = append(g.discard, card)
+	return []Event{e}, nil
+}
+
+func (g *Game) applyLookDecision(a Action) ([]Event, error) {
+	if a.Type != ActionLookDecision {
+		return nil, ErrIllegalAction
+	}
+	var events []Event
+	if a.Swap {
+		events = append(events, g.swap(*g.pendingLook))
+	}
+	return append(events, g.endTurn()...), nil
+}
+
+func (g *Game) endTurn() []Event {
+	g.phase, g.power, g.pendingLook, g.drawn = PhaseTurnStart, PowerNone, nil, nil
+	g.turn++
+	g.roundTurns++
+	g.current = (g.current + 1) % g.players
+	if g.current == g.kabooCaller || g.roundTurns >= MaxRoundTurns {
+		return g.endRound()
+	}
+	return []Event{g.newEvent(EventTurn, g.current)}
+}
+
+func (g *Game) endRound() []Event {
+	roundScores := make([]int, g.players)
+	for seat, hand := range g.hands {
+		for _, card := range hand {
+			roundScores[seat] += card.Value()
+		}
+	}
+	handScores := append([]int(nil), roundScores...)
+	kabooSucceeded := false
+	if caller := g.kabooCaller; caller != noSeat {
+		lowest := true
+		for seat, score := range handScores {
+			if seat != caller && score <= handScores[caller] {
+				lowest = false
+			}
+		}
+		kabooSucceeded = lowest
+		if lowest {
+			roundScores[caller] = 0
+		} else {
+			roundScores[caller] += g.rules.KabooPenalty
+		}
+	}
+	for seat, penalty := range g.penalties {
+		roundScores[seat] += penalty
+	}
+	g.rounds = append(g.rounds, RoundResult{
+		Round:          g.round,
+		KabooCaller:    g.kabooCaller,
+		Scores:         roundScores,
+		KabooSucceeded: kabooSucceeded,
+	})
+	finished := false
+	for seat := range g.scores {
+		g.scores[seat] += roundScores[seat]
+		finished = finished || g.scores[seat] >= g.rules.TargetScore
+	}
+	e := g.newEvent(EventRoundEnd, g.kabooCaller)
+	e.Hands, e.RoundScores, e.Scores = g.copyHands(), roundScores, g.Scores()
+	events := []Event{e}
+	if finished || g.round >= MaxRounds {
+		g.phase = PhaseGameOver
+		g.winners = lowestSeats(g.scores)
+		end := g.newEvent(EventGameEnd, noSeat)
+		end.Scores, end.Winners = g.Scores(), g.Winners()
+		return append(events, end)
+	}
+	g.round++
+	return append(events, g.deal()...)
+}
+
+func (g *Game) deal() []Event {
+	g.shuffler = newShuffler(g.seed, g.round)
+	g.deck = NewDeck(g.rules.Jokers)
+	g.shuffler.shuffle(g.deck)
+	g.hands = make([][]Card, g.players)
+	for slot := 0; slot < g.rules.HandSize; slot++ {
+		for seat := range g.hands {
+			g.hands[seat] = append(g.hands[seat], g.deck[len(g.deck)-1])
+			g.deck = g.deck[:len(g.deck)-1]
+		}
+	}
+	top := g.deck[len(g.deck)-1]
+	g.deck = g.deck[:len(g.deck)-1]
+	g.discard = []Card{top}
+	g.current = (g.round - 1) % g.players
+	g.phase, g.power, g.pendingLook, g.drawn = PhaseTurnStart, PowerNone, nil, nil
+	g.kabooCaller, g.roundTurns = noSeat, 0
+	g.penalties = make([]int, g.players)
+
+	deal := g.newEvent(EventDeal, g.current)
+	deal.Pile, deal.Card = PileDiscard, &top
+	events := []Event{deal}
+	for seat := range g.hands {
+		for slot := 0; slot < g.rules.InitialPeekCount; slot++ {
+			events = append(events, g.peekEvent(seat, seat, slot))
+		}
+	}
+	return append(events, g.newEvent(EventTurn, g.current))
+}
+
+// canDrawFromDeck returns whether the deck has a card, or the discard pile has cards below its
+// top to reshuffle into the deck
+func (g *Game) canDrawFromDeck() bool {
+	return len(g.deck) > 0 || len(g.discard) > 1
+}
+
+// popDeck draws the top card of the deck, reshuffling the discard pile into
+// the deck when it runs out. Must only be called when canDrawFromDeck
+func (g *Game) popDeck() (Card, []Event) {
+	var events []Event
+	if len(g.deck) == 0 {
+		top := g.discard[len(g.discard)-1]
+		g.deck = g.discard[:len(g.discard)-1]
+		g.discard = []Card{top}
+		g.shuffler.shuffle(g.deck)
+		events = append(events, g.newEvent(EventReshuffle, noSeat))
+	}
+	card := g.deck[len(g.deck)-1]
+	g.deck = g.deck[:len(g.deck)-1]
+	return card, events
+}
+
+// learn records which cards every seat has seen, knowledge follows a card
+// wherever it is moved to and is forgotten when a new round is dealt
+func (g *Game) learn(events []Event) {
+	for _, e := range events {
+		if e.Type == EventDeal {
+			g.knowledge = make([]map[int]bool, g.players)
+			for seat := range g.knowledge {
+				g.knowledge[seat] = make(map[int]bool)
+			}
+		}
+		for seat, known := range g.knowledge {
+			if e.Card != nil && e.VisibleTo(seat) {
+				known[e.Card.ID] = true
+			}
+			for _, hand := range e.Hands {
+				for _, card := range hand {
+					known[card.ID] = true
+				}
+			}
+		}
+	}
+}
+
+func (g *Game) swap(a Action) Event {
+	hand, targetHand := g.hands[a.Seat], g.hands[a.TargetSeat]
+	hand[a.Slot], targetHand[a.TargetSlot] = targetHand[a.TargetSlot], hand[a.Slot]
+	e := g.newEvent(EventSwap, a.Seat)
+	e.Slot, e.TargetSeat, e.TargetSlot = a.Slot, a.TargetSeat, a.TargetSlot
+	return e
+}
+
+func (g *Game) peekEvent(seat, targetSeat, targetSlot int) Event {
+	card := g.hands[targetSeat][targetSlot]
+	e := g.newEvent(EventPeek, seat)
+	e.TargetSeat, e.TargetSlot, e.Card, e.RevealedTo = targetSeat, targetSlot, &card, seat
+	return e
+}
+
+func (g *Game) newEvent(t EventType, seat int) Event {
+	return Event{Type: t, Round: g.round, Seat: seat, RevealedTo: RevealedToAll}
+}
+
+func (g *Game) validSlot(seat, slot int) bool {
+	return slot >= 0 && slot < len(g.hands[seat])
+}
+
+// validateTarget checks the action's target card, a Kaboo caller's hand is
+// locked and can only be looked at
+func (g *Game) validateTarget(a Action, lookOnly bool) error {
+	if a.TargetSeat < 0 || a.TargetSeat >= g.players || a.TargetSeat == a.Seat {
+		return ErrInvalidTarget
+	}
+	if !lookOnly && a.TargetSeat == g.kabooCaller {
+		return ErrInvalidTarget
+	}
+	if !g.validSlot(a.TargetSeat, a.TargetSlot) {
+		return ErrInvalidSlot
+	}
+	return nil
+}
+
+func (g *Game) forEachTarget(seat int, lookOnly bool, f func(target, targetSlot int)) {
+	for target := range g.hands {
+		if target == seat || (!lookOnly && target == g.kabooCaller) {
+			continue
+		}
+		for targetSlot := range g.hands[target] {
+			f(target, targetSlot)
+		}
+	}
+}
+
+func (g *Game) copyHands() [][]Card {
+	hands := make([][]Card, len(g.hands))
+	for seat, hand := range g.hands {
+		hands[seat] = append([]Card(nil), hand...)
+	}
+	return hands
+}
+
+func lowestSeats(scores []int) (seats []int) {
+	for seat, score := range scores {
+		switch {
+		case len(seats) == 0 || score < scores[seats[0]]:
+			seats = []int{seat}
+		case score == scores[seats[0]]:
+			seats = append(seats, seat)
+		}
+	}
+	return
+}
@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func Test_DealIsDeterministic(t *testing.T) {
+	g1, events1, _ := NewGame("seed", 3, DefaultRuleset())
+	g2, events2, _ := NewGame("seed", 3, DefaultRuleset())
+	if !reflect.DeepEqual(g1.hands, g2.hands) || !reflect.DeepEqual(events1, events2) {
+		t.Errorf("Same seed should deal the same hands")
+	}
+	g3, _, _ := NewGame("other seed", 3, DefaultRuleset())
+	if reflect.DeepEqual(g1.hands, g3.hands) {
+		t.Errorf("Different seeds should deal different hands")
+	}
+}
+
+func Test_InvalidPlayerCount(t *testing.T) {
+	if _, _, err := NewGame("seed", 1, DefaultRuleset()); err != ErrPlayerCount {
+		t.Errorf("Should have failed creating a single player game")
+	}
+	if _, _, err := NewGame("seed", MaxPlayers+1, DefaultRuleset()); err != ErrPlayerCount {
+		t.Errorf("Should have failed creating an oversized game")
+	}
+}
+
+func Test_ActionOutOfTurn(t *testing.T) {
+	g, _, _ := NewGame("seed", 2, DefaultRuleset())
+	if _, err := g.Apply(Action{Type: ActionDrawFromDeck, Seat: 1}); err != ErrNotYourTurn {
+		t.Errorf("Should have rejected action out of turn, got %v", err)
+	}
+	if _, err := g.Apply(Action{Type: ActionDiscard, Seat: 0}); err != ErrIllegalAction {
+		t.Errorf("Should have rejected discarding before drawing, got %v", err)
+	}
+}
+
+func Test_DrawnCardIsPrivate(t *testing.T) {
+	g, _, _ := NewGame("seed", 2, DefaultRuleset())
+	events, err := g.Apply(Action{Type: ActionDrawFromDeck, Seat: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	draw := events[len(events)-1]
+	if draw.Masked(0).Card == nil || draw.Masked(1).Card != nil || draw.Masked(Spectator).Card != nil {
+		t.Errorf("Drawn card should only be visible to the drawing player")
+	}
+	if g.View(0).Drawn == nil || g.View(1).Drawn != nil || g.View(Spectator).Drawn != nil {
+		t.Errorf("Drawn card should only be in the drawing player's view")
+	}
+}
+
+func Test_KabooEndsRoundAfterEveryoneElsePlayed(t *testing.T) {
+	g, _, _ := NewGame("seed", 3, DefaultRuleset())
+	mustApply(t, g, Action{Type: ActionCallKaboo, Seat: 0})
+	for seat := 1; seat < 3; seat++ {
+		mustApply(t, g, Action{Type: ActionDrawFromDeck, Seat: seat})
+		mustApply(t, g, Action{Type: ActionReplace, Seat: seat, Slot: 0})
+	}
+	if g.Round() != 2 && !g.IsOver() {
+		t.Errorf("Round should have ended once play got back to the Kaboo caller")
+	}
+	if rounds := g.Rounds(); len(rounds) != 1 || rounds[0].KabooCaller != 0 {
+		t.Errorf("Finished round should record its Kaboo caller, got %+v", rounds)
+	}
+}
+
+func Test_RandomGamesFinish(t *testing.T) {
+	playRandomGames(t, DefaultRuleset())
+}
+
+func Test_HouseRulesGamesFinish(t *testing.T) {
+	rules := DefaultRuleset()
+	rules.HandSize, rules.InitialPeekCount, rules.Jokers = MaxHandSize, 0, false
+	rules.Snapping, rules.TargetScore = true, 50
+	rules.Powers = map[string]Power{"A": PowerLookAndSwap}
+	playRandomGames(t, rules)
+}
+
+func Test_Snapping(t *testing.T) {
+	rules := DefaultRuleset()
+	rules.Snapping = true
+	g, _, _ := NewGame("seed", 2, rules)
+	top := g.View(Omniscient).DiscardTop
+	snapshot := g.Snapshot(Omniscient)
+	wrong, right := -1, -1
+	for slot, card := range snapshot.Hands[1] {
+		if card.Rank == top.Rank {
+			right = slot
+		} else {
+			wrong = slot
+		}
+	}
+	events := mustApply(t, g, Action{Type: ActionSnap, Seat: 1, Slot: wrong})
+	if events[0].Type != EventSnap || events[0].Penalty != rules.SnapPenalty || events[0].RevealedTo != RevealedToAll {
+		t.Errorf("Wrong snap should be revealed and penalized, got %+v", events[0])
+	}
+	if right != -1 {
+		mustApply(t, g, Action{Type: ActionSnap, Seat: 1, Slot: right})
+		if len(g.hands[1]) != rules.HandSize-1 {
+			t.Errorf("Matching snap should leave the hand")
+		}
+	}
+	if _, _, err := NewGame("seed", 2, Ruleset{}); !errors.Is(err, ErrInvalidRuleset) {
+		t.Errorf("Expected invalid ruleset, got %v", err)
+	}
+}
+
+func Test_SnapKeepsLastCard(t *testing.T) {
+	rules := DefaultRuleset()
+	rules.Snapping, rules.HandSize, rules.InitialPeekCount = true, 1, 1
+	g, _, err := NewGame("seed", 2, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.hands[1][0] = g.discard[len(g.discard)-1]
+	for _, action := range g.LegalActions(1) {
+		if action.Type == ActionSnap {
+			t.Errorf("The last card of a hand shouldn't be snappable")
+		}
+	}
+	if _, err := g.Apply(Action{Type: ActionSnap, Seat: 1, Slot: 0}); !errors.Is(err, ErrIllegalAction) {
+		t.Errorf("Snapping the last card should be illegal, got %v", err)
+	}
+
+	// Every seat snapping whenever possible, games must still finish with one card hands
+	for i := 0; i < 200; i++ {
+		rng := rand.New(rand.NewSource(int64(i)))
+		g, _, _ := NewGame(string(rune('a'+i%26))+string(rune('a'+i/26)), 2+i%(MaxPlayers-1), rules)
+		for !g.IsOver() {
+			var legal []Action
+			for seat := 0; seat < g.Players(); seat++ {
+				legal = append(legal, g.LegalActions(seat)...)
+			}
+			if len(legal) == 0 {
+				t.Fatalf("Game %v has no legal actions", i)
+			}
+			mustApply(t, g, legal[rng.Intn(len(legal))])
+		}
+	}
+}
+
+func Test_EmptyDeckCantBeDrawn(t *testing.T) {
+	g, _, _ := NewGame("seed", 2, DefaultRuleset())
+	seat := g.CurrentSeat()
+	g.deck, g.discard = nil, g.discard[len(g.discard)-1:]
+	legal := g.LegalActions(seat)
+	for _, action := range legal {
+		if action.Type == ActionDrawFromDeck {
+			t.Errorf("Drawing from an empty deck with nothing to reshuffle shouldn't be legal")
+		}
+	}
+	if len(legal) == 0 {
+		t.Errorf("Drawing from the discard pile should still be legal")
+	}
+	if _, err := g.Apply(Action{Type: ActionDrawFromDeck, Seat: seat}); !errors.Is(err, ErrIllegalAction) {
+		t.Errorf("Drawing from an empty deck should be illegal, got %v", err)
+	}
+}
+
+func playRandomGames(t *testing.T, rules Ruleset) {
+	for i := 0; i < 20; i++ {
+		rng := rand.New(rand.NewSource(int64(i)))
+		g, _, err := NewGame(string(rune('a'+i)), 2+i%(MaxPlayers-1), rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for !g.IsOver() {
+			legal := g.LegalActions(g.CurrentSeat())
+			mustApply(t, g, legal[rng.Intn(len(legal))])
+		}
+		if len(g.Winners()) == 0 {
+			t.Errorf("Finished game should have a winner")
+		}
+		replayed, _, err := Replay(g.Seed(), g.Players(), g.Rules(), g.History())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(replayed.Scores(), g.Scores()) {
+			t.Errorf("Replaying the history should reach the same scores")
+		}
+	}
+}
+
+func Test_DeltasRebuildViews(t *testing.T) {
+	rules := DefaultRuleset()
+	rules.Snapping = true
+	for i := 0; i < 10; i++ {
+		rng := rand.New(rand.NewSource(int64(i)))
+		g, _, _ := NewGame(string(rune('a'+i)), 2+i%(MaxPlayers-1), rules)
+		sent := make([]PlayerView, g.Players())
+		rebuilt := make([]PlayerView, g.Players())
+		for seat := range sent {
+			sent[seat] = g.View(seat)
+			rebuilt[seat] = sent[seat]
+		}
+		for !g.IsOver() {
+			legal := g.LegalActions(g.CurrentSeat())
+			mustApply(t, g, legal[rng.Intn(len(legal))])
+			for seat := range sent {
+				view := g.View(seat)
+				delta := Diff(sent[seat], view)
+				if delta.Drawn != nil && seat != g.CurrentSeat() && view.DrawnFrom != PileDiscard {
+					t.Fatalf("Delta revealed a card drawn by another seat")
+				}
+				rebuilt[seat] = rebuilt[seat].Apply(delta)
+				sent[seat] = view
+				if !reflect.DeepEqual(rebuilt[seat], view) {
+					t.Fatalf("Applying deltas should rebuild the view\n%+v\n%+v", rebuilt[seat], view)
+				}
+			}
+		}
+	}
+	g, _, _ := NewGame("seed", 2, DefaultRuleset())
+	if !Diff(g.View(0), g.View(0)).IsEmpty() {
+		t.Errorf("Unchanged view should have an empty delta")
+	}
+}
+
+func Test_SnapshotOnlyRevealsKnownCards(t *testing.T) {
+	g, _, _ := NewGame("seed", 2, DefaultRuleset())
+	snapshot := g.Snapshot(0)
+	for slot, card := range snapshot.Hands[0] {
+		if (slot < DefaultInitialPeekCount) != (card != nil) {
+			t.Errorf("Only the initially peeked cards should be known, slot %d is %v", slot, card)
+		}
+	}
+	for _, card := range snapshot.Hands[1] {
+		if card != nil {
+			t.Errorf("Opponent cards shouldn't be known")
+		}
+	}
+	known := *snapshot.Hands[0][0]
+	mustApply(t, g, Action{Type: ActionDrawFromDeck, Seat: 0})
+	mustApply(t, g, Action{Type: ActionReplace, Seat: 0, Slot: 3})
+	if g.Snapshot(0).Hands[0][3] == nil || g.Snapshot(1).Hands[0][3] != nil {
+		t.Errorf("Card drawn from the deck should only be known to the drawing player")
+	}
+	for _, hand := range g.Snapshot(Omniscient).Hands {
+		for _, card := range hand {
+			if card == nil {
+				t.Errorf("Omniscient snapshot should reveal every card")
+			}
+		}
+	}
+	if *g.Snapshot(0).Hands[0][0] != known {
+		t.Errorf("Peeked card should still be known")
+	}
+}
+
+func mustApply(t *testing.T, g *Game, a Action) []Event {
+	events, err := g.Apply(a)
+	if err != nil {
+		t.Fatalf("Failed applying %v, %v", a, err)
+	}
+	return events
+}
+
+func Test_ReplayRejectsIllegalHistory(t *testing.T) {
+	actions := []Action{{Type: ActionDrawFromDeck, Seat: 0}, {Type: ActionDrawFromDeck, Seat: 0}}
+	if _, _, err := Replay("seed", 2, DefaultRuleset(), actions); !errors.Is(err, ErrIllegalAction) {
+		t.Errorf("Should have failed replaying an illegal history, got %v", err)
+	}
+}
+
+// Test_ShuffleFollowsPublishedAlgorithm expected orders computed independently from the algorithm documented on shuffler
+func Test_ShuffleFollowsPublishedAlgorithm(t *testing.T) {
+	tests := []struct {
+		seed     string
+		round    int
+		cards    int
+		expected []int
+	}{
+		{"seed", 1, 10, []int{5, 4, 2, 0, 7, 9, 3, 1, 6, 8}},
+		{"kaboo", 2, 54, []int{2, 43, 22, 47, 14, 36, 30, 21, 12, 15}},
+	}
+	for _, test := range tests {
+		cards := make([]Card, test.cards)
+		for i := range cards {
+			cards[i].ID = i
+		}
+		newShuffler(test.seed, test.round).shuffle(cards)
+		for i, id := range test.expected {
+			if cards[i].ID != id {
+				t.Errorf("Seed %q round %d: card %d should be %d, got %d", test.seed, test.round, i, id, cards[i].ID)
+			}
+		}
+	}
+}
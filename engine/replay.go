@@ -0,0 +1,40 @@
+package engine
+
+import "fmt"
+
+// Replay rebuilds a game from its seed and the actions applied to it,
+// returning the game along with every event in order
+func Replay(seed string, players int, rules Ruleset, actions []Action) (*Game, []Event, error) {
+	g, events, err := NewGame(seed, players, rules)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, a := range actions {
+		actionEvents, err := g.Apply(a)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed replaying action %d (%v), %w", i, a.Type, err)
+		}
+		events = append(events, actionEvents...)
+	}
+	return g, events, nil
+}
+
+// ReplayStep rebuilds a game up to the given step, step 0 being the deal and
+// step n the n-th action, returning the game and the events of that step alone
+func ReplayStep(seed string, players int, rules Ruleset, actions []Action, step int) (*Game, []Event, error) {
+	if step < 0 || step > len(actions) {
+		return nil, nil, ErrInvalidStep
+	}
+	if step == 0 {
+		return NewGame(seed, players, rules)
+	}
+	g, _, err := Replay(seed, players, rules, actions[:step-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := g.Apply(actions[step-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, events, nil
+}
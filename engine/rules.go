@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Ruleset limits
+const (
+	// MaxHandSize most cards that can be dealt to each player, a full table still leaves a deck to draw from
+	MaxHandSize = 6
+	// MaxPenalty highest Kaboo or snap penalty
+	MaxPenalty = 50
+	// MinTargetScore lowest score a game can be played to
+	MinTargetScore = 10
+	// MaxTargetScore highest score a game can be played to
+	MaxTargetScore = 500
+)
+
+// ErrInvalidRuleset the ruleset has an out of range or unknown setting
+var ErrInvalidRuleset = errors.New("Invalid ruleset")
+
+var rankNames = [...]string{"Joker", "A", "2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K"}
+
+// String returns the rank name as used by rulesets, e.g. "7", "J" or "Joker"
+func (r Rank) String() string {
+	if r < RankJoker || r > RankKing {
+		return fmt.Sprintf("Rank(%d)", int(r))
+	}
+	return rankNames[r]
+}
+
+// ParseRank returns the rank with the given name
+func ParseRank(name string) (Rank, bool) {
+	for rank, rankName := range rankNames {
+		if rankName == name {
+			return Rank(rank), true
+		}
+	}
+	return RankJoker, false
+}
+
+// Ruleset the house rules a game is played by
+type Ruleset struct {
+	// HandSize cards dealt to each player
+	HandSize int `json:"handSize" bson:"hand_size"`
+	// InitialPeekCount own cards each player sees when the round is dealt
+	InitialPeekCount int `json:"initialPeekCount" bson:"initial_peek_count"`
+	// Jokers adds two jokers to the deck
+	Jokers bool `json:"jokers" bson:"jokers"`
+	// Snapping lets any player throw a card matching the discard pile's top card before the next draw,
+	// a wrong snap adds SnapPenalty points to the player's round score
+	Snapping    bool `json:"snapping" bson:"snapping"`
+	SnapPenalty int  `json:"snapPenalty" bson:"snap_penalty"`
+	// Powers the power granted by discarding each rank, keyed by rank name
+	Powers map[string]Power `json:"powers" bson:"powers"`
+	// KabooPenalty points added to a Kaboo caller that doesn't have the lowest hand
+	KabooPenalty int `json:"kabooPenalty" bson:"kaboo_penalty"`
+	// TargetScore the game ends once a player reaches this score
+	TargetScore int `json:"targetScore" bson:"target_score"`
+}
+
+// DefaultRuleset the standard Kaboo rules
+func DefaultRuleset() Ruleset {
+	return Ruleset{
+		HandSize:         DefaultHandSize,
+		InitialPeekCount: DefaultInitialPeekCount,
+		Jokers:           true,
+		Snapping:         false,
+		SnapPenalty:      DefaultSnapPenalty,
+		Powers: map[string]Power{
+			RankSeven.String(): PowerPeek,
+			RankEight.String(): PowerPeek,
+			RankNine.String():  PowerSpy,
+			RankTen.String():   PowerSpy,
+			RankJack.String():  PowerBlindSwap,
+			RankQueen.String(): PowerBlindSwap,
+			RankKing.String():  PowerLookAndSwap,
+		},
+		KabooPenalty: DefaultKabooPenalty,
+		TargetScore:  DefaultTargetScore,
+	}
+}
+
+// OrDefault returns the default ruleset in place of an unset one
+func (r Ruleset) OrDefault() Ruleset {
+	if r.HandSize == 0 {
+		return DefaultRuleset()
+	}
+	return r
+}
+
+// Power returns the power discarding a card of the given rank grants
+func (r Ruleset) Power(rank Rank) Power {
+	return r.Powers[rank.String()]
+}
+
+// DeckSize returns the number of cards in the deck
+func (r Ruleset) DeckSize() int {
+	if r.Jokers {
+		return 52 + JokersInDeck
+	}
+	return 52
+}
+
+// Validate returns an error describing the first invalid setting
+func (r Ruleset) Validate() error {
+	switch {
+	case r.HandSize < 1 || r.HandSize > MaxHandSize:
+		return fmt.Errorf("%w: hand size must be between 1 and %d", ErrInvalidRuleset, MaxHandSize)
+	case r.InitialPeekCount < 0 || r.InitialPeekCount > r.HandSize:
+		return fmt.Errorf("%w: initial peek count must be between 0 and the hand size", ErrInvalidRuleset)
+	case r.SnapPenalty < 0 || r.SnapPenalty > MaxPenalty:
+		return fmt.Errorf("%w: snap penalty must be between 0 and %d", ErrInvalidRuleset, MaxPenalty)
+	case r.KabooPenalty < 0 || r.KabooPenalty > MaxPenalty:
+		return fmt.Errorf("%w: Kaboo penalty must be between 0 and %d", ErrInvalidRuleset, MaxPenalty)
+	case r.TargetScore < MinTargetScore || r.TargetScore > MaxTargetScore:
+		return fmt.Errorf("%w: target score must be between %d and %d", ErrInvalidRuleset, MinTargetScore, MaxTargetScore)
+	}
+	for name, power := range r.Powers {
+		if _, ok := ParseRank(name); !ok {
+			return fmt.Errorf("%w: unknown rank %q", ErrInvalidRuleset, name)
+		}
+		switch power {
+		case PowerNone, PowerPeek, PowerSpy, PowerBlindSwap, PowerLookAndSwap:
+		default:
+			return fmt.Errorf("%w: unknown power %q", ErrInvalidRuleset, power)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// shuffler shuffles the cards of a round with a Fisher–Yates shuffle driven by SHA-256, so anyone
+// can verify a deal from the revealed seed without depending on a particular random generator:
+//
+//  1. The byte stream of round r is SHA-256(seed ":" r ":" 0) || SHA-256(seed ":" r ":" 1) || ...
+//     with r and the block counter written in decimal.
+//  2. The stream is read as consecutive big endian uint64 values.
+//  3. A uniform index below n takes values until one is below 2^64 - (2^64 mod n) and returns it mod n.
+//  4. The deck, from bottom to top, is shuffled by swapping every position i from the top one down to 1
+//     with a uniform index below i+1.
+//
+// Reshuffling the discard pile into the deck within a round continues the round's stream
+type shuffler struct {
+	seed    string
+	round   int
+	counter int
+	block   []byte
+}
+
+func newShuffler(seed string, round int) *shuffler {
+	return &shuffler{seed: seed, round: round}
+}
+
+// shuffle shuffles the cards in place
+func (s *shuffler) shuffle(cards []Card) {
+	for i := len(cards) - 1; i > 0; i-- {
+		j := s.index(uint64(i + 1))
+		cards[i], cards[j] = cards[j], cards[i]
+	}
+}
+
+// index returns a uniform index below n
+func (s *shuffler) index(n uint64) int {
+	// 2^64 - (2^64 mod n), values from it on would favor the lower indexes
+	limit := -(-n % n)
+	for {
+		if v := s.next(); limit == 0 || v < limit {
+			return int(v % n)
+		}
+	}
+}
+
+// next returns the next uint64 of the stream
+func (s *shuffler) next() uint64 {
+	if len(s.block) == 0 {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d", s.seed, s.round, s.counter)))
+		s.block = sum[:]
+		s.counter++
+	}
+	v := binary.BigEndian.Uint64(s.block)
+	s.block = s.block[8:]
+	return v
+}
@@ -0,0 +1,77 @@
+package engine
+
+// PlayerView the public game state plus the hidden information a single seat
+// is currently holding. Cards peeked at earlier are not part of the view, a
+// player has to remember them.
+type PlayerView struct {
+	Seat        int   `json:"seat"`
+	Players     int   `json:"players"`
+	Round       int   `json:"round"`
+	Turn        int   `json:"turn"`
+	Phase       Phase `json:"phase"`
+	CurrentSeat int   `json:"currentSeat"`
+	Power       Power `json:"power,omitempty"`
+	HandSizes   []int `json:"handSizes"`
+	DeckSize    int   `json:"deckSize"`
+	DiscardTop  *Card `json:"discardTop,omitempty"`
+	Drawn       *Card `json:"drawn,omitempty"`
+	DrawnFrom   Pile  `json:"drawnFrom,omitempty"`
+	KabooCaller int   `json:"kabooCaller"`
+	Scores      []int `json:"scores"`
+	Winners     []int `json:"winners,omitempty"`
+}
+
+// View returns the game as seen by the given seat
+func (g *Game) View(seat int) PlayerView {
+	v := PlayerView{
+		Seat:        seat,
+		Players:     g.players,
+		Round:       g.round,
+		Turn:        g.turn,
+		Phase:       g.phase,
+		CurrentSeat: g.current,
+		Power:       g.power,
+		HandSizes:   make([]int, g.players),
+		DeckSize:    len(g.deck),
+		KabooCaller: g.kabooCaller,
+		Scores:      g.Scores(),
+		Winners:     g.Winners(),
+	}
+	for i, hand := range g.hands {
+		v.HandSizes[i] = len(hand)
+	}
+	if len(g.discard) > 0 {
+		top := g.discard[len(g.discard)-1]
+		v.DiscardTop = &top
+	}
+	if g.drawn != nil {
+		v.DrawnFrom = g.drawnFrom
+		if seat == g.current || seat == Omniscient || g.drawnFrom == PileDiscard {
+			drawn := *g.drawn
+			v.Drawn = &drawn
+		}
+	}
+	return v
+}
+
+// Snapshot the game state along with every hand card the viewer knows
+type Snapshot struct {
+	PlayerView
+	Hands [][]*Card `json:"hands"`
+}
+
+// Snapshot returns the game as known by the given seat, cards the seat has
+// seen are revealed even if they have been moved since. Omniscient reveals every card.
+func (g *Game) Snapshot(viewer int) Snapshot {
+	snapshot := Snapshot{PlayerView: g.View(viewer), Hands: make([][]*Card, g.players)}
+	for seat, hand := range g.hands {
+		snapshot.Hands[seat] = make([]*Card, len(hand))
+		for slot, card := range hand {
+			if viewer == Omniscient || (viewer >= 0 && viewer < g.players && g.knowledge[viewer][card.ID]) {
+				c := card
+				snapshot.Hands[seat][slot] = &c
+			}
+		}
+	}
+	return snapshot
+}
@@ -11,7 +11,9 @@ require (
 	github.com/sirupsen/logrus v1.5.0
 	github.com/stretchr/testify v1.5.1 // indirect
 	github.com/urfave/cli/v2 v2.2.0
+	github.com/vmihailenco/msgpack/v4 v4.3.13
 	go.mongodb.org/mongo-driver v1.3.1
 	golang.org/x/crypto v0.0.0-20200323165209-0ec3e9974c59 // indirect
+	golang.org/x/time v0.0.0-20190921001708-c4c64cad1fd0
 	gopkg.in/square/go-jose.v2 v2.4.1
 )
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// ChatHistoryLength number of chat messages kept with a game
+	ChatHistoryLength = 100
+)
+
+// ChatMessage a message sent in a game's chat
+type ChatMessage struct {
+	ID       primitive.ObjectID `bson:"id"`
+	UserID   primitive.ObjectID `bson:"user_id"`
+	Username string             `bson:"username"`
+	Text     string             `bson:"text"`
+	SentAt   time.Time          `bson:"sent_at"`
+}
+
+// IsMuted returns whether the owner muted the user in the game's chat
+func (g *KabooGame) IsMuted(userID primitive.ObjectID) bool {
+	for _, muted := range g.Muted {
+		if muted == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendChatMessage stores a chat message with the game, only the latest ChatHistoryLength messages are kept
+func (g *GamesDAO) AppendChatMessage(game *KabooGame, message *ChatMessage) error {
+	g.gmtx.Lock()
+	defer g.gmtx.Unlock()
+
+	update := bson.M{"$push": bson.M{"chat": bson.M{"$each": []*ChatMessage{message}, "$slice": -ChatHistoryLength}}}
+	if _, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, update); err != nil {
+		return err
+	}
+	game.Chat = append(game.Chat, *message)
+	if len(game.Chat) > ChatHistoryLength {
+		game.Chat = game.Chat[len(game.Chat)-ChatHistoryLength:]
+	}
+	return nil
+}
+
+// SetUserMuted mutes or unmutes a user in the game's chat
+func (g *GamesDAO) SetUserMuted(game *KabooGame, userID primitive.ObjectID, muted bool) error {
+	g.gmtx.Lock()
+	defer g.gmtx.Unlock()
+
+	mutedUsers := []primitive.ObjectID{}
+	for _, id := range game.Muted {
+		if id != userID {
+			mutedUsers = append(mutedUsers, id)
+		}
+	}
+	if muted {
+		mutedUsers = append(mutedUsers, userID)
+	}
+	if _, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"muted": mutedUsers}}); err != nil {
+		return err
+	}
+	game.Muted = mutedUsers
+	return nil
+}
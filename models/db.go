@@ -15,6 +15,12 @@ type Db struct {
 	database *mongo.Database
 	GamesDAO *GamesDAO
 	UserDAO  *UserDAO
+	// GameEventsDAO game event logs
+	GameEventsDAO *GameEventsDAO
+	// LeaderboardDAO precomputed leaderboards
+	LeaderboardDAO *LeaderboardDAO
+	// FriendsDAO the social graph
+	FriendsDAO *FriendsDAO
 }
 
 // Open a new connection to the db and sets the the client
@@ -38,5 +44,31 @@ func (d *Db) Open(uri string, dbname string) {
 	d.UserDAO = &UserDAO{
 		collection: d.database.Collection(UserCollection),
 	}
+	d.GameEventsDAO = &GameEventsDAO{
+		collection: d.database.Collection(GameEventsCollection),
+	}
+	d.LeaderboardDAO = &LeaderboardDAO{
+		database: d.database,
+		games:    d.GamesDAO.collection,
+	}
+	d.FriendsDAO = &FriendsDAO{
+		collection: d.database.Collection(FriendshipsCollection),
+	}
+	if err := d.FriendsDAO.EnsureIndices(); err != nil {
+		log.Fatal(err)
+		return
+	}
+	if err := d.UserDAO.EnsureIndices(); err != nil {
+		log.Fatal(err)
+		return
+	}
+	if err := d.GamesDAO.EnsureIndices(); err != nil {
+		log.Fatal(err)
+		return
+	}
+	if err := d.GameEventsDAO.EnsureIndices(); err != nil {
+		log.Fatal(err)
+		return
+	}
 	log.Infof("Connected to MongoDB (%v)\n", uri)
 }
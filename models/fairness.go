@@ -0,0 +1,67 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// MaxEntropyLength maximal length of a player entropy contribution
+	MaxEntropyLength = 128
+)
+
+var (
+	// ErrEntropyTooLong entropy contribution is too long
+	ErrEntropyTooLong = errors.New("Entropy is too long")
+)
+
+// PlayerEntropy a value contributed by a player that is mixed into the shuffle seed
+type PlayerEntropy struct {
+	UserID primitive.ObjectID `bson:"user_id"`
+	Value  string             `bson:"value"`
+}
+
+// SeedCommitment returns the published commitment of a seed, the hex encoded SHA-256 of the seed
+func SeedCommitment(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// MixSeed derives the shuffle seed from the server seed and the players entropy
+// contributions, each prefixed with its length so they can't be confused:
+// base64url(SHA-256(seed "|" len(e1) ":" e1 "|" len(e2) ":" e2 ...))
+func MixSeed(seed string, entropy []string) string {
+	h := sha256.New()
+	h.Write([]byte(seed))
+	for _, value := range entropy {
+		fmt.Fprintf(h, "|%d:%s", len(value), value)
+	}
+	return base64.URLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// EntropyValues returns the entropy contributions of a game in the order they were made
+func (g *KabooGame) EntropyValues() []string {
+	values := make([]string, len(g.Entropy))
+	for i, e := range g.Entropy {
+		values[i] = e.Value
+	}
+	return values
+}
+
+// ShuffleSeed returns the seed the engine deals from, the game seed mixed with the players entropy
+func (g *KabooGame) ShuffleSeed() string {
+	return MixSeed(g.Seed, g.EntropyValues())
+}
+
+// RevealedSeed returns the game seed once the game ended, empty before that
+func (g *KabooGame) RevealedSeed() string {
+	if g.State != GameStateEnded {
+		return ""
+	}
+	return g.Seed
+}
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func Test_SeedCommitment(t *testing.T) {
+	// echo -n "seed" | sha256sum
+	if c := SeedCommitment("seed"); c != "19b25856e1c150ca834cffc8b59b23adbd0ec0389e58eb22b3b64768098d002b" {
+		t.Errorf("Unexpected commitment %v", c)
+	}
+}
+
+func Test_MixSeed(t *testing.T) {
+	base := MixSeed("seed", nil)
+	if base == MixSeed("seed", []string{"a"}) {
+		t.Errorf("Entropy should change the shuffle seed")
+	}
+	if MixSeed("seed", []string{"a", "b"}) == MixSeed("seed", []string{"b", "a"}) {
+		t.Errorf("Entropy order should matter")
+	}
+	if MixSeed("seed", []string{"a|b"}) == MixSeed("seed", []string{"a", "b"}) {
+		t.Errorf("Contributions shouldn't be ambiguous")
+	}
+}
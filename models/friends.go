@@ -0,0 +1,255 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// FriendshipsCollection name of the friendships collection
+	FriendshipsCollection = "friendships"
+)
+
+// FriendshipStatus state of the relation between two users
+type FriendshipStatus string
+
+// Friendship statuses
+const (
+	FriendshipPending  FriendshipStatus = "pending"
+	FriendshipAccepted FriendshipStatus = "accepted"
+	FriendshipBlocked  FriendshipStatus = "blocked"
+)
+
+var (
+	// ErrFriendRequestToSelf users can't befriend themselves
+	ErrFriendRequestToSelf = errors.New("Can't send a friend request to yourself")
+	// ErrAlreadyFriends the users are already friends
+	ErrAlreadyFriends = errors.New("Already friends")
+	// ErrFriendRequestPending a request was already sent and wasn't answered yet
+	ErrFriendRequestPending = errors.New("Friend request already sent")
+	// ErrNoFriendRequest there's no pending request from the user
+	ErrNoFriendRequest = errors.New("No pending friend request from user")
+	// ErrNotFriends the users aren't friends
+	ErrNotFriends = errors.New("Not friends")
+	// ErrUserBlocked one of the users blocked the other
+	ErrUserBlocked = errors.New("User is blocked")
+	// ErrNotBlocked the user didn't block the other user
+	ErrNotBlocked = errors.New("User isn't blocked")
+)
+
+// Friendship the relation between two users, stored once per pair with UserA < UserB
+type Friendship struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserA       primitive.ObjectID `bson:"user_a"`
+	UserB       primitive.ObjectID `bson:"user_b"`
+	Status      FriendshipStatus   `bson:"status"`
+	RequestedBy primitive.ObjectID `bson:"requested_by"`
+	// BlockedBy the user that blocked the other, only set for blocked relations
+	BlockedBy primitive.ObjectID `bson:"blocked_by,omitempty"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+// Other returns the other user of the relation
+func (f *Friendship) Other(user primitive.ObjectID) primitive.ObjectID {
+	if f.UserA == user {
+		return f.UserB
+	}
+	return f.UserA
+}
+
+// orderedPair returns the two users ordered the way friendships are keyed
+func orderedPair(a primitive.ObjectID, b primitive.ObjectID) (primitive.ObjectID, primitive.ObjectID) {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		return b, a
+	}
+	return a, b
+}
+
+func pairFilter(a primitive.ObjectID, b primitive.ObjectID) bson.M {
+	userA, userB := orderedPair(a, b)
+	return bson.M{"user_a": userA, "user_b": userB}
+}
+
+// FriendsDAO the social graph between users
+type FriendsDAO struct {
+	collection *mongo.Collection
+}
+
+// EnsureIndices creates the friendship indices, there's a single relation per pair of users
+func (d *FriendsDAO) EnsureIndices() error {
+	_, err := d.collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_a", Value: 1}, {Key: "user_b", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_b", Value: 1}}},
+	})
+	return err
+}
+
+// FetchFriendship returns the relation between two users, nil if there's none
+func (d *FriendsDAO) FetchFriendship(a primitive.ObjectID, b primitive.ObjectID) (*Friendship, error) {
+	var friendship Friendship
+	err := d.collection.FindOne(context.Background(), pairFilter(a, b)).Decode(&friendship)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &friendship, nil
+}
+
+// FetchFriendships returns every relation the user is part of
+func (d *FriendsDAO) FetchFriendships(user primitive.ObjectID) (friendships []*Friendship, err error) {
+	filter := bson.M{"$or": bson.A{bson.M{"user_a": user}, bson.M{"user_b": user}}}
+	cursor, err := d.collection.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &friendships)
+	return friendships, err
+}
+
+// FetchFriends returns the ids of the user's accepted friends
+func (d *FriendsDAO) FetchFriends(user primitive.ObjectID) ([]primitive.ObjectID, error) {
+	friendships, err := d.FetchFriendships(user)
+	if err != nil {
+		return nil, err
+	}
+	var friends []primitive.ObjectID
+	for _, friendship := range friendships {
+		if friendship.Status == FriendshipAccepted {
+			friends = append(friends, friendship.Other(user))
+		}
+	}
+	return friends, nil
+}
+
+// AreFriends returns whether the two users are friends
+func (d *FriendsDAO) AreFriends(a primitive.ObjectID, b primitive.ObjectID) (bool, error) {
+	friendship, err := d.FetchFriendship(a, b)
+	if err != nil {
+		return false, err
+	}
+	return friendship != nil && friendship.Status == FriendshipAccepted, nil
+}
+
+// RequestFriendship sends a friend request from one user to another, if the other user
+// already asked to be friends the request is accepted instead. Returns the resulting status
+func (d *FriendsDAO) RequestFriendship(from primitive.ObjectID, to primitive.ObjectID) (FriendshipStatus, error) {
+	if from == to {
+		return "", ErrFriendRequestToSelf
+	}
+	friendship, err := d.FetchFriendship(from, to)
+	if err != nil {
+		return "", err
+	}
+	if friendship != nil {
+		switch {
+		case friendship.Status == FriendshipBlocked:
+			return "", ErrUserBlocked
+		case friendship.Status == FriendshipAccepted:
+			return "", ErrAlreadyFriends
+		case friendship.RequestedBy == from:
+			return "", ErrFriendRequestPending
+		}
+		return FriendshipAccepted, d.setStatus(friendship, FriendshipAccepted)
+	}
+	userA, userB := orderedPair(from, to)
+	_, err = d.collection.InsertOne(context.Background(), &Friendship{
+		UserA:       userA,
+		UserB:       userB,
+		Status:      FriendshipPending,
+		RequestedBy: from,
+		UpdatedAt:   time.Now(),
+	})
+	if isDuplicateKeyError(err) {
+		return "", ErrFriendRequestPending
+	}
+	return FriendshipPending, err
+}
+
+// RespondToFriendRequest accepts or declines the pending request the user received from another user
+func (d *FriendsDAO) RespondToFriendRequest(user primitive.ObjectID, from primitive.ObjectID, accept bool) error {
+	friendship, err := d.FetchFriendship(user, from)
+	if err != nil {
+		return err
+	}
+	if friendship == nil || friendship.Status != FriendshipPending || friendship.RequestedBy != from {
+		return ErrNoFriendRequest
+	}
+	if !accept {
+		_, err = d.collection.DeleteOne(context.Background(), bson.M{"_id": friendship.ID})
+		return err
+	}
+	return d.setStatus(friendship, FriendshipAccepted)
+}
+
+// RemoveFriend ends a friendship, or cancels a pending request in either direction
+func (d *FriendsDAO) RemoveFriend(user primitive.ObjectID, other primitive.ObjectID) error {
+	filter := pairFilter(user, other)
+	filter["status"] = bson.M{"$in": bson.A{FriendshipAccepted, FriendshipPending}}
+	res, err := d.collection.DeleteOne(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFriends
+	}
+	return nil
+}
+
+// BlockUser blocks another user, replacing any friendship or request between them
+func (d *FriendsDAO) BlockUser(user primitive.ObjectID, other primitive.ObjectID) error {
+	if user == other {
+		return ErrFriendRequestToSelf
+	}
+	friendship, err := d.FetchFriendship(user, other)
+	if err != nil {
+		return err
+	}
+	if friendship != nil && friendship.Status == FriendshipBlocked {
+		// Already blocked, possibly by the other user which keeps him the only one able to unblock
+		return nil
+	}
+	userA, userB := orderedPair(user, other)
+	_, err = d.collection.UpdateOne(context.Background(), pairFilter(user, other), bson.M{"$set": bson.M{
+		"user_a":       userA,
+		"user_b":       userB,
+		"status":       FriendshipBlocked,
+		"requested_by": user,
+		"blocked_by":   user,
+		"updated_at":   time.Now(),
+	}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// UnblockUser removes a block the user placed on another user
+func (d *FriendsDAO) UnblockUser(user primitive.ObjectID, other primitive.ObjectID) error {
+	filter := pairFilter(user, other)
+	filter["status"] = FriendshipBlocked
+	filter["blocked_by"] = user
+	res, err := d.collection.DeleteOne(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotBlocked
+	}
+	return nil
+}
+
+func (d *FriendsDAO) setStatus(friendship *Friendship, status FriendshipStatus) error {
+	friendship.Status = status
+	friendship.UpdatedAt = time.Now()
+	_, err := d.collection.UpdateOne(context.Background(), bson.M{"_id": friendship.ID},
+		bson.M{"$set": bson.M{"status": status, "updated_at": friendship.UpdatedAt}})
+	return err
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_FriendshipPairIsOrdered(t *testing.T) {
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	if pairFilter(a, b)["user_a"] != pairFilter(b, a)["user_a"] {
+		t.Errorf("Both directions should map to the same relation")
+	}
+	friendship := Friendship{UserA: a, UserB: b}
+	if friendship.Other(a) != b || friendship.Other(b) != a {
+		t.Errorf("Unexpected other user")
+	}
+}
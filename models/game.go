@@ -4,9 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/ngutman/kaboo-server-go/engine"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 
@@ -15,6 +18,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrGameFull every seat of the game is taken
+var ErrGameFull = errors.New("Too many players in game")
+
 // GameState game state type
 type GameState int
 
@@ -43,6 +49,60 @@ type KabooGame struct {
 	Name       string               `bson:"name"`
 	Password   string               `bson:"password"`
 	Seed       string               `bson:"seed"`
+	Bots       []BotPlayer          `bson:"bots"`
+	// SeedCommitment published at creation, lets players verify Seed once it's revealed
+	SeedCommitment string          `bson:"seed_commitment"`
+	Entropy        []PlayerEntropy `bson:"entropy"`
+	// Spectators watching the game, they aren't seated
+	Spectators        []primitive.ObjectID `bson:"spectators"`
+	SpectatorSettings SpectatorSettings    `bson:"spectator_settings"`
+	// Chat latest chat messages, Muted users the owner silenced
+	Chat  []ChatMessage        `bson:"chat"`
+	Muted []primitive.ObjectID `bson:"muted"`
+	// Unrated games don't affect the players rating
+	Unrated bool `bson:"unrated"`
+	// Rules the house rules the game is played by, see Ruleset
+	Rules engine.Ruleset `bson:"rules"`
+	// Visibility who can find the game, Banned users the owner kicked for good
+	Visibility GameVisibility       `bson:"visibility"`
+	Banned     []primitive.ObjectID `bson:"banned"`
+	// Results every seat's outcome, set once the game ended
+	Results []PlayerResult `bson:"results"`
+	EndedAt time.Time      `bson:"ended_at"`
+	// AbandonedBy the player who left the game before it ended, ending it without results
+	AbandonedBy primitive.ObjectID `bson:"abandoned_by,omitempty"`
+}
+
+// BotPlayer a computer player seated in a game, bots are seated after the human players
+type BotPlayer struct {
+	ID         primitive.ObjectID `bson:"id"`
+	Name       string             `bson:"name"`
+	Difficulty string             `bson:"difficulty"`
+}
+
+// IsRated returns whether the game's result updates the players rating, games
+// with bots are only rated when includeBotGames is set. Abandoned games are never rated
+func (g *KabooGame) IsRated(includeBotGames bool) bool {
+	return !g.Unrated && !g.IsAbandoned() && (includeBotGames || len(g.Bots) == 0)
+}
+
+// IsAbandoned returns whether a player left the game before it ended
+func (g *KabooGame) IsAbandoned() bool {
+	return !g.AbandonedBy.IsZero()
+}
+
+// PlayerCount returns the number of seated players, bots included
+func (g *KabooGame) PlayerCount() int {
+	return len(g.Players) + len(g.Bots)
+}
+
+// Seats returns the ids of every seated player in seating order
+func (g *KabooGame) Seats() []primitive.ObjectID {
+	seats := append([]primitive.ObjectID(nil), g.Players...)
+	for _, bot := range g.Bots {
+		seats = append(seats, bot.ID)
+	}
+	return seats
 }
 
 // GamesDAO is handling all game related actions against the db
@@ -51,32 +111,45 @@ type GamesDAO struct {
 	gmtx       sync.Mutex
 }
 
-// CreateGame creates a game for the given user
-func (g *GamesDAO) CreateGame(owner *User, name string, maxPlayers int, password string) (*KabooGame, error) {
+// NewKabooGame returns a game owned by the given user waiting for players, with a fresh
+// seed and the default settings. It's stored once its settings are set with CreateGame
+func NewKabooGame(owner *User, name string, maxPlayers int, password string) (*KabooGame, error) {
 	seed, err := generateGameSeed()
 	log.Tracef("Generated seed - %v\n", seed)
 	if err != nil {
 		log.Errorf("Error generating level seed, %v\n", err)
 		return nil, err
 	}
-	game := KabooGame{
-		primitive.NilObjectID,
-		owner.ID,
-		GameStateWaitingForPlayers,
-		true,
-		[]primitive.ObjectID{owner.ID},
-		maxPlayers,
-		name,
-		password,
-		seed,
-	}
+	return &KabooGame{
+		Owner:          owner.ID,
+		State:          GameStateWaitingForPlayers,
+		Active:         true,
+		Players:        []primitive.ObjectID{owner.ID},
+		MaxPlayers:     maxPlayers,
+		Name:           name,
+		Password:       password,
+		Seed:           seed,
+		Bots:           []BotPlayer{},
+		SeedCommitment: SeedCommitment(seed),
+		Entropy:        []PlayerEntropy{},
+		Spectators:     []primitive.ObjectID{},
+		Chat:           []ChatMessage{},
+		Muted:          []primitive.ObjectID{},
+		Rules:          engine.DefaultRuleset(),
+		Visibility:     GameVisibilityPublic,
+		Banned:         []primitive.ObjectID{},
+	}, nil
+}
+
+// CreateGame stores a game built by NewKabooGame in a single insert, setting its id
+func (g *GamesDAO) CreateGame(game *KabooGame) error {
 	res, err := g.collection.InsertOne(context.Background(), game)
 	if err != nil {
-		log.Fatalf("Couldn't insert level to db, %v\n", err)
-		return nil, err
+		log.Errorf("Couldn't insert game to db, %v\n", err)
+		return err
 	}
 	game.ID = res.InsertedID.(primitive.ObjectID)
-	return &game, nil
+	return nil
 }
 
 // FetchActiveGames returns active games from the db
@@ -95,6 +168,15 @@ func (g *GamesDAO) FetchActiveGames() (results []*KabooGame, err error) {
 	return results, nil
 }
 
+// FetchGameByID returns a game, active or not
+func (g *GamesDAO) FetchGameByID(gameID primitive.ObjectID) (*KabooGame, error) {
+	var game KabooGame
+	if err := g.collection.FindOne(context.Background(), bson.M{"_id": gameID}).Decode(&game); err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
 // IsPlayerInActiveGame returns if given player is participating in any active game
 func (g *GamesDAO) IsPlayerInActiveGame(user primitive.ObjectID) bool {
 	filter := bson.M{"players": user, "active": true}
@@ -105,13 +187,25 @@ func (g *GamesDAO) IsPlayerInActiveGame(user primitive.ObjectID) bool {
 	return count > 0
 }
 
+// FetchActiveGameOfPlayer returns the active game the player is seated in, nil if there's none
+func (g *GamesDAO) FetchActiveGameOfPlayer(user primitive.ObjectID) (*KabooGame, error) {
+	var game KabooGame
+	err := g.collection.FindOne(context.Background(), bson.M{"players": user, "active": true}).Decode(&game)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &game, nil
+}
+
 // TryToAddPlayerToGame attempts to add the player to the given game, will fail if there are too many players
 func (g *GamesDAO) TryToAddPlayerToGame(game *KabooGame, user *User) (bool, error) {
 	g.gmtx.Lock()
 	defer g.gmtx.Unlock()
 
-	if len(game.Players) >= game.MaxPlayers {
-		return false, fmt.Errorf("Too many players in game (maximum %d, current %d)", game.MaxPlayers, len(game.Players))
+	if game.PlayerCount() >= game.MaxPlayers {
+		return false, fmt.Errorf("%w (maximum %d, current %d)", ErrGameFull, game.MaxPlayers, game.PlayerCount())
 	}
 
 	game.Players = append(game.Players, user.ID)
@@ -122,6 +216,67 @@ func (g *GamesDAO) TryToAddPlayerToGame(game *KabooGame, user *User) (bool, erro
 	return true, nil
 }
 
+// TryToAddBotToGame attempts to seat a bot in the given game, will fail if there are too many players
+func (g *GamesDAO) TryToAddBotToGame(game *KabooGame, bot BotPlayer) (bool, error) {
+	g.gmtx.Lock()
+	defer g.gmtx.Unlock()
+
+	if game.PlayerCount() >= game.MaxPlayers {
+		return false, fmt.Errorf("%w (maximum %d, current %d)", ErrGameFull, game.MaxPlayers, game.PlayerCount())
+	}
+
+	game.Bots = append(game.Bots, bot)
+	if _, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"bots": game.Bots}}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AddPlayerEntropy records a player's entropy contribution, it is mixed into the shuffle seed
+func (g *GamesDAO) AddPlayerEntropy(game *KabooGame, user *User, entropy string) error {
+	if len(entropy) > MaxEntropyLength {
+		return ErrEntropyTooLong
+	}
+	g.gmtx.Lock()
+	defer g.gmtx.Unlock()
+
+	game.Entropy = append(game.Entropy, PlayerEntropy{UserID: user.ID, Value: entropy})
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"entropy": game.Entropy}})
+	return err
+}
+
+// Ruleset returns the rules the game is played by, games created before house rules existed use the defaults
+func (g *KabooGame) Ruleset() engine.Ruleset {
+	return g.Rules.OrDefault()
+}
+
+// AbandonGame ends a running game the given player left, no results are recorded
+func (g *GamesDAO) AbandonGame(game *KabooGame, userID primitive.ObjectID) error {
+	game.AbandonedBy = userID
+	game.State = GameStateEnded
+	game.Active = false
+	game.EndedAt = time.Now()
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{
+		"abandoned_by": game.AbandonedBy, "state": game.State, "active": game.Active, "ended_at": game.EndedAt}})
+	return err
+}
+
+// DeleteGame removes a game that never started
+func (g *GamesDAO) DeleteGame(game *KabooGame) error {
+	_, err := g.collection.DeleteOne(context.Background(), bson.M{"_id": game.ID})
+	return err
+}
+
+// UpdateGameState sets the game state, ended games are no longer active
+func (g *GamesDAO) UpdateGameState(game *KabooGame, state GameState) error {
+	game.State = state
+	game.Active = state != GameStateEnded
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID},
+		bson.M{"$set": bson.M{"state": game.State, "active": game.Active}})
+	return err
+}
+
 func generateGameSeed() (seed string, err error) {
 	b := make([]byte, GameSeedLength)
 	_, err = rand.Read(b)
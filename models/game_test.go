@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_IsRated(t *testing.T) {
+	tests := []struct {
+		game            KabooGame
+		includeBotGames bool
+		rated           bool
+	}{
+		{KabooGame{}, false, true},
+		{KabooGame{Unrated: true}, false, false},
+		{KabooGame{Bots: []BotPlayer{{}}}, false, false},
+		{KabooGame{Bots: []BotPlayer{{}}}, true, true},
+		// A player left before the game ended
+		{KabooGame{AbandonedBy: primitive.NewObjectID()}, false, false},
+		{KabooGame{AbandonedBy: primitive.NewObjectID(), Bots: []BotPlayer{{}}}, true, false},
+	}
+	for i, test := range tests {
+		if test.game.IsRated(test.includeBotGames) != test.rated {
+			t.Errorf("Game %d rated should be %v", i, test.rated)
+		}
+	}
+}
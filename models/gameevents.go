@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// GameEventsCollection name of the game events collection
+	GameEventsCollection = "game_events"
+)
+
+// GameLogEntry an immutable record of a single engine action and the events it produced.
+// Seq 0 holds the initial deal and has no action, entry n holds the n-th action of the game.
+type GameLogEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	GameID    primitive.ObjectID `bson:"game_id"`
+	Seq       int                `bson:"seq"`
+	Action    *engine.Action     `bson:"action,omitempty"`
+	Events    []engine.Event     `bson:"events"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+// GameEventsDAO appends and reads game event logs
+type GameEventsDAO struct {
+	collection *mongo.Collection
+}
+
+// EnsureIndices creates the indices the event log relies on, (game, seq) is unique
+// so an entry can never be written twice
+func (d *GameEventsDAO) EnsureIndices() error {
+	_, err := d.collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "game_id", Value: 1}, {Key: "seq", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// AppendEntry appends an entry to the game's log
+func (d *GameEventsDAO) AppendEntry(entry *GameLogEntry) error {
+	entry.CreatedAt = time.Now()
+	res, err := d.collection.InsertOne(context.Background(), entry)
+	if err != nil {
+		log.Errorf("Error appending game %v log entry %d, %v\n", entry.GameID.Hex(), entry.Seq, err)
+		return err
+	}
+	entry.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// FetchGameLog returns the whole log of a game ordered by sequence
+func (d *GameEventsDAO) FetchGameLog(gameID primitive.ObjectID) (entries []*GameLogEntry, err error) {
+	opts := options.Find().SetSort(bson.M{"seq": 1})
+	cursor, err := d.collection.Find(context.Background(), bson.M{"game_id": gameID}, opts)
+	if err != nil {
+		log.Errorf("Error fetching game %v log, %v\n", gameID.Hex(), err)
+		return entries, err
+	}
+	err = cursor.All(context.Background(), &entries)
+	return entries, err
+}
+
+// LogActions returns the actions recorded in a game log, in order
+func LogActions(entries []*GameLogEntry) []engine.Action {
+	var actions []engine.Action
+	for _, entry := range entries {
+		if entry.Action != nil {
+			actions = append(actions, *entry.Action)
+		}
+	}
+	return actions
+}
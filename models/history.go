@@ -0,0 +1,172 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxHistoryPageSize the largest page of game history that can be requested
+const MaxHistoryPageSize = 100
+
+// PlayerResult a seat's outcome in an ended game
+type PlayerResult struct {
+	UserID primitive.ObjectID `bson:"user_id"`
+	Seat   int                `bson:"seat"`
+	Bot    bool               `bson:"bot"`
+	Score  int                `bson:"score"`
+	// Placement 1 for the winners, tied players share a placement
+	Placement      int  `bson:"placement"`
+	Won            bool `bson:"won"`
+	KabooCalls     int  `bson:"kaboo_calls"`
+	KabooSuccesses int  `bson:"kaboo_successes"`
+	// BestRound the lowest score the player had in a single round
+	BestRound int `bson:"best_round"`
+}
+
+// PlayerStats aggregated results of a player over every ended game
+type PlayerStats struct {
+	GamesPlayed    int     `bson:"games_played"`
+	Wins           int     `bson:"wins"`
+	AverageScore   float64 `bson:"average_score"`
+	KabooCalls     int     `bson:"kaboo_calls"`
+	KabooSuccesses int     `bson:"kaboo_successes"`
+	// BestRound nil until the player finished a game
+	BestRound *int `bson:"best_round"`
+}
+
+// WinRate the ratio of games won
+func (s *PlayerStats) WinRate() float64 {
+	if s.GamesPlayed == 0 {
+		return 0
+	}
+	return float64(s.Wins) / float64(s.GamesPlayed)
+}
+
+// KabooSuccessRate the ratio of Kaboo calls that had the lowest hand
+func (s *PlayerStats) KabooSuccessRate() float64 {
+	if s.KabooCalls == 0 {
+		return 0
+	}
+	return float64(s.KabooSuccesses) / float64(s.KabooCalls)
+}
+
+// ResultFor returns the result of the given player, nil if they didn't play the game
+func (g *KabooGame) ResultFor(userID primitive.ObjectID) *PlayerResult {
+	for i := range g.Results {
+		if g.Results[i].UserID == userID {
+			return &g.Results[i]
+		}
+	}
+	return nil
+}
+
+// NewPlayerResults builds every seat's result from the game's final scores and its rounds
+func NewPlayerResults(game *KabooGame, scores []int, rounds []engine.RoundResult) []PlayerResult {
+	seats := game.Seats()
+	results := make([]PlayerResult, len(seats))
+	for seat, userID := range seats {
+		placement := 1
+		for _, score := range scores {
+			if score < scores[seat] {
+				placement++
+			}
+		}
+		results[seat] = PlayerResult{
+			UserID:    userID,
+			Seat:      seat,
+			Bot:       seat >= len(game.Players),
+			Score:     scores[seat],
+			Placement: placement,
+			Won:       placement == 1,
+		}
+	}
+	for i, round := range rounds {
+		for seat := range results {
+			if i == 0 || round.Scores[seat] < results[seat].BestRound {
+				results[seat].BestRound = round.Scores[seat]
+			}
+		}
+		if round.KabooCaller >= 0 && round.KabooCaller < len(results) {
+			results[round.KabooCaller].KabooCalls++
+			if round.KabooSucceeded {
+				results[round.KabooCaller].KabooSuccesses++
+			}
+		}
+	}
+	return results
+}
+
+// EnsureIndices creates the indices used to look up a player's games, history and leaderboards
+func (g *GamesDAO) EnsureIndices() error {
+	_, err := g.collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "players", Value: 1}, {Key: "state", Value: 1}}},
+		{Keys: bson.D{{Key: "results.user_id", Value: 1}, {Key: "ended_at", Value: -1}}},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "ended_at", Value: -1}}},
+	})
+	return err
+}
+
+// SetGameResults records the final results of an ended game
+func (g *GamesDAO) SetGameResults(game *KabooGame, results []PlayerResult) error {
+	game.Results = results
+	game.EndedAt = time.Now()
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID},
+		bson.M{"$set": bson.M{"results": game.Results, "ended_at": game.EndedAt}})
+	return err
+}
+
+// FetchPlayerHistory returns a page of the player's ended games, latest first, and the total number of such games
+func (g *GamesDAO) FetchPlayerHistory(userID primitive.ObjectID, offset int, limit int) (games []*KabooGame, total int64, err error) {
+	filter := bson.M{"state": GameStateEnded, "results.user_id": userID}
+	total, err = g.collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "ended_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"seed": 0, "chat": 0, "password": 0})
+	cursor, err := g.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = cursor.All(context.Background(), &games)
+	return games, total, err
+}
+
+// AggregatePlayerStats aggregates the player's results over every ended game
+func (g *GamesDAO) AggregatePlayerStats(userID primitive.ObjectID) (*PlayerStats, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"state": GameStateEnded, "results.user_id": userID}}},
+		{{Key: "$unwind", Value: "$results"}},
+		{{Key: "$match", Value: bson.M{"results.user_id": userID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":             nil,
+			"games_played":    bson.M{"$sum": 1},
+			"wins":            bson.M{"$sum": bson.M{"$cond": bson.A{"$results.won", 1, 0}}},
+			"average_score":   bson.M{"$avg": "$results.score"},
+			"kaboo_calls":     bson.M{"$sum": "$results.kaboo_calls"},
+			"kaboo_successes": bson.M{"$sum": "$results.kaboo_successes"},
+			"best_round":      bson.M{"$min": "$results.best_round"},
+		}}},
+	}
+	cursor, err := g.collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var stats []PlayerStats
+	if err := cursor.All(context.Background(), &stats); err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 {
+		return &PlayerStats{}, nil
+	}
+	return &stats[0], nil
+}
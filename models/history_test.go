@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_NewPlayerResults(t *testing.T) {
+	game := &KabooGame{
+		Players: []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()},
+		Bots:    []BotPlayer{{ID: primitive.NewObjectID()}},
+	}
+	rounds := []engine.RoundResult{
+		{Round: 1, KabooCaller: 0, Scores: []int{0, 12, 7}, KabooSucceeded: true},
+		{Round: 2, KabooCaller: 0, Scores: []int{25, 3, 3}},
+		{Round: 3, KabooCaller: -1, Scores: []int{4, 20, 15}},
+	}
+	results := NewPlayerResults(game, []int{29, 35, 25}, rounds)
+
+	if results[2].Placement != 1 || !results[2].Won || !results[2].Bot {
+		t.Errorf("Bot with the lowest score should have won, got %+v", results[2])
+	}
+	if results[0].Placement != 2 || results[1].Placement != 3 {
+		t.Errorf("Unexpected placements %d, %d", results[0].Placement, results[1].Placement)
+	}
+	if results[0].KabooCalls != 2 || results[0].KabooSuccesses != 1 {
+		t.Errorf("Expected 2 Kaboo calls with 1 success, got %+v", results[0])
+	}
+	if results[0].BestRound != 0 || results[1].BestRound != 3 {
+		t.Errorf("Unexpected best rounds %d, %d", results[0].BestRound, results[1].BestRound)
+	}
+	if game.ResultFor(game.Players[1]) != nil {
+		t.Errorf("Results aren't recorded on the game until they're saved")
+	}
+}
+
+func Test_TiedPlayersSharePlacement(t *testing.T) {
+	game := &KabooGame{Players: []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}}
+	results := NewPlayerResults(game, []int{10, 10, 30}, nil)
+	if !results[0].Won || !results[1].Won || results[2].Placement != 3 {
+		t.Errorf("Tied winners should share first place, got %+v", results)
+	}
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/rating"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LeaderboardWindow the period of games a leaderboard covers
+type LeaderboardWindow string
+
+// Leaderboard windows, weekly and monthly are rolling windows ending now
+const (
+	LeaderboardWeekly  LeaderboardWindow = "weekly"
+	LeaderboardMonthly LeaderboardWindow = "monthly"
+	LeaderboardAllTime LeaderboardWindow = "all_time"
+)
+
+// LeaderboardMetric what players are ranked by
+type LeaderboardMetric string
+
+// Leaderboard metrics
+const (
+	LeaderboardByRating       LeaderboardMetric = "rating"
+	LeaderboardByWins         LeaderboardMetric = "wins"
+	LeaderboardByAverageScore LeaderboardMetric = "average_score"
+)
+
+// MaxLeaderboardSize the largest number of entries a leaderboard request returns
+const MaxLeaderboardSize = 100
+
+var (
+	// ErrUnknownLeaderboardWindow window isn't one of the known windows
+	ErrUnknownLeaderboardWindow = errors.New("Unknown leaderboard window")
+	// ErrUnknownLeaderboardMetric metric isn't one of the known metrics
+	ErrUnknownLeaderboardMetric = errors.New("Unknown leaderboard metric")
+)
+
+// LeaderboardWindows returns every leaderboard window
+func LeaderboardWindows() []LeaderboardWindow {
+	return []LeaderboardWindow{LeaderboardWeekly, LeaderboardMonthly, LeaderboardAllTime}
+}
+
+// Since returns the time the window starts at, the zero time for all time
+func (w LeaderboardWindow) Since(now time.Time) (time.Time, error) {
+	switch w {
+	case LeaderboardWeekly:
+		return now.AddDate(0, 0, -7), nil
+	case LeaderboardMonthly:
+		return now.AddDate(0, -1, 0), nil
+	case LeaderboardAllTime:
+		return time.Time{}, nil
+	}
+	return time.Time{}, ErrUnknownLeaderboardWindow
+}
+
+// sort returns the order entries are ranked in for the metric, lower average scores are better
+func (m LeaderboardMetric) sort() (bson.D, error) {
+	switch m {
+	case LeaderboardByRating:
+		return bson.D{{Key: "rating", Value: -1}, {Key: "games", Value: -1}}, nil
+	case LeaderboardByWins:
+		return bson.D{{Key: "wins", Value: -1}, {Key: "games", Value: 1}}, nil
+	case LeaderboardByAverageScore:
+		return bson.D{{Key: "average_score", Value: 1}, {Key: "games", Value: -1}}, nil
+	}
+	return nil, ErrUnknownLeaderboardMetric
+}
+
+// LeaderboardEntry a player's precomputed standing in a leaderboard window
+type LeaderboardEntry struct {
+	UserID       primitive.ObjectID `bson:"_id"`
+	Username     string             `bson:"username"`
+	Rating       float64            `bson:"rating"`
+	Games        int                `bson:"games"`
+	Wins         int                `bson:"wins"`
+	AverageScore float64            `bson:"average_score"`
+	UpdatedAt    time.Time          `bson:"updated_at"`
+}
+
+// LeaderboardDAO precomputes leaderboards from ended games and serves them
+type LeaderboardDAO struct {
+	database *mongo.Database
+	games    *mongo.Collection
+}
+
+func leaderboardCollection(window LeaderboardWindow) string {
+	return "leaderboard_" + string(window)
+}
+
+// Refresh recomputes every leaderboard window, it should run whenever a game ends and periodically
+// so the rolling windows drop older games
+func (d *LeaderboardDAO) Refresh() error {
+	now := time.Now()
+	for _, window := range LeaderboardWindows() {
+		if err := d.refreshWindow(window, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshWindow aggregates the human results of the games that ended in the window and
+// replaces the window's collection with them
+func (d *LeaderboardDAO) refreshWindow(window LeaderboardWindow, now time.Time) error {
+	since, err := window.Since(now)
+	if err != nil {
+		return err
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"state": GameStateEnded, "ended_at": bson.M{"$gte": since}}}},
+		{{Key: "$unwind", Value: "$results"}},
+		{{Key: "$match", Value: bson.M{"results.bot": false}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$results.user_id",
+			"games":         bson.M{"$sum": 1},
+			"wins":          bson.M{"$sum": bson.M{"$cond": bson.A{"$results.won", 1, 0}}},
+			"average_score": bson.M{"$avg": "$results.score"},
+		}}},
+		{{Key: "$lookup", Value: bson.M{"from": UserCollection, "localField": "_id", "foreignField": "_id", "as": "user"}}},
+		{{Key: "$unwind", Value: "$user"}},
+		{{Key: "$project", Value: bson.M{
+			"username":      "$user.username",
+			"games":         1,
+			"wins":          1,
+			"average_score": 1,
+			"rating": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$user.rated_games", 0}}, "$user.rating", rating.DefaultRating,
+			}},
+			"updated_at": now,
+		}}},
+		{{Key: "$out", Value: leaderboardCollection(window)}},
+	}
+	cursor, err := d.games.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.Close(context.Background())
+}
+
+// FetchLeaderboard returns the top players of the window ranked by the metric, only players
+// with at least minGames games in the window are ranked
+func (d *LeaderboardDAO) FetchLeaderboard(window LeaderboardWindow, metric LeaderboardMetric, minGames int, limit int) (entries []*LeaderboardEntry, err error) {
+	if _, err := window.Since(time.Now()); err != nil {
+		return nil, err
+	}
+	sort, err := metric.sort()
+	if err != nil {
+		return nil, err
+	}
+	opts := options.Find().SetSort(sort).SetLimit(int64(limit))
+	cursor, err := d.database.Collection(leaderboardCollection(window)).Find(context.Background(),
+		bson.M{"games": bson.M{"$gte": minGames}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(context.Background(), &entries)
+	return entries, err
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_LeaderboardWindowSince(t *testing.T) {
+	now := time.Date(2020, 5, 15, 12, 0, 0, 0, time.UTC)
+	if since, _ := LeaderboardWeekly.Since(now); !since.Equal(now.AddDate(0, 0, -7)) {
+		t.Errorf("Weekly window should start a week ago, got %v", since)
+	}
+	if since, _ := LeaderboardMonthly.Since(now); !since.Equal(time.Date(2020, 4, 15, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Monthly window should start a month ago, got %v", since)
+	}
+	if since, _ := LeaderboardAllTime.Since(now); !since.IsZero() {
+		t.Errorf("All time window should include every game, got %v", since)
+	}
+	if _, err := LeaderboardWindow("daily").Since(now); err != ErrUnknownLeaderboardWindow {
+		t.Errorf("Expected unknown window error, got %v", err)
+	}
+}
+
+func Test_LeaderboardMetricSort(t *testing.T) {
+	for _, metric := range []LeaderboardMetric{LeaderboardByRating, LeaderboardByWins, LeaderboardByAverageScore} {
+		if _, err := metric.sort(); err != nil {
+			t.Errorf("Metric %v should be sortable, %v", metric, err)
+		}
+	}
+	if sort, _ := LeaderboardByAverageScore.sort(); sort[0].Value != 1 {
+		t.Errorf("Lower average scores should rank first")
+	}
+	if _, err := LeaderboardMetric("losses").sort(); err != ErrUnknownLeaderboardMetric {
+		t.Errorf("Expected unknown metric error, got %v", err)
+	}
+}
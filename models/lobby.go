@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GameVisibility who can find a game in the lobby listing
+type GameVisibility string
+
+// Game visibilities, unlisted games can only be joined by id and friends only games are
+// listed and joinable by the owner's friends alone
+const (
+	GameVisibilityPublic      GameVisibility = "public"
+	GameVisibilityUnlisted    GameVisibility = "unlisted"
+	GameVisibilityFriendsOnly GameVisibility = "friends"
+)
+
+var (
+	// ErrUnknownVisibility visibility isn't one of the known visibilities
+	ErrUnknownVisibility = errors.New("Unknown game visibility")
+	// ErrBannedFromGame the owner banned the user from the game
+	ErrBannedFromGame = errors.New("User is banned from this game")
+	// ErrPlayerNotInGame the player isn't seated in the game
+	ErrPlayerNotInGame = errors.New("Player isn't in the game")
+	// ErrInvalidMaxPlayers max players is out of range or lower than the number of seated players
+	ErrInvalidMaxPlayers = errors.New("Invalid max players")
+)
+
+// Validate returns an error for unknown visibilities
+func (v GameVisibility) Validate() error {
+	switch v {
+	case GameVisibilityPublic, GameVisibilityUnlisted, GameVisibilityFriendsOnly:
+		return nil
+	}
+	return ErrUnknownVisibility
+}
+
+// GameVisibility returns the game visibility, games created before visibilities existed are public
+func (g *KabooGame) GameVisibility() GameVisibility {
+	if g.Visibility == "" {
+		return GameVisibilityPublic
+	}
+	return g.Visibility
+}
+
+// IsBanned returns whether the owner banned the user from the game
+func (g *KabooGame) IsBanned(userID primitive.ObjectID) bool {
+	for _, banned := range g.Banned {
+		if banned == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPlayer returns whether the user is seated in the game
+func (g *KabooGame) IsPlayer(userID primitive.ObjectID) bool {
+	for _, player := range g.Players {
+		if player == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// SetVisibility changes who can find the game
+func (g *GamesDAO) SetVisibility(game *KabooGame, visibility GameVisibility) error {
+	if err := visibility.Validate(); err != nil {
+		return err
+	}
+	game.Visibility = visibility
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"visibility": visibility}})
+	return err
+}
+
+// RemovePlayerFromGame unseats a player, banning him from rejoining if ban is set
+func (g *GamesDAO) RemovePlayerFromGame(game *KabooGame, userID primitive.ObjectID, ban bool) error {
+	g.gmtx.Lock()
+	defer g.gmtx.Unlock()
+
+	if !game.IsPlayer(userID) {
+		return ErrPlayerNotInGame
+	}
+	players := []primitive.ObjectID{}
+	for _, player := range game.Players {
+		if player != userID {
+			players = append(players, player)
+		}
+	}
+	game.Players = players
+	update := bson.M{"players": game.Players}
+	if ban && !game.IsBanned(userID) {
+		game.Banned = append(game.Banned, userID)
+		update["banned"] = game.Banned
+	}
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": update})
+	return err
+}
+
+// TransferOwnership makes another seated player the game owner
+func (g *GamesDAO) TransferOwnership(game *KabooGame, userID primitive.ObjectID) error {
+	if !game.IsPlayer(userID) {
+		return ErrPlayerNotInGame
+	}
+	game.Owner = userID
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"owner": userID}})
+	return err
+}
+
+// UpdateMaxPlayers changes the number of seats, can't go below the seated players
+func (g *GamesDAO) UpdateMaxPlayers(game *KabooGame, maxPlayers int) error {
+	g.gmtx.Lock()
+	defer g.gmtx.Unlock()
+
+	if maxPlayers < engine.MinPlayers || maxPlayers > engine.MaxPlayers || maxPlayers < game.PlayerCount() {
+		return ErrInvalidMaxPlayers
+	}
+	game.MaxPlayers = maxPlayers
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"max_players": maxPlayers}})
+	return err
+}
+
+// RenameGame changes the game name
+func (g *GamesDAO) RenameGame(game *KabooGame, name string) error {
+	game.Name = name
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"name": name}})
+	return err
+}
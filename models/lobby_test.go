@@ -0,0 +1,22 @@
+package models
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_GameVisibility(t *testing.T) {
+	game := KabooGame{}
+	if game.GameVisibility() != GameVisibilityPublic {
+		t.Errorf("Games without a visibility should be public")
+	}
+	if err := GameVisibility("secret").Validate(); err != ErrUnknownVisibility {
+		t.Errorf("Expected unknown visibility, got %v", err)
+	}
+	userID := primitive.NewObjectID()
+	game.Players = []primitive.ObjectID{userID}
+	if !game.IsPlayer(userID) || game.IsBanned(userID) {
+		t.Errorf("Seated player shouldn't be banned")
+	}
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// MaxDisplayNameLength maximum length of a display name, in characters
+	MaxDisplayNameLength = 32
+	// UploadedAvatarPrefix prefixes the avatar of users that uploaded their own image
+	UploadedAvatarPrefix = "upload:"
+	// DefaultAvatar avatar of users that didn't pick one
+	DefaultAvatar = "classic"
+)
+
+var (
+	// ErrInvalidUsername username has an invalid length or characters
+	ErrInvalidUsername = errors.New("Username must be 3-20 letters, digits, '_' or '-'")
+	// ErrUsernameTaken another user already has the username, regardless of case
+	ErrUsernameTaken = errors.New("Username is already taken")
+	// ErrInvalidDisplayName display name is empty, too long or has control characters
+	ErrInvalidDisplayName = errors.New("Display name must be 1-32 printable characters")
+	// ErrUnknownAvatar avatar isn't one of the available avatars
+	ErrUnknownAvatar = errors.New("Unknown avatar")
+	// ErrUnknownCardBack card back isn't one of the available card backs
+	ErrUnknownCardBack = errors.New("Unknown card back")
+	// ErrUnsupportedLanguage language isn't supported
+	ErrUnsupportedLanguage = errors.New("Unsupported language")
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,20}$`)
+
+// Avatars the avatars users can pick from, users can also upload their own
+var Avatars = []string{DefaultAvatar, "cat", "dog", "fox", "owl", "robot", "ghost", "crown"}
+
+// CardBacks the card back designs users can pick from
+var CardBacks = []string{"classic", "blue", "red", "green", "gold"}
+
+// Languages the languages the client is available in
+var Languages = []string{"en", "he", "es", "fr", "de"}
+
+// Preferences per user client settings
+type Preferences struct {
+	CardBack string `bson:"card_back"`
+	Sound    bool   `bson:"sound"`
+	Language string `bson:"language"`
+}
+
+// DefaultPreferences preferences of users that didn't change them
+func DefaultPreferences() Preferences {
+	return Preferences{CardBack: CardBacks[0], Sound: true, Language: Languages[0]}
+}
+
+// Validate returns an error if the preferences refer to an unknown card back or language
+func (p Preferences) Validate() error {
+	if !contains(CardBacks, p.CardBack) {
+		return ErrUnknownCardBack
+	}
+	if !contains(Languages, p.Language) {
+		return ErrUnsupportedLanguage
+	}
+	return nil
+}
+
+// UserPreferences returns the user preferences, falling back to the defaults for users that never set them
+func (u *User) UserPreferences() Preferences {
+	if u.Preferences == nil {
+		return DefaultPreferences()
+	}
+	return *u.Preferences
+}
+
+// UserAvatar returns the user avatar, falling back to the default avatar
+func (u *User) UserAvatar() string {
+	if u.Avatar == "" {
+		return DefaultAvatar
+	}
+	return u.Avatar
+}
+
+// Name returns the name shown to other players, the display name if set or the username
+func (u *User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
+}
+
+// ValidateUsername returns an error if the username has an invalid length or characters
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return ErrInvalidUsername
+	}
+	return nil
+}
+
+// NormalizeDisplayName trims the display name and validates it
+func NormalizeDisplayName(displayName string) (string, error) {
+	displayName = strings.TrimSpace(displayName)
+	length := utf8.RuneCountInString(displayName)
+	if length == 0 || length > MaxDisplayNameLength || !utf8.ValidString(displayName) {
+		return "", ErrInvalidDisplayName
+	}
+	for _, r := range displayName {
+		if !unicode.IsPrint(r) {
+			return "", ErrInvalidDisplayName
+		}
+	}
+	return displayName, nil
+}
+
+// ValidateAvatar returns an error unless the avatar is one of the available avatars, uploaded
+// images are only set through SetUploadedAvatar
+func ValidateAvatar(avatar string) error {
+	if !contains(Avatars, avatar) {
+		return ErrUnknownAvatar
+	}
+	return nil
+}
+
+// UploadedAvatar returns the file name of the user's uploaded avatar, empty if he uses one of the available avatars
+func (u *User) UploadedAvatar() string {
+	if !strings.HasPrefix(u.Avatar, UploadedAvatarPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(u.Avatar, UploadedAvatarPrefix)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureIndices creates the user indices, usernames are unique regardless of case
+func (d *UserDAO) EnsureIndices() error {
+	_, err := d.collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetCollation(&options.Collation{Locale: "en", Strength: 2}).
+				SetPartialFilterExpression(bson.M{"username": bson.M{"$gt": ""}}),
+		},
+		{Keys: bson.D{{Key: "external_id", Value: 1}}},
+	})
+	return err
+}
+
+// UpdateUsername changes the user's username, fails with ErrUsernameTaken if another user has it in any case
+func (d *UserDAO) UpdateUsername(user *User, username string) error {
+	if err := ValidateUsername(username); err != nil {
+		return err
+	}
+	_, err := d.collection.UpdateOne(context.Background(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"username": username}})
+	if isDuplicateKeyError(err) {
+		return ErrUsernameTaken
+	} else if err != nil {
+		return err
+	}
+	user.Username = username
+	return nil
+}
+
+// UpdateDisplayName changes the user's display name
+func (d *UserDAO) UpdateDisplayName(user *User, displayName string) error {
+	displayName, err := NormalizeDisplayName(displayName)
+	if err != nil {
+		return err
+	}
+	user.DisplayName = displayName
+	return d.set(user.ID, bson.M{"display_name": displayName})
+}
+
+// UpdateAvatar changes the user's avatar
+func (d *UserDAO) UpdateAvatar(user *User, avatar string) error {
+	if err := ValidateAvatar(avatar); err != nil {
+		return err
+	}
+	user.Avatar = avatar
+	return d.set(user.ID, bson.M{"avatar": avatar})
+}
+
+// SetUploadedAvatar sets the user's avatar to an image he uploaded and that was stored as fileName
+func (d *UserDAO) SetUploadedAvatar(user *User, fileName string) error {
+	user.Avatar = UploadedAvatarPrefix + fileName
+	return d.set(user.ID, bson.M{"avatar": user.Avatar})
+}
+
+// UpdatePreferences replaces the user's preferences
+func (d *UserDAO) UpdatePreferences(user *User, preferences Preferences) error {
+	if err := preferences.Validate(); err != nil {
+		return err
+	}
+	user.Preferences = &preferences
+	return d.set(user.ID, bson.M{"preferences": preferences})
+}
+
+func (d *UserDAO) set(userID primitive.ObjectID, fields bson.M) error {
+	_, err := d.collection.UpdateOne(context.Background(), bson.M{"_id": userID}, bson.M{"$set": fields})
+	return err
+}
+
+func isDuplicateKeyError(err error) bool {
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) {
+		for _, writeError := range writeException.WriteErrors {
+			if writeError.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
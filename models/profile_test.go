@@ -0,0 +1,47 @@
+package models
+
+import "testing"
+
+func Test_ValidateUsername(t *testing.T) {
+	for _, username := range []string{"bob", "Bob_the-2nd", "abcdefghij0123456789"} {
+		if err := ValidateUsername(username); err != nil {
+			t.Errorf("Username %q should be valid", username)
+		}
+	}
+	for _, username := range []string{"", "ab", "has space", "émile", "abcdefghij01234567890"} {
+		if err := ValidateUsername(username); err != ErrInvalidUsername {
+			t.Errorf("Username %q should be invalid", username)
+		}
+	}
+}
+
+func Test_NormalizeDisplayName(t *testing.T) {
+	if name, err := NormalizeDisplayName("  Émile Zola "); err != nil || name != "Émile Zola" {
+		t.Errorf("Unexpected display name %q (%v)", name, err)
+	}
+	for _, name := range []string{"   ", "tab\tname", "123456789012345678901234567890123"} {
+		if _, err := NormalizeDisplayName(name); err != ErrInvalidDisplayName {
+			t.Errorf("Display name %q should be invalid", name)
+		}
+	}
+}
+
+func Test_ProfileDefaults(t *testing.T) {
+	user := User{Username: "bob"}
+	if user.Name() != "bob" || user.UserAvatar() != DefaultAvatar || user.UploadedAvatar() != "" {
+		t.Errorf("Unexpected defaults for %+v", user)
+	}
+	if err := user.UserPreferences().Validate(); err != nil {
+		t.Errorf("Default preferences should be valid, %v", err)
+	}
+	user.Avatar = UploadedAvatarPrefix + "abc.png"
+	if user.UploadedAvatar() != "abc.png" {
+		t.Errorf("Unexpected uploaded avatar %q", user.UploadedAvatar())
+	}
+	if err := ValidateAvatar(user.Avatar); err != ErrUnknownAvatar {
+		t.Errorf("Uploaded avatars can't be picked directly")
+	}
+	if err := (Preferences{CardBack: "classic", Language: "xx"}).Validate(); err != ErrUnsupportedLanguage {
+		t.Errorf("Expected unsupported language, got %v", err)
+	}
+}
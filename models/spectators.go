@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// MaxSpectatorDelay longest delay a game may set on the spectator stream
+	MaxSpectatorDelay = 5 * time.Minute
+)
+
+var (
+	// ErrSpectatingDisabled the owner disabled spectating
+	ErrSpectatingDisabled = errors.New("Spectating is disabled for this game")
+
+	// ErrTooManySpectators the spectator cap was reached
+	ErrTooManySpectators = errors.New("Too many spectators in game")
+
+	// ErrInvalidSpectatorSettings spectator cap or delay are out of range
+	ErrInvalidSpectatorSettings = errors.New("Invalid spectator settings")
+)
+
+// SpectatorSettings who may watch a game, the zero value allows unlimited spectators with no delay
+type SpectatorSettings struct {
+	Disabled      bool `bson:"disabled"`
+	MaxSpectators int  `bson:"max_spectators"`
+	DelaySeconds  int  `bson:"delay_seconds"`
+}
+
+// Delay returns the delay applied to the spectator stream
+func (s SpectatorSettings) Delay() time.Duration {
+	return time.Duration(s.DelaySeconds) * time.Second
+}
+
+// Validate checks the settings are within range
+func (s SpectatorSettings) Validate() error {
+	if s.MaxSpectators < 0 || s.DelaySeconds < 0 || s.Delay() > MaxSpectatorDelay {
+		return ErrInvalidSpectatorSettings
+	}
+	return nil
+}
+
+// IsSpectator returns whether the user is watching the game
+func (g *KabooGame) IsSpectator(userID primitive.ObjectID) bool {
+	for _, spectator := range g.Spectators {
+		if spectator == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Audience returns every human following the game, players and spectators
+func (g *KabooGame) Audience() []primitive.ObjectID {
+	return append(append([]primitive.ObjectID(nil), g.Players...), g.Spectators...)
+}
+
+// TryToAddSpectatorToGame attempts to add a spectator, will fail if spectating is disabled or the cap was reached
+func (g *GamesDAO) TryToAddSpectatorToGame(game *KabooGame, user *User) error {
+	g.gmtx.Lock()
+	defer g.gmtx.Unlock()
+
+	if game.SpectatorSettings.Disabled {
+		return ErrSpectatingDisabled
+	}
+	if game.IsSpectator(user.ID) {
+		return nil
+	}
+	if max := game.SpectatorSettings.MaxSpectators; max > 0 && len(game.Spectators) >= max {
+		return ErrTooManySpectators
+	}
+	game.Spectators = append(game.Spectators, user.ID)
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"spectators": game.Spectators}})
+	return err
+}
+
+// RemoveSpectatorFromGame stops the user from watching the game
+func (g *GamesDAO) RemoveSpectatorFromGame(game *KabooGame, user *User) error {
+	g.gmtx.Lock()
+	defer g.gmtx.Unlock()
+
+	spectators := []primitive.ObjectID{}
+	for _, spectator := range game.Spectators {
+		if spectator != user.ID {
+			spectators = append(spectators, spectator)
+		}
+	}
+	game.Spectators = spectators
+	_, err := g.collection.UpdateOne(context.Background(), bson.M{"_id": game.ID}, bson.M{"$set": bson.M{"spectators": game.Spectators}})
+	return err
+}
@@ -2,6 +2,9 @@ package models
 
 import (
 	"context"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/rating"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,13 +14,37 @@ import (
 const (
 	// UserCollection is the users mongo collection name
 	UserCollection = "users"
+	// RatingHistoryLength number of rating changes kept on a user
+	RatingHistoryLength = 100
 )
 
 // User object in the system
 type User struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty"`
-	ExternalID string             `bson:"external_id"`
-	Username   string             `bson:"username"`
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	ExternalID    string             `bson:"external_id"`
+	Username      string             `bson:"username"`
+	DisplayName   string             `bson:"display_name"`
+	Avatar        string             `bson:"avatar"`
+	Preferences   *Preferences       `bson:"preferences,omitempty"`
+	Rating        float64            `bson:"rating"`
+	RatedGames    int                `bson:"rated_games"`
+	RatingHistory []RatingChange     `bson:"rating_history"`
+}
+
+// RatingChange a rating update following a rated game
+type RatingChange struct {
+	GameID primitive.ObjectID `bson:"game_id"`
+	Before float64            `bson:"before"`
+	After  float64            `bson:"after"`
+	At     time.Time          `bson:"at"`
+}
+
+// CurrentRating returns the user rating, users that didn't play rated games have the default rating
+func (u *User) CurrentRating() float64 {
+	if u.RatedGames == 0 {
+		return rating.DefaultRating
+	}
+	return u.Rating
 }
 
 // UserDAO handles all user related db interactions
@@ -26,7 +53,6 @@ type UserDAO struct {
 }
 
 // FetchUserByExternalID returns a user using his external id (e.g. Auth0)
-// TODO: Add missing indices
 func (d *UserDAO) FetchUserByExternalID(externalID string) (user *User, err error) {
 	var returnedUser User
 	filter := bson.M{"external_id": externalID}
@@ -36,3 +62,27 @@ func (d *UserDAO) FetchUserByExternalID(externalID string) (user *User, err erro
 	}
 	return &returnedUser, nil
 }
+
+// FetchUserByID returns a user using his id
+func (d *UserDAO) FetchUserByID(userID primitive.ObjectID) (user *User, err error) {
+	var returnedUser User
+	err = d.collection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&returnedUser)
+	if err != nil {
+		return nil, err
+	}
+	return &returnedUser, nil
+}
+
+// ApplyRatingChange sets the user rating following a rated game and records the change
+func (d *UserDAO) ApplyRatingChange(userID primitive.ObjectID, change RatingChange) error {
+	update := bson.M{
+		"$set": bson.M{"rating": change.After},
+		"$inc": bson.M{"rated_games": 1},
+		"$push": bson.M{"rating_history": bson.M{
+			"$each":  []RatingChange{change},
+			"$slice": -RatingHistoryLength,
+		}},
+	}
+	_, err := d.collection.UpdateOne(context.Background(), bson.M{"_id": userID}, update)
+	return err
+}
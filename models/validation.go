@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+)
+
+const (
+	// MaxGameNameLength longest game name in characters
+	MaxGameNameLength = 40
+	// MaxGamePasswordLength longest game password in characters
+	MaxGamePasswordLength = 64
+)
+
+// gameNamePattern letters, digits, spaces and a few punctuation marks
+var gameNamePattern = regexp.MustCompile(`^[\p{L}\p{N} _.,:!?'&#()+-]+$`)
+
+// FieldError a single invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of a request
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return "Invalid request, " + strings.Join(messages, "; ")
+}
+
+// Add records an invalid field
+func (e *ValidationError) Add(field string, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// OrNil returns the error if any field was invalid, nil otherwise
+func (e *ValidationError) OrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// NormalizeGameName trims the game name and validates its length and characters, the
+// error describes what's wrong with the name
+func NormalizeGameName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	length := utf8.RuneCountInString(name)
+	if length == 0 || length > MaxGameNameLength {
+		return "", fmt.Errorf("must be 1 to %d characters long", MaxGameNameLength)
+	}
+	if !gameNamePattern.MatchString(name) {
+		return "", errors.New("may only contain letters, digits, spaces and _.,:!?'&#()+-")
+	}
+	return name, nil
+}
+
+// ValidateNewGame validates the settings of a new game, returning the normalized game name or a *ValidationError
+func ValidateNewGame(name string, maxPlayers int, password string) (string, error) {
+	errs := &ValidationError{}
+	name, err := NormalizeGameName(name)
+	if err != nil {
+		errs.Add("name", err.Error())
+	}
+	if maxPlayers < engine.MinPlayers || maxPlayers > engine.MaxPlayers {
+		errs.Add("maxPlayers", fmt.Sprintf("must be between %d and %d", engine.MinPlayers, engine.MaxPlayers))
+	}
+	if utf8.RuneCountInString(password) > MaxGamePasswordLength || !utf8.ValidString(password) {
+		errs.Add("password", fmt.Sprintf("must be at most %d characters long", MaxGamePasswordLength))
+	}
+	return name, errs.OrNil()
+}
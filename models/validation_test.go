@@ -0,0 +1,33 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_ValidateNewGame(t *testing.T) {
+	if name, err := ValidateNewGame("  Friday night #3 ", 4, "secret"); err != nil || name != "Friday night #3" {
+		t.Errorf("Unexpected game name %q (%v)", name, err)
+	}
+	_, err := ValidateNewGame(strings.Repeat("a", MaxGameNameLength+1), 10000, strings.Repeat("p", MaxGamePasswordLength+1))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	fields := []string{}
+	for _, field := range validationErr.Fields {
+		fields = append(fields, field.Field)
+	}
+	if strings.Join(fields, ",") != "name,maxPlayers,password" {
+		t.Errorf("Unexpected invalid fields %v", fields)
+	}
+	for _, name := range []string{"", "   ", "<script>", "tab\tname"} {
+		if _, err := NormalizeGameName(name); err == nil {
+			t.Errorf("Game name %q should be invalid", name)
+		}
+	}
+	if _, err := ValidateNewGame("game", 0, ""); err == nil {
+		t.Errorf("Zero players should be invalid")
+	}
+}
@@ -0,0 +1,45 @@
+// Package rating computes multiplayer Elo ratings. A game with n players is
+// treated as every pair of players playing each other, the pairwise results
+// are averaged so a game moves a rating by at most KFactor.
+package rating
+
+import "math"
+
+const (
+	// DefaultRating rating of a user that didn't play rated games yet
+	DefaultRating = 1500.0
+	// KFactor maximal rating change in a single game
+	KFactor = 32.0
+)
+
+// Expected returns the expected score of a player rated a against a player rated b
+func Expected(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// Update returns the new ratings given the final game scores, lower scores
+// place better and equal scores are a draw. Ratings and scores are indexed by player.
+func Update(ratings []float64, scores []int) []float64 {
+	updated := append([]float64(nil), ratings...)
+	n := len(ratings)
+	if n < 2 {
+		return updated
+	}
+	for i := range ratings {
+		delta := 0.0
+		for j := range ratings {
+			if i == j {
+				continue
+			}
+			actual := 0.5
+			if scores[i] < scores[j] {
+				actual = 1
+			} else if scores[i] > scores[j] {
+				actual = 0
+			}
+			delta += actual - Expected(ratings[i], ratings[j])
+		}
+		updated[i] += KFactor * delta / float64(n-1)
+	}
+	return updated
+}
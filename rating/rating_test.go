@@ -0,0 +1,35 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_UpdateTwoPlayers(t *testing.T) {
+	updated := Update([]float64{DefaultRating, DefaultRating}, []int{10, 20})
+	if updated[0] != DefaultRating+KFactor/2 || updated[1] != DefaultRating-KFactor/2 {
+		t.Errorf("Unexpected ratings %v", updated)
+	}
+}
+
+func Test_UpdateIsZeroSum(t *testing.T) {
+	ratings := []float64{1400, 1650, 1500, 1800}
+	updated := Update(ratings, []int{30, 12, 12, 70})
+	total := 0.0
+	for i := range ratings {
+		total += updated[i] - ratings[i]
+	}
+	if math.Abs(total) > 1e-9 {
+		t.Errorf("Rating changes should sum to zero, got %v", total)
+	}
+	if updated[1] <= ratings[1] || updated[3] >= ratings[3] {
+		t.Errorf("Winner should gain and last place should lose, got %v", updated)
+	}
+}
+
+func Test_DrawBetweenEqualsChangesNothing(t *testing.T) {
+	updated := Update([]float64{1500, 1500}, []int{5, 5})
+	if updated[0] != 1500 || updated[1] != 1500 {
+		t.Errorf("Draw between equal players shouldn't change ratings, got %v", updated)
+	}
+}
@@ -0,0 +1,156 @@
+// Package simulation plays games between bots entirely in memory, used for
+// balancing rules and regression testing the engine.
+package simulation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ngutman/kaboo-server-go/bots"
+	"github.com/ngutman/kaboo-server-go/engine"
+)
+
+var (
+	// ErrNoGames at least one game must be simulated
+	ErrNoGames = errors.New("Number of games must be positive")
+)
+
+// Config simulation parameters, seat i is played by Strategies[i]. An unset
+// ruleset plays by the default rules
+type Config struct {
+	Games      int
+	Strategies []bots.Difficulty
+	Seed       string
+	Rules      engine.Ruleset
+}
+
+// SeatStats aggregated results of a single seat
+type SeatStats struct {
+	Seat             int             `json:"seat"`
+	Strategy         bots.Difficulty `json:"strategy"`
+	Wins             float64         `json:"wins"`
+	WinRate          float64         `json:"winRate"`
+	AverageScore     float64         `json:"averageScore"`
+	KabooCalls       int             `json:"kabooCalls"`
+	KabooSuccesses   int             `json:"kabooSuccesses"`
+	KabooSuccessRate float64         `json:"kabooSuccessRate"`
+}
+
+// Report aggregated results of a simulation
+type Report struct {
+	Games            int         `json:"games"`
+	Seed             string      `json:"seed"`
+	AverageTurns     float64     `json:"averageTurns"`
+	AverageRounds    float64     `json:"averageRounds"`
+	KabooCalls       int         `json:"kabooCalls"`
+	KabooSuccessRate float64     `json:"kabooSuccessRate"`
+	Seats            []SeatStats `json:"seats"`
+}
+
+// Run plays the configured number of games, game i is seeded with "<seed>-<i>"
+// so every run of the same config yields the same report
+func Run(config Config) (*Report, error) {
+	if config.Games <= 0 {
+		return nil, ErrNoGames
+	}
+	if len(config.Strategies) < engine.MinPlayers || len(config.Strategies) > engine.MaxPlayers {
+		return nil, engine.ErrPlayerCount
+	}
+	for _, strategy := range config.Strategies {
+		if !bots.IsValidDifficulty(strategy) {
+			return nil, fmt.Errorf("%w (%v)", bots.ErrUnknownDifficulty, strategy)
+		}
+	}
+	rules := config.Rules.OrDefault()
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	report := &Report{Games: config.Games, Seed: config.Seed, Seats: make([]SeatStats, len(config.Strategies))}
+	for seat, strategy := range config.Strategies {
+		report.Seats[seat] = SeatStats{Seat: seat, Strategy: strategy}
+	}
+	totalTurns, totalRounds := 0, 0
+	for i := 0; i < config.Games; i++ {
+		g, err := PlayGame(fmt.Sprintf("%s-%d", config.Seed, i), config.Strategies, rules, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, round := range g.Rounds() {
+			if round.KabooCaller < 0 {
+				continue
+			}
+			report.Seats[round.KabooCaller].KabooCalls++
+			if round.KabooSucceeded {
+				report.Seats[round.KabooCaller].KabooSuccesses++
+			}
+		}
+		totalTurns += g.Turn()
+		totalRounds += g.Round()
+		winners := g.Winners()
+		for seat, score := range g.Scores() {
+			report.Seats[seat].AverageScore += float64(score)
+		}
+		for _, winner := range winners {
+			report.Seats[winner].Wins += 1 / float64(len(winners))
+		}
+	}
+	successes := 0
+	for i := range report.Seats {
+		s := &report.Seats[i]
+		s.WinRate = s.Wins / float64(config.Games)
+		s.AverageScore /= float64(config.Games)
+		s.KabooSuccessRate = rate(s.KabooSuccesses, s.KabooCalls)
+		report.KabooCalls += s.KabooCalls
+		successes += s.KabooSuccesses
+	}
+	report.KabooSuccessRate = rate(successes, report.KabooCalls)
+	report.AverageTurns = float64(totalTurns) / float64(config.Games)
+	report.AverageRounds = float64(totalRounds) / float64(config.Games)
+	return report, nil
+}
+
+// PlayGame plays a single game between the given bot strategies until it
+// ends, onEvent is called with every unmasked event
+func PlayGame(seed string, strategies []bots.Difficulty, rules engine.Ruleset, onEvent func(e engine.Event)) (*engine.Game, error) {
+	g, events, err := engine.NewGame(seed, len(strategies), rules)
+	if err != nil {
+		return nil, err
+	}
+	players := make([]bots.Bot, len(strategies))
+	for seat, strategy := range strategies {
+		if players[seat], err = bots.New(strategy, seat, len(strategies), rules, bots.SeedFor(seed, seat)); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		for _, e := range events {
+			if onEvent != nil {
+				onEvent(e)
+			}
+			for seat, bot := range players {
+				bot.Observe(e.Masked(seat))
+			}
+		}
+		if g.IsOver() {
+			return g, nil
+		}
+		// Any bot may snap before the current seat acts
+		action, snapped := bots.OutOfTurnSnap(g, func(seat int) bots.Bot { return players[seat] })
+		if !snapped {
+			seat := g.CurrentSeat()
+			if action, err = players[seat].Act(g.View(seat), g.LegalActions(seat)); err != nil {
+				return nil, err
+			}
+		}
+		if events, err = g.Apply(action); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func rate(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
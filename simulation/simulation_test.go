@@ -0,0 +1,74 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ngutman/kaboo-server-go/bots"
+	"github.com/ngutman/kaboo-server-go/engine"
+)
+
+func Test_SimulationIsReproducible(t *testing.T) {
+	config := Config{
+		Games:      50,
+		Strategies: []bots.Difficulty{bots.DifficultyRandom, bots.DifficultyMemory, bots.DifficultyExpected},
+		Seed:       "test",
+	}
+	report1, err := Run(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report2, _ := Run(config)
+	if !reflect.DeepEqual(report1, report2) {
+		t.Errorf("Same config should produce the same report")
+	}
+	totalWinRate := 0.0
+	for _, seat := range report1.Seats {
+		totalWinRate += seat.WinRate
+	}
+	if totalWinRate < 0.999 || totalWinRate > 1.001 {
+		t.Errorf("Win rates should sum to 1, got %v", totalWinRate)
+	}
+}
+
+func Test_SimulationRejectsBadConfig(t *testing.T) {
+	if _, err := Run(Config{Games: 0, Strategies: []bots.Difficulty{bots.DifficultyRandom, bots.DifficultyRandom}}); err != ErrNoGames {
+		t.Errorf("Should have rejected zero games")
+	}
+	if _, err := Run(Config{Games: 1, Strategies: []bots.Difficulty{bots.DifficultyRandom, "genius"}}); err == nil {
+		t.Errorf("Should have rejected unknown strategy")
+	}
+	rules := engine.DefaultRuleset()
+	rules.TargetScore = 0
+	if _, err := Run(Config{Games: 1, Strategies: []bots.Difficulty{bots.DifficultyRandom, bots.DifficultyRandom}, Rules: rules}); !errors.Is(err, engine.ErrInvalidRuleset) {
+		t.Errorf("Should have rejected invalid rules")
+	}
+}
+
+func Test_BotsSnapOutOfTurn(t *testing.T) {
+	rules := engine.DefaultRuleset()
+	rules.Snapping = true
+	strategies := []bots.Difficulty{bots.DifficultyMemory, bots.DifficultyExpected, bots.DifficultyMemory}
+	outOfTurn := 0
+	for i := 0; i < 20; i++ {
+		turn := -1
+		_, err := PlayGame(fmt.Sprintf("snap-%d", i), strategies, rules, func(e engine.Event) {
+			switch e.Type {
+			case engine.EventTurn:
+				turn = e.Seat
+			case engine.EventSnap:
+				if e.Seat != turn {
+					outOfTurn++
+				}
+			}
+		})
+		if err != nil {
+			t.Fatalf("Failed playing game, %v", err)
+		}
+	}
+	if outOfTurn == 0 {
+		t.Errorf("Bots should snap during other seats' turns")
+	}
+}
@@ -0,0 +1,109 @@
+// Package apierror defines the errors reported to clients. The same codes are
+// used by the REST API error bodies and the websocket error replies so clients
+// can handle both the same way.
+package apierror
+
+import (
+	"net/http"
+	"time"
+)
+
+// Code machine readable error code
+type Code string
+
+// Error codes
+const (
+	// CodeMalformedRequest the request body or command couldn't be parsed
+	CodeMalformedRequest Code = "MALFORMED_REQUEST"
+	// CodeValidationFailed one or more request fields are invalid, details lists them
+	CodeValidationFailed Code = "VALIDATION_FAILED"
+	// CodeInvalidArgument a request parameter has an unsupported value
+	CodeInvalidArgument Code = "INVALID_ARGUMENT"
+	// CodeUnauthorized the request isn't authenticated
+	CodeUnauthorized Code = "UNAUTHORIZED"
+	// CodeForbidden the user isn't allowed to perform the request
+	CodeForbidden Code = "FORBIDDEN"
+	// CodeNotFound the requested resource doesn't exist
+	CodeNotFound Code = "NOT_FOUND"
+	// CodeConflict the request conflicts with the current state
+	CodeConflict Code = "CONFLICT"
+	// CodeUnknownCommand the websocket command type isn't supported
+	CodeUnknownCommand Code = "UNKNOWN_COMMAND"
+	// CodeNotImplemented the route isn't implemented yet
+	CodeNotImplemented Code = "NOT_IMPLEMENTED"
+	// CodeInternal an unexpected server error, the cause isn't reported
+	CodeInternal Code = "INTERNAL_ERROR"
+
+	CodeGameNotFound         Code = "GAME_NOT_FOUND"
+	CodeGameFull             Code = "GAME_FULL"
+	CodeGameAlreadyStarted   Code = "GAME_ALREADY_STARTED"
+	CodeGameNotRunning       Code = "GAME_NOT_RUNNING"
+	CodeGameNotEnded         Code = "GAME_NOT_ENDED"
+	CodeWrongPassword        Code = "WRONG_PASSWORD"
+	CodeAlreadyInGame        Code = "ALREADY_IN_GAME"
+	CodeNotInGame            Code = "NOT_IN_GAME"
+	CodeNotGameOwner         Code = "NOT_GAME_OWNER"
+	CodeBannedFromGame       Code = "BANNED_FROM_GAME"
+	CodeFriendsOnlyGame      Code = "FRIENDS_ONLY_GAME"
+	CodeSpectatingDisabled   Code = "SPECTATING_DISABLED"
+	CodeTooManySpectators    Code = "TOO_MANY_SPECTATORS"
+	CodeNotYourTurn          Code = "NOT_YOUR_TURN"
+	CodeIllegalAction        Code = "ILLEGAL_ACTION"
+	CodeGameOver             Code = "GAME_OVER"
+	CodeInvalidRuleset       Code = "INVALID_RULESET"
+	CodeAlreadyQueued        Code = "ALREADY_QUEUED"
+	CodeNotQueued            Code = "NOT_QUEUED"
+	CodeChatRejected         Code = "CHAT_REJECTED"
+	CodeChatMuted            Code = "CHAT_MUTED"
+	CodeRateLimited          Code = "RATE_LIMITED"
+	CodeUserNotFound         Code = "USER_NOT_FOUND"
+	CodeUsernameTaken        Code = "USERNAME_TAKEN"
+	CodeAlreadyFriends       Code = "ALREADY_FRIENDS"
+	CodeFriendRequestPending Code = "FRIEND_REQUEST_PENDING"
+	CodeNotFriends           Code = "NOT_FRIENDS"
+	CodeUserBlocked          Code = "USER_BLOCKED"
+	CodeInviteNotFound       Code = "INVITE_NOT_FOUND"
+	CodeAvatarTooLarge       Code = "AVATAR_TOO_LARGE"
+	CodeUnsupportedAvatar    Code = "UNSUPPORTED_AVATAR_TYPE"
+	CodeUnsupportedProtocol  Code = "UNSUPPORTED_PROTOCOL"
+	CodeNotConnected         Code = "NOT_CONNECTED"
+)
+
+// Error an error reported to a client
+type Error struct {
+	Code    Code        `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	// Status the HTTP status the error is reported with
+	Status int `json:"-"`
+	// RetryAfter how long clients should wait before retrying, sent as the Retry-After header when set
+	RetryAfter time.Duration `json:"-"`
+}
+
+// New creates an error reported with the given HTTP status
+func New(status int, code Code, message string) *Error {
+	return &Error{Code: code, Message: message, Status: status}
+}
+
+// Internal the error reported for unexpected failures
+func Internal() *Error {
+	return New(http.StatusInternalServerError, CodeInternal, http.StatusText(http.StatusInternalServerError))
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithDetails returns a copy of the error carrying the given details
+func (e *Error) WithDetails(details interface{}) *Error {
+	withDetails := *e
+	withDetails.Details = details
+	return &withDetails
+}
+
+// WithRetryAfter returns a copy of the error telling clients to retry after the given duration
+func (e *Error) WithRetryAfter(retryAfter time.Duration) *Error {
+	withRetry := *e
+	withRetry.RetryAfter = retryAfter
+	return &withRetry
+}
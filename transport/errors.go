@@ -0,0 +1,149 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/backend"
+	"github.com/ngutman/kaboo-server-go/bots"
+	"github.com/ngutman/kaboo-server-go/engine"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// catalogEntry the code and status a known error is reported with
+type catalogEntry struct {
+	err    error
+	status int
+	code   apierror.Code
+}
+
+// errorCatalog every error clients are expected to handle, checked in order with errors.Is
+var errorCatalog = []catalogEntry{
+	// Games
+	{backend.ErrGameDoesntExist, http.StatusNotFound, apierror.CodeGameNotFound},
+	{mongo.ErrNoDocuments, http.StatusNotFound, apierror.CodeNotFound},
+	{models.ErrGameFull, http.StatusConflict, apierror.CodeGameFull},
+	{backend.ErrWrongGamePassword, http.StatusForbidden, apierror.CodeWrongPassword},
+	{backend.ErrTooManyWrongPasswords, http.StatusTooManyRequests, apierror.CodeRateLimited},
+	{backend.ErrAlreadyInGame, http.StatusConflict, apierror.CodeAlreadyInGame},
+	{backend.ErrAlreadyPlaying, http.StatusConflict, apierror.CodeAlreadyInGame},
+	{backend.ErrJoinGameAlreadyStarted, http.StatusConflict, apierror.CodeGameAlreadyStarted},
+	{backend.ErrGameNotRunning, http.StatusConflict, apierror.CodeGameNotRunning},
+	{backend.ErrGameNotEnded, http.StatusConflict, apierror.CodeGameNotEnded},
+	{backend.ErrNotInGame, http.StatusForbidden, apierror.CodeNotInGame},
+	{backend.ErrNotGameOwner, http.StatusForbidden, apierror.CodeNotGameOwner},
+	{backend.ErrCannotKickOwner, http.StatusConflict, apierror.CodeConflict},
+	{backend.ErrOwnerCannotLeave, http.StatusConflict, apierror.CodeConflict},
+	{backend.ErrFriendsOnlyGame, http.StatusForbidden, apierror.CodeFriendsOnlyGame},
+	{backend.ErrInvalidPerspective, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{backend.ErrUnknownBotDifficulty, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrBannedFromGame, http.StatusForbidden, apierror.CodeBannedFromGame},
+	{models.ErrPlayerNotInGame, http.StatusNotFound, apierror.CodeNotInGame},
+	{models.ErrInvalidMaxPlayers, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrUnknownVisibility, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrEntropyTooLong, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrInvalidSpectatorSettings, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrSpectatingDisabled, http.StatusForbidden, apierror.CodeSpectatingDisabled},
+	{models.ErrTooManySpectators, http.StatusConflict, apierror.CodeTooManySpectators},
+	{engine.ErrInvalidRuleset, http.StatusBadRequest, apierror.CodeInvalidRuleset},
+	// Game play
+	{engine.ErrNotYourTurn, http.StatusConflict, apierror.CodeNotYourTurn},
+	{engine.ErrGameOver, http.StatusConflict, apierror.CodeGameOver},
+	{engine.ErrIllegalAction, http.StatusBadRequest, apierror.CodeIllegalAction},
+	{engine.ErrInvalidSlot, http.StatusBadRequest, apierror.CodeIllegalAction},
+	{engine.ErrInvalidTarget, http.StatusBadRequest, apierror.CodeIllegalAction},
+	{engine.ErrInvalidStep, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{engine.ErrPlayerCount, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{bots.ErrUnknownDifficulty, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	// Matchmaking
+	{backend.ErrAlreadyQueued, http.StatusConflict, apierror.CodeAlreadyQueued},
+	{backend.ErrNotQueued, http.StatusConflict, apierror.CodeNotQueued},
+	{backend.ErrInvalidPlayerCount, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{backend.ErrUnknownRuleset, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	// Chat
+	{backend.ErrChatEmpty, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{backend.ErrChatTooLong, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{backend.ErrChatRateLimited, http.StatusTooManyRequests, apierror.CodeRateLimited},
+	{backend.ErrChatMuted, http.StatusForbidden, apierror.CodeChatMuted},
+	{backend.ErrChatRejected, http.StatusBadRequest, apierror.CodeChatRejected},
+	{backend.ErrNotInAudience, http.StatusForbidden, apierror.CodeForbidden},
+	// Profiles
+	{backend.ErrUserNotFound, http.StatusNotFound, apierror.CodeUserNotFound},
+	{backend.ErrAvatarTooLarge, http.StatusRequestEntityTooLarge, apierror.CodeAvatarTooLarge},
+	{backend.ErrUnsupportedAvatarType, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedAvatar},
+	{backend.ErrAvatarNotFound, http.StatusNotFound, apierror.CodeNotFound},
+	{models.ErrUsernameTaken, http.StatusConflict, apierror.CodeUsernameTaken},
+	{models.ErrInvalidUsername, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrInvalidDisplayName, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrUnknownAvatar, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrUnknownCardBack, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrUnsupportedLanguage, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrUnknownLeaderboardWindow, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrUnknownLeaderboardMetric, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	// Friends
+	{models.ErrFriendRequestToSelf, http.StatusBadRequest, apierror.CodeInvalidArgument},
+	{models.ErrAlreadyFriends, http.StatusConflict, apierror.CodeAlreadyFriends},
+	{models.ErrFriendRequestPending, http.StatusConflict, apierror.CodeFriendRequestPending},
+	{models.ErrNoFriendRequest, http.StatusNotFound, apierror.CodeNotFound},
+	{models.ErrNotFriends, http.StatusForbidden, apierror.CodeNotFriends},
+	{models.ErrUserBlocked, http.StatusForbidden, apierror.CodeUserBlocked},
+	{models.ErrNotBlocked, http.StatusNotFound, apierror.CodeNotFound},
+	{backend.ErrInviteNotFound, http.StatusNotFound, apierror.CodeInviteNotFound},
+	{backend.ErrNoGameToInviteTo, http.StatusConflict, apierror.CodeConflict},
+}
+
+// retryable an error telling clients when to try again
+type retryable interface {
+	RetryAfter() time.Duration
+}
+
+// toAPIError returns the error reported to clients for err. Errors missing from the
+// catalog are logged and reported as internal errors without exposing their cause
+func toAPIError(err error) *apierror.Error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		return apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, validationErr.Error()).
+			WithDetails(validationErr.Fields)
+	}
+	var mr *malformedRequest
+	if errors.As(err, &mr) {
+		return apierror.New(mr.status, apierror.CodeMalformedRequest, mr.msg)
+	}
+	for _, entry := range errorCatalog {
+		if errors.Is(err, entry.err) {
+			apiErr = apierror.New(entry.status, entry.code, err.Error())
+			var retry retryable
+			if errors.As(err, &retry) {
+				apiErr.RetryAfter = retry.RetryAfter()
+			}
+			return apiErr
+		}
+	}
+	log.Errorf("Unexpected error, %v\n", err)
+	return apierror.Internal()
+}
+
+// writeError responds with the JSON body {code,message,details} and status of err, and the
+// Retry-After header of errors telling clients when to try again
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := toAPIError(err)
+	body, _ := json.Marshal(apiErr)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+	w.WriteHeader(apiErr.Status)
+	w.Write(body)
+}
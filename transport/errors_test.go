@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ngutman/kaboo-server-go/backend"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+)
+
+func Test_ErrorCatalog(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   apierror.Code
+	}{
+		{backend.ErrGameDoesntExist, http.StatusNotFound, apierror.CodeGameNotFound},
+		{backend.ErrWrongGamePassword, http.StatusForbidden, apierror.CodeWrongPassword},
+		{fmt.Errorf("%w (maximum 2, current 2)", models.ErrGameFull), http.StatusConflict, apierror.CodeGameFull},
+		{backend.ErrAlreadyInGame, http.StatusConflict, apierror.CodeAlreadyInGame},
+		{&models.ValidationError{Fields: []models.FieldError{{Field: "name", Message: "empty"}}}, http.StatusBadRequest, apierror.CodeValidationFailed},
+		{errors.New("connection refused"), http.StatusInternalServerError, apierror.CodeInternal},
+	}
+	for _, test := range tests {
+		apiErr := toAPIError(test.err)
+		if apiErr.Status != test.status || apiErr.Code != test.code {
+			t.Errorf("%v should be reported as %v %v, got %v %v", test.err, test.status, test.code, apiErr.Status, apiErr.Code)
+		}
+	}
+	if toAPIError(errors.New("secret")).Message == "secret" {
+		t.Errorf("Unexpected errors shouldn't be exposed")
+	}
+}
+
+func Test_WriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeError(w, &models.ValidationError{Fields: []models.FieldError{{Field: "maxPlayers", Message: "too many"}}})
+	var body struct {
+		Code    string              `json:"code"`
+		Message string              `json:"message"`
+		Details []models.FieldError `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Error body should be JSON, %v", err)
+	}
+	if w.Code != http.StatusBadRequest || body.Code != string(apierror.CodeValidationFailed) ||
+		len(body.Details) != 1 || body.Details[0].Field != "maxPlayers" {
+		t.Errorf("Unexpected error response %v %+v", w.Code, body)
+	}
+}
@@ -0,0 +1,181 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultReadHeaderTimeout longest time to read request headers by default
+	DefaultReadHeaderTimeout = 10 * time.Second
+	// DefaultReadTimeout longest time to read a whole request by default, avatar uploads included
+	DefaultReadTimeout = 30 * time.Second
+	// DefaultWriteTimeout longest time to write a response by default, websockets clear it once upgraded
+	DefaultWriteTimeout = 30 * time.Second
+	// DefaultIdleTimeout how long idle keep-alive connections are kept open by default
+	DefaultIdleTimeout = 2 * time.Minute
+	// selfSignedValidity how long self signed certificates are valid, they're regenerated on every start
+	selfSignedValidity = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrInvalidHTTPConfig the HTTP server settings are out of range or conflicting
+	ErrInvalidHTTPConfig = errors.New("Invalid HTTP server settings")
+)
+
+// HTTPConfig HTTP server settings
+type HTTPConfig struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// TLSCertFile and TLSKeyFile PEM certificate chain and private key, TLS is disabled unless set
+	TLSCertFile string
+	TLSKeyFile  string
+	// SelfSigned serves TLS with a certificate generated on start for localhost, for local development
+	SelfSigned bool
+	// RedirectPort port redirecting plain HTTP requests to HTTPS, 0 disables it. Requires TLS
+	RedirectPort int
+	// HTTP2 negotiates HTTP/2 with TLS clients supporting it, websockets always use HTTP/1.1
+	HTTP2 bool
+}
+
+// DefaultHTTPConfig plain HTTP with timeouts, HTTP/2 is negotiated once TLS is enabled
+func DefaultHTTPConfig() HTTPConfig {
+	return HTTPConfig{
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		ReadTimeout:       DefaultReadTimeout,
+		WriteTimeout:      DefaultWriteTimeout,
+		IdleTimeout:       DefaultIdleTimeout,
+		HTTP2:             true,
+	}
+}
+
+// TLS returns whether requests are served over TLS
+func (c HTTPConfig) TLS() bool {
+	return c.TLSCertFile != "" || c.SelfSigned
+}
+
+// Validate returns an error wrapping ErrInvalidHTTPConfig if a setting is out of range or conflicts with another
+func (c HTTPConfig) Validate() error {
+	if c.ReadHeaderTimeout <= 0 || c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 {
+		return fmt.Errorf("%w, timeouts must be positive", ErrInvalidHTTPConfig)
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("%w, TLS certificate and key files must be set together", ErrInvalidHTTPConfig)
+	}
+	if c.TLSCertFile != "" && c.SelfSigned {
+		return fmt.Errorf("%w, TLS certificate files and a self signed certificate are mutually exclusive", ErrInvalidHTTPConfig)
+	}
+	if c.RedirectPort < 0 || c.RedirectPort > 65535 {
+		return fmt.Errorf("%w, redirect port must be 0-65535", ErrInvalidHTTPConfig)
+	}
+	if c.RedirectPort != 0 && !c.TLS() {
+		return fmt.Errorf("%w, redirecting to HTTPS requires TLS", ErrInvalidHTTPConfig)
+	}
+	return nil
+}
+
+// newHTTPServer returns a server for handler configured by config, with its certificate loaded when TLS is enabled
+func newHTTPServer(addr string, handler http.Handler, config HTTPConfig) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+	if !config.HTTP2 {
+		// A non nil map keeps the server from configuring HTTP/2
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	if !config.TLS() {
+		return server, nil
+	}
+	var certificate tls.Certificate
+	var err error
+	if config.SelfSigned {
+		certificate, err = selfSignedCertificate([]string{"localhost", "127.0.0.1", "::1"})
+	} else {
+		certificate, err = tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if config.HTTP2 {
+		server.TLSConfig.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return server, nil
+}
+
+// selfSignedCertificate generates a certificate for the given host names and IP addresses
+func selfSignedCertificate(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Kaboo development"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// redirectToHTTPS redirects every request to the same URL over HTTPS on the given port
+func redirectToHTTPS(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = strings.Trim(r.Host, "[]")
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		// 308 keeps the method and body of non GET requests
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_HTTPConfigValidation(t *testing.T) {
+	if err := DefaultHTTPConfig().Validate(); err != nil {
+		t.Errorf("Default config should be valid, %v", err)
+	}
+	invalid := []func(c *HTTPConfig){
+		func(c *HTTPConfig) { c.ReadHeaderTimeout = 0 },
+		func(c *HTTPConfig) { c.WriteTimeout = -1 },
+		func(c *HTTPConfig) { c.TLSCertFile = "cert.pem" },
+		func(c *HTTPConfig) { c.TLSKeyFile = "key.pem" },
+		func(c *HTTPConfig) { c.TLSCertFile, c.TLSKeyFile, c.SelfSigned = "cert.pem", "key.pem", true },
+		func(c *HTTPConfig) { c.RedirectPort = 80 },
+		func(c *HTTPConfig) { c.SelfSigned, c.RedirectPort = true, 70000 },
+	}
+	for i, change := range invalid {
+		config := DefaultHTTPConfig()
+		change(&config)
+		if err := config.Validate(); !errors.Is(err, ErrInvalidHTTPConfig) {
+			t.Errorf("Config %v should be rejected, got %v", i, err)
+		}
+	}
+	config := DefaultHTTPConfig()
+	config.SelfSigned, config.RedirectPort = true, 80
+	if err := config.Validate(); err != nil {
+		t.Errorf("Redirecting with TLS should be valid, got %v", err)
+	}
+}
+
+func Test_RedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		method    string
+		url       string
+		httpsPort int
+		location  string
+		status    int
+	}{
+		{http.MethodGet, "http://kaboo.example.com/api/v1/games?x=1", 443, "https://kaboo.example.com/api/v1/games?x=1", http.StatusMovedPermanently},
+		{http.MethodGet, "http://kaboo.example.com:80/", 8443, "https://kaboo.example.com:8443/", http.StatusMovedPermanently},
+		{http.MethodPost, "http://kaboo.example.com/api/v1/game/join", 443, "https://kaboo.example.com/api/v1/game/join", http.StatusPermanentRedirect},
+		{http.MethodGet, "http://[::1]:80/ws", 443, "https://[::1]/ws", http.StatusMovedPermanently},
+		{http.MethodGet, "http://[::1]/ws", 8443, "https://[::1]:8443/ws", http.StatusMovedPermanently},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		redirectToHTTPS(test.httpsPort).ServeHTTP(w, httptest.NewRequest(test.method, test.url, nil))
+		if w.Code != test.status || w.Header().Get("Location") != test.location {
+			t.Errorf("%v %v should redirect to %v with %v, got %v %v", test.method, test.url, test.location, test.status,
+				w.Header().Get("Location"), w.Code)
+		}
+	}
+}
+
+// serveTLS serves config on a local port and returns the protocol a client supporting HTTP/2 was served with
+func serveTLS(t *testing.T, config HTTPConfig) string {
+	server, err := newHTTPServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), config)
+	if err != nil {
+		t.Fatalf("Failed creating server, %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed listening, %v", err)
+	}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	response, err := client.Get("https://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed requesting over TLS, %v", err)
+	}
+	response.Body.Close()
+	return response.Proto
+}
+
+func Test_TLSServer(t *testing.T) {
+	config := DefaultHTTPConfig()
+	config.SelfSigned = true
+	if proto := serveTLS(t, config); proto != "HTTP/2.0" {
+		t.Errorf("HTTP/2 should be negotiated, got %v", proto)
+	}
+	config.HTTP2 = false
+	if proto := serveTLS(t, config); proto != "HTTP/1.1" {
+		t.Errorf("HTTP/2 should be disabled, got %v", proto)
+	}
+
+	certificate, err := selfSignedCertificate([]string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Failed generating certificate, %v", err)
+	}
+	parsed, _ := x509.ParseCertificate(certificate.Certificate[0])
+	if parsed.VerifyHostname("localhost") != nil || parsed.VerifyHostname("127.0.0.1") != nil {
+		t.Errorf("Certificate should be valid for localhost, got %v %v", parsed.DNSNames, parsed.IPAddresses)
+	}
+
+	dir, err := ioutil.TempDir("", "kaboo-tls")
+	if err != nil {
+		t.Fatalf("Failed creating directory, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	key, _ := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+	config = DefaultHTTPConfig()
+	config.TLSCertFile, config.TLSKeyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(config.TLSCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]}), 0600)
+	ioutil.WriteFile(config.TLSKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600)
+	if proto := serveTLS(t, config); proto != "HTTP/2.0" {
+		t.Errorf("Certificate files should be served with HTTP/2, got %v", proto)
+	}
+
+	config.TLSKeyFile = filepath.Join(dir, "missing.pem")
+	if _, err := newHTTPServer("", nil, config); err == nil {
+		t.Errorf("Missing key file should fail")
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/auth0-community/go-auth0"
 	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
 	"gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
 )
@@ -19,10 +20,13 @@ type JWTAuthMiddleware struct {
 	db            *models.Db
 	auth0Domain   string
 	auth0Audience string
+	// limits rate limits authenticated requests per user
+	limits *rateLimits
 }
 
 // Handle implements the JWT validation over incoming request
 func (j *JWTAuthMiddleware) Handle(next func(w http.ResponseWriter, r *http.Request, user *models.User)) func(w http.ResponseWriter, r *http.Request) {
+	next = j.limits.limitUser(next)
 	return func(w http.ResponseWriter, r *http.Request) {
 		// For faster development allowed to skip token authentication in DEBUG mode
 		if os.Getenv("DEBUG") != "" {
@@ -42,7 +46,7 @@ func (j *JWTAuthMiddleware) Handle(next func(w http.ResponseWriter, r *http.Requ
 		token, err := validator.ValidateRequest(r)
 		if err != nil {
 			log.Errorf("Token %v is invalid, %v\n", token, err)
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			writeError(w, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized, http.StatusText(http.StatusUnauthorized)))
 		} else {
 			claims := jwt.Claims{}
 			validator.Claims(r, token, &claims)
@@ -7,29 +7,303 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang/gddo/httputil/header"
+	"github.com/ngutman/kaboo-server-go/engine"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
 )
 
 type createGameReq struct {
-	Name            string `json:"name"`
-	MaxPlayersCount int    `json:"maxPlayers"`
-	Password        string `json:"password"`
+	Name            string               `json:"name"`
+	MaxPlayersCount int                  `json:"maxPlayers"`
+	Password        string               `json:"password"`
+	Entropy         string               `json:"entropy"`
+	Spectators      spectatorSettingsReq `json:"spectators"`
+	Unrated         bool                 `json:"unrated"`
+	Visibility      string               `json:"visibility"`
+	Rules           *rulesReq            `json:"rules"`
+}
+
+// rulesReq house rules, settings missing from the request keep their default
+type rulesReq struct {
+	engine.Ruleset
+}
+
+func (r *rulesReq) UnmarshalJSON(data []byte) error {
+	rules := engine.DefaultRuleset()
+	rules.Powers = nil
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return err
+	}
+	if rules.Powers == nil {
+		rules.Powers = engine.DefaultRuleset().Powers
+	}
+	r.Ruleset = rules
+	return nil
+}
+
+type spectatorSettingsReq struct {
+	Disabled      bool `json:"disabled"`
+	MaxSpectators int  `json:"maxSpectators"`
+	DelaySeconds  int  `json:"delaySeconds"`
 }
 
 type createGameRes struct {
 	GameID string `json:"id"`
+	// SeedCommitment lets the players verify the seed revealed once the game ends
+	SeedCommitment string `json:"seedCommitment"`
 }
 
 type joinGameReq struct {
 	GameID   string `json:"gameid"`
 	Password string `json:"password"`
+	Entropy  string `json:"entropy"`
 }
 
 type joinGameRes struct {
 	Success bool `json:"success"`
 }
 
+type spectateGameReq struct {
+	GameID string `json:"gameid"`
+}
+
+type muteUserReq struct {
+	GameID string `json:"gameid"`
+	UserID string `json:"userid"`
+	Muted  bool   `json:"muted"`
+}
+
+type chatHistoryRes struct {
+	Messages []websocket.ChatMessage `json:"messages"`
+}
+
+type joinMatchmakingReq struct {
+	Players int    `json:"players"`
+	Ruleset string `json:"ruleset"`
+}
+
+type profileRes struct {
+	UserID        string                 `json:"id"`
+	Username      string                 `json:"username"`
+	DisplayName   string                 `json:"displayName"`
+	Avatar        string                 `json:"avatar"`
+	AvatarURL     string                 `json:"avatarUrl,omitempty"`
+	Preferences   *websocket.Preferences `json:"preferences,omitempty"`
+	Rating        float64                `json:"rating"`
+	RatedGames    int                    `json:"ratedGames"`
+	RatingHistory []ratingChangeRes      `json:"ratingHistory"`
+}
+
+type updateProfileReq struct {
+	Username    *string         `json:"username"`
+	DisplayName *string         `json:"displayName"`
+	Avatar      *string         `json:"avatar"`
+	Preferences *preferencesReq `json:"preferences"`
+}
+
+type preferencesReq struct {
+	CardBack *string `json:"cardBack"`
+	Sound    *bool   `json:"sound"`
+	Language *string `json:"language"`
+}
+
+type stateRes struct {
+	User         profileRes `json:"user"`
+	ActiveGameID string     `json:"activeGameId,omitempty"`
+	Avatars      []string   `json:"avatars"`
+	CardBacks    []string   `json:"cardBacks"`
+	Languages    []string   `json:"languages"`
+}
+
+type friendReq struct {
+	UserID string `json:"userId"`
+}
+
+type friendResponseReq struct {
+	UserID string `json:"userId"`
+	Accept bool   `json:"accept"`
+}
+
+type friendRequestRes struct {
+	Status string `json:"status"`
+}
+
+type friendsRes struct {
+	Friends []friendRes `json:"friends"`
+}
+
+type friendRes struct {
+	User     websocket.User `json:"user"`
+	Status   string         `json:"status"`
+	Incoming bool           `json:"incoming"`
+	Online   bool           `json:"online"`
+}
+
+type inviteRes struct {
+	InviteID string `json:"inviteId"`
+}
+
+type inviteReq struct {
+	InviteID string `json:"inviteId"`
+}
+
+type ratingChangeRes struct {
+	GameID string    `json:"gameid"`
+	Before float64   `json:"before"`
+	After  float64   `json:"after"`
+	At     time.Time `json:"at"`
+}
+
+type historyRes struct {
+	Games  []historyEntryRes `json:"games"`
+	Total  int64             `json:"total"`
+	Offset int               `json:"offset"`
+	Limit  int               `json:"limit"`
+}
+
+type historyEntryRes struct {
+	GameID    string    `json:"gameid"`
+	Name      string    `json:"name"`
+	EndedAt   time.Time `json:"endedAt"`
+	Players   int       `json:"players"`
+	Placement int       `json:"placement"`
+	Score     int       `json:"score"`
+	Won       bool      `json:"won"`
+}
+
+type statsRes struct {
+	GamesPlayed      int     `json:"gamesPlayed"`
+	Wins             int     `json:"wins"`
+	WinRate          float64 `json:"winRate"`
+	AverageScore     float64 `json:"averageScore"`
+	KabooCalls       int     `json:"kabooCalls"`
+	KabooSuccesses   int     `json:"kabooSuccesses"`
+	KabooSuccessRate float64 `json:"kabooSuccessRate"`
+	BestRound        *int    `json:"bestRound"`
+}
+
+type leaderboardRes struct {
+	Window   string                `json:"window"`
+	Metric   string                `json:"metric"`
+	MinGames int                   `json:"minGames"`
+	Entries  []leaderboardEntryRes `json:"entries"`
+}
+
+type leaderboardEntryRes struct {
+	Rank         int       `json:"rank"`
+	UserID       string    `json:"id"`
+	Username     string    `json:"username"`
+	Rating       float64   `json:"rating"`
+	Games        int       `json:"games"`
+	Wins         int       `json:"wins"`
+	AverageScore float64   `json:"averageScore"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+type addBotReq struct {
+	GameID     string `json:"gameid"`
+	Difficulty string `json:"difficulty"`
+}
+
+type addBotRes struct {
+	BotID string `json:"id"`
+}
+
+type startGameReq struct {
+	GameID string `json:"gameid"`
+}
+
+type leaveGameReq struct {
+	GameID string `json:"gameid"`
+}
+
+type gameActionReq struct {
+	GameID string        `json:"gameid"`
+	Action engine.Action `json:"action"`
+}
+
+type successRes struct {
+	Success bool `json:"success"`
+}
+
+type gameInfoRes struct {
+	GameID         string         `json:"id"`
+	Name           string         `json:"name"`
+	Owner          string         `json:"owner"`
+	State          int            `json:"state"`
+	MaxPlayers     int            `json:"maxPlayers"`
+	Visibility     string         `json:"visibility"`
+	Rules          engine.Ruleset `json:"rules"`
+	Players        []string       `json:"players"`
+	Bots           []string       `json:"bots"`
+	SeedCommitment string         `json:"seedCommitment"`
+	Entropy        []string       `json:"entropy"`
+	Seed           string         `json:"seed,omitempty"`
+}
+
+type lobbyRes struct {
+	Games []lobbyGameRes `json:"games"`
+}
+
+type lobbyGameRes struct {
+	GameID     string         `json:"id"`
+	Name       string         `json:"name"`
+	Owner      string         `json:"owner"`
+	Players    int            `json:"players"`
+	MaxPlayers int            `json:"maxPlayers"`
+	Visibility string         `json:"visibility"`
+	Password   bool           `json:"password"`
+	Rules      engine.Ruleset `json:"rules"`
+}
+
+type lobbySettingsReq struct {
+	GameID     string  `json:"gameid"`
+	Name       *string `json:"name"`
+	MaxPlayers *int    `json:"maxPlayers"`
+	Visibility *string `json:"visibility"`
+}
+
+type kickPlayerReq struct {
+	GameID string `json:"gameid"`
+	UserID string `json:"userId"`
+	Ban    bool   `json:"ban"`
+}
+
+type transferOwnershipReq struct {
+	GameID string `json:"gameid"`
+	UserID string `json:"userId"`
+}
+
+type gameReplayRes struct {
+	GameID         string           `json:"gameid"`
+	Seed           string           `json:"seed"`
+	SeedCommitment string           `json:"seedCommitment"`
+	Entropy        []string         `json:"entropy"`
+	ShuffleSeed    string           `json:"shuffleSeed"`
+	Seats          []string         `json:"seats"`
+	Actions        []engine.Action  `json:"actions"`
+	Log            []replayEntryRes `json:"log"`
+}
+
+type replayEntryRes struct {
+	Seq    int            `json:"seq"`
+	Action *engine.Action `json:"action,omitempty"`
+	Events []engine.Event `json:"events"`
+}
+
+type replayStepRes struct {
+	GameID string          `json:"gameid"`
+	Step   int             `json:"step"`
+	Steps  int             `json:"steps"`
+	Action *engine.Action  `json:"action,omitempty"`
+	Events []engine.Event  `json:"events"`
+	State  engine.Snapshot `json:"state"`
+}
+
 type malformedRequest struct {
 	status int
 	msg    string
@@ -0,0 +1,2782 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+)
+
+// openAPISpec OpenAPI 3 specification of every route, Test_OpenAPISpecMatchesRouter keeps it in sync with the
+// router and the request and response structs
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Kaboo server API",
+    "version": "1",
+    "description": "REST API of the Kaboo card game server. Failed requests respond with an Error body. Requests are rate limited per IP address and per user, creating and joining games more strictly, and joining a game is locked for exponentially longer after repeated wrong passwords. Limited requests respond with 429 RATE_LIMITED and a Retry-After header. Browsers may call the API from the allowed origins only."
+  },
+  "servers": [
+    {
+      "url": "/api/v1"
+    }
+  ],
+  "security": [
+    {
+      "bearerAuth": []
+    }
+  ],
+  "paths": {
+    "/openapi.json": {
+      "get": {
+        "summary": "This specification",
+        "tags": [
+          "Meta"
+        ],
+        "responses": {
+          "200": {
+            "description": "OpenAPI 3 specification",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        },
+        "security": []
+      }
+    },
+    "/games": {
+      "get": {
+        "summary": "List the games waiting for players the user may join, newest first",
+        "tags": [
+          "Lobby"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Lobby"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/game/new": {
+      "post": {
+        "summary": "Create a game owned by the user",
+        "tags": [
+          "Games"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/CreatedGame"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/CreateGameRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/join": {
+      "post": {
+        "summary": "Join a game waiting for players",
+        "tags": [
+          "Games"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/JoinGameRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/leave": {
+      "post": {
+        "summary": "Leave a game. The owner must transfer ownership before leaving a game waiting for players, leaving a running game abandons it: it ends for everyone without results and isn't rated",
+        "tags": [
+          "Games"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/GameRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/spectate": {
+      "post": {
+        "summary": "Watch a game",
+        "tags": [
+          "Spectators"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/GameRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/unspectate": {
+      "post": {
+        "summary": "Stop watching a game",
+        "tags": [
+          "Spectators"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/GameRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/mute": {
+      "post": {
+        "summary": "Mute or unmute a user in the owner's game",
+        "tags": [
+          "Chat"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/MuteUserRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/{id}/chat": {
+      "get": {
+        "summary": "Latest chat messages of a game the user plays or watches",
+        "tags": [
+          "Chat"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ChatHistory"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "Game id",
+            "schema": {
+              "type": "string",
+              "description": "Object id, 24 hex characters",
+              "pattern": "^[0-9a-f]{24}$"
+            }
+          }
+        ]
+      }
+    },
+    "/game/settings": {
+      "post": {
+        "summary": "Change the lobby settings of the owner's game",
+        "tags": [
+          "Lobby"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/LobbySettingsRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/kick": {
+      "post": {
+        "summary": "Remove a player from the owner's game, optionally banning him",
+        "tags": [
+          "Lobby"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/KickPlayerRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/transfer": {
+      "post": {
+        "summary": "Hand the owner's game over to another seated player",
+        "tags": [
+          "Lobby"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/TransferOwnershipRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/bot": {
+      "post": {
+        "summary": "Seat a bot in the owner's game",
+        "tags": [
+          "Games"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/AddBotResponse"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/AddBotRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/start": {
+      "post": {
+        "summary": "Start the owner's game",
+        "tags": [
+          "Games"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/GameRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/action": {
+      "post": {
+        "summary": "Play an action in a running game",
+        "tags": [
+          "Games"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/GameActionRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/game/{id}": {
+      "get": {
+        "summary": "Public details of a game, friends only games are visible to the owner's friends and the users taking part only",
+        "tags": [
+          "Games"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/GameInfo"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "Game id",
+            "schema": {
+              "type": "string",
+              "description": "Object id, 24 hex characters",
+              "pattern": "^[0-9a-f]{24}$"
+            }
+          }
+        ]
+      }
+    },
+    "/game/{id}/replay": {
+      "get": {
+        "summary": "Seed and action log of an ended game the user may see. Replays of password protected games are only available to the users who took part in them",
+        "tags": [
+          "Replays"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Replay"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "Game id",
+            "schema": {
+              "type": "string",
+              "description": "Object id, 24 hex characters",
+              "pattern": "^[0-9a-f]{24}$"
+            }
+          }
+        ]
+      }
+    },
+    "/game/{id}/replay/{step}": {
+      "get": {
+        "summary": "State of an ended game the user may replay after a step",
+        "tags": [
+          "Replays"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ReplayStep"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "Game id",
+            "schema": {
+              "type": "string",
+              "description": "Object id, 24 hex characters",
+              "pattern": "^[0-9a-f]{24}$"
+            }
+          },
+          {
+            "name": "step",
+            "in": "path",
+            "required": true,
+            "description": "Step number, 0 is the deal",
+            "schema": {
+              "type": "integer",
+              "minimum": 0
+            }
+          },
+          {
+            "name": "perspective",
+            "in": "query",
+            "required": false,
+            "description": "A seat whose knowledge limits what is revealed, or omniscient (the default)",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ]
+      }
+    },
+    "/matchmaking/join": {
+      "post": {
+        "summary": "Wait for a match",
+        "tags": [
+          "Matchmaking"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/JoinMatchmakingRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/matchmaking/leave": {
+      "post": {
+        "summary": "Stop waiting for a match",
+        "tags": [
+          "Matchmaking"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/users/me/profile": {
+      "get": {
+        "summary": "The user's own profile",
+        "tags": [
+          "Profiles"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Profile"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      },
+      "put": {
+        "summary": "Update the user's profile, every field is validated before any is saved",
+        "tags": [
+          "Profiles"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/UpdateProfileRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Profile"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/users/me/avatar": {
+      "post": {
+        "summary": "Upload an avatar image, at most 256KB",
+        "tags": [
+          "Profiles"
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "image/png": {
+              "schema": {
+                "type": "string",
+                "format": "binary"
+              }
+            },
+            "image/jpeg": {
+              "schema": {
+                "type": "string",
+                "format": "binary"
+              }
+            },
+            "image/gif": {
+              "schema": {
+                "type": "string",
+                "format": "binary"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Profile"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/avatars/{file}": {
+      "get": {
+        "summary": "An uploaded avatar image",
+        "tags": [
+          "Profiles"
+        ],
+        "security": [],
+        "parameters": [
+          {
+            "name": "file",
+            "in": "path",
+            "required": true,
+            "description": "Avatar file name",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The image",
+            "content": {
+              "image/png": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              },
+              "image/jpeg": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              },
+              "image/gif": {
+                "schema": {
+                  "type": "string",
+                  "format": "binary"
+                }
+              }
+            }
+          },
+          "404": {
+            "description": "No such avatar"
+          }
+        }
+      }
+    },
+    "/friends": {
+      "get": {
+        "summary": "The user's friends, pending requests and blocked users",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Friends"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/friends/request": {
+      "post": {
+        "summary": "Send a friend request, accepted right away if the other user already sent one",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/FriendRequestStatus"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/FriendRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/friends/respond": {
+      "post": {
+        "summary": "Accept or decline a friend request",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/FriendResponseRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/friends/remove": {
+      "post": {
+        "summary": "End a friendship or cancel a request",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/FriendRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/friends/block": {
+      "post": {
+        "summary": "Block a user",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/FriendRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/friends/unblock": {
+      "post": {
+        "summary": "Remove a block the user placed",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/FriendRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/friends/invite": {
+      "post": {
+        "summary": "Invite a friend to the game the user is waiting in",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Invite"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/FriendRequest"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/invites/accept": {
+      "post": {
+        "summary": "Join the game the user was invited to",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/CreatedGame"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/Invite"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/invites/decline": {
+      "post": {
+        "summary": "Discard a game invite",
+        "tags": [
+          "Friends"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Success"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/Invite"
+              }
+            }
+          }
+        }
+      }
+    },
+    "/leaderboard": {
+      "get": {
+        "summary": "A precomputed leaderboard",
+        "tags": [
+          "Statistics"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Leaderboard"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "window",
+            "in": "query",
+            "required": false,
+            "description": "weekly, monthly or all_time (the default)",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "weekly",
+                "monthly",
+                "all_time"
+              ]
+            }
+          },
+          {
+            "name": "metric",
+            "in": "query",
+            "required": false,
+            "description": "rating (the default), wins or average_score",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "rating",
+                "wins",
+                "average_score"
+              ]
+            }
+          },
+          {
+            "name": "minGames",
+            "in": "query",
+            "required": false,
+            "description": "Least games a listed player played, 1 by default",
+            "schema": {
+              "type": "integer",
+              "minimum": 1
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "required": false,
+            "description": "Page size, 50 by default",
+            "schema": {
+              "type": "integer",
+              "minimum": 1,
+              "maximum": 100
+            }
+          }
+        ]
+      }
+    },
+    "/users/me/history": {
+      "get": {
+        "summary": "A page of the user's ended games, newest first",
+        "tags": [
+          "Statistics"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/History"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "offset",
+            "in": "query",
+            "required": false,
+            "description": "Games to skip",
+            "schema": {
+              "type": "integer",
+              "minimum": 0
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "required": false,
+            "description": "Page size, 20 by default",
+            "schema": {
+              "type": "integer",
+              "minimum": 1,
+              "maximum": 100
+            }
+          }
+        ]
+      }
+    },
+    "/users/me/stats": {
+      "get": {
+        "summary": "Aggregated statistics of the user's games",
+        "tags": [
+          "Statistics"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Stats"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/users/{id}/profile": {
+      "get": {
+        "summary": "Public profile of a user",
+        "tags": [
+          "Profiles"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Profile"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        },
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "User id",
+            "schema": {
+              "type": "string",
+              "description": "Object id, 24 hex characters",
+              "pattern": "^[0-9a-f]{24}$"
+            }
+          }
+        ]
+      }
+    },
+    "/state": {
+      "get": {
+        "summary": "The user's profile, active game and the available profile options",
+        "tags": [
+          "Profiles"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/State"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/ws": {
+      "get": {
+        "summary": "Upgrade to a websocket carrying game messages",
+        "tags": [
+          "Realtime"
+        ],
+        "description": "The protocol version and encoding are selected with the protocol and encoding query parameters or by offering kaboo.v<version>[.<encoding>] subprotocols, clients selecting neither speak version 1 in JSON. The negotiated version and encoding are returned in the Kaboo-Protocol-Version and Kaboo-Protocol-Encoding headers. MessagePack messages are sent one per binary frame. Browsers may only connect from the server's own origin or an allowed origin, others are rejected with 403 FORBIDDEN.",
+        "parameters": [
+          {
+            "name": "protocol",
+            "in": "query",
+            "required": false,
+            "description": "Protocol version, 1 to 3",
+            "schema": {
+              "type": "integer",
+              "minimum": 1,
+              "maximum": 3
+            }
+          },
+          {
+            "name": "encoding",
+            "in": "query",
+            "required": false,
+            "description": "json (the default) or msgpack, which requires protocol version 2",
+            "schema": {
+              "type": "string",
+              "enum": [
+                "json",
+                "msgpack"
+              ]
+            }
+          },
+          {
+            "name": "Sec-WebSocket-Protocol",
+            "in": "header",
+            "required": false,
+            "description": "Offered protocol versions, e.g. kaboo.v2.msgpack, kaboo.v2",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "101": {
+            "description": "Switching protocols"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/users/me/connection": {
+      "get": {
+        "summary": "Bytes exchanged over the user's current websocket connection, 404 NOT_CONNECTED when the user isn't connected. The totals of every connection are logged when it closes",
+        "tags": [
+          "Realtime"
+        ],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ConnectionStats"
+                }
+              }
+            }
+          },
+          "429": {
+            "$ref": "#/components/responses/RateLimited"
+          },
+          "default": {
+            "$ref": "#/components/responses/Error"
+          }
+        }
+      }
+    },
+    "/ws/schema.json": {
+      "get": {
+        "summary": "JSON Schema of the websocket messages",
+        "tags": [
+          "Realtime"
+        ],
+        "responses": {
+          "200": {
+            "description": "JSON Schema of every server message and client command",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        },
+        "security": []
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {
+        "type": "http",
+        "scheme": "bearer",
+        "bearerFormat": "JWT"
+      }
+    },
+    "responses": {
+      "Error": {
+        "description": "The request failed",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "RateLimited": {
+        "description": "Too many requests, or too many wrong passwords for the game",
+        "headers": {
+          "Retry-After": {
+            "description": "Seconds to wait before retrying",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        },
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      }
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "description": "Error reported for every failed request",
+        "properties": {
+          "code": {
+            "type": "string",
+            "description": "Machine readable error code, e.g. GAME_NOT_FOUND, WRONG_PASSWORD or GAME_FULL"
+          },
+          "message": {
+            "type": "string",
+            "description": "Human readable description"
+          },
+          "details": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/FieldError"
+            },
+            "description": "Invalid fields of a VALIDATION_FAILED error"
+          }
+        }
+      },
+      "FieldError": {
+        "type": "object",
+        "description": "A single invalid request field",
+        "properties": {
+          "field": {
+            "type": "string"
+          },
+          "message": {
+            "type": "string"
+          }
+        }
+      },
+      "Success": {
+        "type": "object",
+        "description": "Request succeeded",
+        "properties": {
+          "success": {
+            "type": "boolean"
+          }
+        }
+      },
+      "GameRequest": {
+        "type": "object",
+        "description": "Identifies a game",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          }
+        }
+      },
+      "CreateGameRequest": {
+        "type": "object",
+        "description": "Settings of a new game",
+        "properties": {
+          "name": {
+            "type": "string",
+            "minLength": 1,
+            "maxLength": 40,
+            "description": "Letters, digits, spaces and _.,:!?'&#()+-"
+          },
+          "maxPlayers": {
+            "type": "integer",
+            "minimum": 2,
+            "maximum": 8
+          },
+          "password": {
+            "type": "string",
+            "maxLength": 64
+          },
+          "entropy": {
+            "type": "string",
+            "description": "Owner's contribution to the shuffle seed"
+          },
+          "spectators": {
+            "$ref": "#/components/schemas/SpectatorSettings"
+          },
+          "unrated": {
+            "type": "boolean"
+          },
+          "visibility": {
+            "type": "string",
+            "enum": [
+              "public",
+              "unlisted",
+              "friends"
+            ]
+          },
+          "rules": {
+            "$ref": "#/components/schemas/Ruleset"
+          }
+        }
+      },
+      "SpectatorSettings": {
+        "type": "object",
+        "description": "Who may watch the game",
+        "properties": {
+          "disabled": {
+            "type": "boolean"
+          },
+          "maxSpectators": {
+            "type": "integer"
+          },
+          "delaySeconds": {
+            "type": "integer",
+            "description": "Delay of the spectator stream"
+          }
+        }
+      },
+      "Ruleset": {
+        "type": "object",
+        "description": "House rules, settings missing from a request keep their default",
+        "properties": {
+          "handSize": {
+            "type": "integer",
+            "minimum": 1,
+            "maximum": 6
+          },
+          "initialPeekCount": {
+            "type": "integer"
+          },
+          "jokers": {
+            "type": "boolean"
+          },
+          "snapping": {
+            "type": "boolean"
+          },
+          "snapPenalty": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 50
+          },
+          "powers": {
+            "type": "object",
+            "description": "Power granted by discarding each rank, keyed by rank name (A, 2-10, J, Q, K, Joker)",
+            "additionalProperties": {
+              "type": "string",
+              "enum": [
+                "",
+                "peek",
+                "spy",
+                "blind_swap",
+                "look_and_swap"
+              ]
+            }
+          },
+          "kabooPenalty": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 50
+          },
+          "targetScore": {
+            "type": "integer",
+            "minimum": 10,
+            "maximum": 500
+          }
+        }
+      },
+      "CreatedGame": {
+        "type": "object",
+        "description": "The game that was created or joined",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "seedCommitment": {
+            "type": "string",
+            "description": "Hex encoded SHA-256 of the game seed, revealed once the game ends"
+          }
+        }
+      },
+      "JoinGameRequest": {
+        "type": "object",
+        "description": "Game to join",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "password": {
+            "type": "string"
+          },
+          "entropy": {
+            "type": "string"
+          }
+        }
+      },
+      "MuteUserRequest": {
+        "type": "object",
+        "description": "Mutes or unmutes a user in the owner's game",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "userid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "muted": {
+            "type": "boolean"
+          }
+        }
+      },
+      "ChatHistory": {
+        "type": "object",
+        "description": "Latest chat messages of a game",
+        "properties": {
+          "messages": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ChatMessage"
+            }
+          }
+        }
+      },
+      "ChatMessage": {
+        "type": "object",
+        "description": "A chat message",
+        "properties": {
+          "id": {
+            "type": "string"
+          },
+          "user": {
+            "$ref": "#/components/schemas/User"
+          },
+          "text": {
+            "type": "string"
+          },
+          "sentAt": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "User": {
+        "type": "object",
+        "description": "Public user details",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "name": {
+            "type": "string"
+          },
+          "displayName": {
+            "type": "string"
+          },
+          "avatar": {
+            "type": "string"
+          },
+          "preferences": {
+            "$ref": "#/components/schemas/Preferences"
+          }
+        }
+      },
+      "Preferences": {
+        "type": "object",
+        "description": "Client settings, omitted fields are left unchanged by updates",
+        "properties": {
+          "cardBack": {
+            "type": "string"
+          },
+          "sound": {
+            "type": "boolean"
+          },
+          "language": {
+            "type": "string"
+          }
+        }
+      },
+      "JoinMatchmakingRequest": {
+        "type": "object",
+        "description": "Match the user wants to be seated in",
+        "properties": {
+          "players": {
+            "type": "integer",
+            "minimum": 2,
+            "maximum": 8
+          },
+          "ruleset": {
+            "type": "string",
+            "enum": [
+              "standard"
+            ],
+            "description": "Rules of the match, users are only matched with users asking for the same ones. Defaults to standard, the default rules"
+          }
+        }
+      },
+      "Profile": {
+        "type": "object",
+        "description": "User profile, preferences are only included in the user's own profile",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "username": {
+            "type": "string"
+          },
+          "displayName": {
+            "type": "string"
+          },
+          "avatar": {
+            "type": "string"
+          },
+          "avatarUrl": {
+            "type": "string"
+          },
+          "preferences": {
+            "$ref": "#/components/schemas/Preferences"
+          },
+          "rating": {
+            "type": "number"
+          },
+          "ratedGames": {
+            "type": "integer"
+          },
+          "ratingHistory": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/RatingChange"
+            }
+          }
+        }
+      },
+      "RatingChange": {
+        "type": "object",
+        "description": "Rating change caused by a game",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "before": {
+            "type": "number"
+          },
+          "after": {
+            "type": "number"
+          },
+          "at": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "UpdateProfileRequest": {
+        "type": "object",
+        "description": "Profile fields to update, omitted fields are left unchanged",
+        "properties": {
+          "username": {
+            "type": "string",
+            "pattern": "^[A-Za-z0-9_-]{3,20}$"
+          },
+          "displayName": {
+            "type": "string",
+            "maxLength": 32
+          },
+          "avatar": {
+            "type": "string"
+          },
+          "preferences": {
+            "$ref": "#/components/schemas/Preferences"
+          }
+        }
+      },
+      "State": {
+        "type": "object",
+        "description": "Everything a client needs on startup",
+        "properties": {
+          "user": {
+            "$ref": "#/components/schemas/Profile"
+          },
+          "activeGameId": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "avatars": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "cardBacks": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "languages": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "FriendRequest": {
+        "type": "object",
+        "description": "The other user",
+        "properties": {
+          "userId": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          }
+        }
+      },
+      "FriendResponseRequest": {
+        "type": "object",
+        "description": "Answer to a friend request",
+        "properties": {
+          "userId": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "accept": {
+            "type": "boolean"
+          }
+        }
+      },
+      "FriendRequestStatus": {
+        "type": "object",
+        "description": "Resulting relation",
+        "properties": {
+          "status": {
+            "type": "string",
+            "enum": [
+              "pending",
+              "accepted"
+            ]
+          }
+        }
+      },
+      "Friends": {
+        "type": "object",
+        "description": "The user's relations",
+        "properties": {
+          "friends": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Friend"
+            }
+          }
+        }
+      },
+      "Friend": {
+        "type": "object",
+        "description": "A related user",
+        "properties": {
+          "user": {
+            "$ref": "#/components/schemas/User"
+          },
+          "status": {
+            "type": "string",
+            "enum": [
+              "pending",
+              "accepted",
+              "blocked"
+            ]
+          },
+          "incoming": {
+            "type": "boolean",
+            "description": "The request was sent by the other user"
+          },
+          "online": {
+            "type": "boolean"
+          }
+        }
+      },
+      "Invite": {
+        "type": "object",
+        "description": "A game invite",
+        "properties": {
+          "inviteId": {
+            "type": "string"
+          }
+        }
+      },
+      "History": {
+        "type": "object",
+        "description": "A page of the user's ended games",
+        "properties": {
+          "games": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/HistoryEntry"
+            }
+          },
+          "total": {
+            "type": "integer"
+          },
+          "offset": {
+            "type": "integer"
+          },
+          "limit": {
+            "type": "integer"
+          }
+        }
+      },
+      "HistoryEntry": {
+        "type": "object",
+        "description": "An ended game",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "name": {
+            "type": "string"
+          },
+          "endedAt": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "players": {
+            "type": "integer"
+          },
+          "placement": {
+            "type": "integer"
+          },
+          "score": {
+            "type": "integer"
+          },
+          "won": {
+            "type": "boolean"
+          }
+        }
+      },
+      "Stats": {
+        "type": "object",
+        "description": "Aggregated statistics of the user's games",
+        "properties": {
+          "gamesPlayed": {
+            "type": "integer"
+          },
+          "wins": {
+            "type": "integer"
+          },
+          "winRate": {
+            "type": "number"
+          },
+          "averageScore": {
+            "type": "number"
+          },
+          "kabooCalls": {
+            "type": "integer"
+          },
+          "kabooSuccesses": {
+            "type": "integer"
+          },
+          "kabooSuccessRate": {
+            "type": "number"
+          },
+          "bestRound": {
+            "type": "integer",
+            "nullable": true
+          }
+        }
+      },
+      "Leaderboard": {
+        "type": "object",
+        "description": "A leaderboard page",
+        "properties": {
+          "window": {
+            "type": "string",
+            "enum": [
+              "weekly",
+              "monthly",
+              "all_time"
+            ]
+          },
+          "metric": {
+            "type": "string",
+            "enum": [
+              "rating",
+              "wins",
+              "average_score"
+            ]
+          },
+          "minGames": {
+            "type": "integer"
+          },
+          "entries": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/LeaderboardEntry"
+            }
+          }
+        }
+      },
+      "LeaderboardEntry": {
+        "type": "object",
+        "description": "A ranked player",
+        "properties": {
+          "rank": {
+            "type": "integer"
+          },
+          "id": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "username": {
+            "type": "string"
+          },
+          "rating": {
+            "type": "number"
+          },
+          "games": {
+            "type": "integer"
+          },
+          "wins": {
+            "type": "integer"
+          },
+          "averageScore": {
+            "type": "number"
+          },
+          "updatedAt": {
+            "type": "string",
+            "format": "date-time"
+          }
+        }
+      },
+      "AddBotRequest": {
+        "type": "object",
+        "description": "Bot to seat",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "difficulty": {
+            "type": "string",
+            "enum": [
+              "random",
+              "memory",
+              "expected"
+            ]
+          }
+        }
+      },
+      "AddBotResponse": {
+        "type": "object",
+        "description": "The seated bot",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          }
+        }
+      },
+      "GameActionRequest": {
+        "type": "object",
+        "description": "An action played in a running game, the seat is set by the server",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "action": {
+            "$ref": "#/components/schemas/Action"
+          }
+        }
+      },
+      "Action": {
+        "type": "object",
+        "description": "A game action",
+        "properties": {
+          "type": {
+            "type": "string",
+            "enum": [
+              "draw_deck",
+              "draw_discard",
+              "call_kaboo",
+              "replace",
+              "discard",
+              "peek",
+              "spy",
+              "blind_swap",
+              "look",
+              "look_decision",
+              "skip_power",
+              "snap"
+            ]
+          },
+          "seat": {
+            "type": "integer"
+          },
+          "slot": {
+            "type": "integer"
+          },
+          "targetSeat": {
+            "type": "integer"
+          },
+          "targetSlot": {
+            "type": "integer"
+          },
+          "swap": {
+            "type": "boolean"
+          }
+        }
+      },
+      "Event": {
+        "type": "object",
+        "description": "A game event, cards are only included when revealed to the viewer",
+        "properties": {
+          "type": {
+            "type": "string",
+            "enum": [
+              "deal",
+              "turn",
+              "draw",
+              "discard",
+              "replace",
+              "peek",
+              "swap",
+              "kaboo",
+              "snap",
+              "reshuffle",
+              "round_end",
+              "game_end"
+            ]
+          },
+          "round": {
+            "type": "integer"
+          },
+          "seat": {
+            "type": "integer"
+          },
+          "slot": {
+            "type": "integer"
+          },
+          "targetSeat": {
+            "type": "integer"
+          },
+          "targetSlot": {
+            "type": "integer"
+          },
+          "pile": {
+            "type": "string",
+            "enum": [
+              "deck",
+              "discard"
+            ]
+          },
+          "card": {
+            "$ref": "#/components/schemas/Card"
+          },
+          "revealedTo": {
+            "type": "integer",
+            "description": "Seat the card is revealed to, -1 for everyone"
+          },
+          "discarded": {
+            "$ref": "#/components/schemas/Card"
+          },
+          "hands": {
+            "type": "array",
+            "items": {
+              "type": "array",
+              "items": {
+                "$ref": "#/components/schemas/Card"
+              }
+            }
+          },
+          "roundScores": {
+            "type": "array",
+            "items": {
+              "type": "integer"
+            }
+          },
+          "scores": {
+            "type": "array",
+            "items": {
+              "type": "integer"
+            }
+          },
+          "winners": {
+            "type": "array",
+            "items": {
+              "type": "integer"
+            }
+          },
+          "penalty": {
+            "type": "integer"
+          }
+        }
+      },
+      "Card": {
+        "type": "object",
+        "description": "A card",
+        "properties": {
+          "rank": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 13,
+            "description": "0 is a joker, 1 an ace and 11-13 the face cards"
+          },
+          "suit": {
+            "type": "integer",
+            "minimum": 0,
+            "maximum": 4,
+            "description": "0 for jokers"
+          }
+        }
+      },
+      "Snapshot": {
+        "type": "object",
+        "description": "Game state as known by a perspective, unknown cards are null",
+        "properties": {
+          "seat": {
+            "type": "integer"
+          },
+          "players": {
+            "type": "integer"
+          },
+          "round": {
+            "type": "integer"
+          },
+          "turn": {
+            "type": "integer"
+          },
+          "phase": {
+            "type": "string",
+            "enum": [
+              "turn_start",
+              "drawn",
+              "power",
+              "look_decision",
+              "game_over"
+            ]
+          },
+          "currentSeat": {
+            "type": "integer"
+          },
+          "power": {
+            "type": "string",
+            "enum": [
+              "",
+              "peek",
+              "spy",
+              "blind_swap",
+              "look_and_swap"
+            ]
+          },
+          "handSizes": {
+            "type": "array",
+            "items": {
+              "type": "integer"
+            }
+          },
+          "deckSize": {
+            "type": "integer"
+          },
+          "discardTop": {
+            "$ref": "#/components/schemas/Card"
+          },
+          "drawn": {
+            "$ref": "#/components/schemas/Card"
+          },
+          "drawnFrom": {
+            "type": "string",
+            "enum": [
+              "deck",
+              "discard"
+            ]
+          },
+          "kabooCaller": {
+            "type": "integer"
+          },
+          "scores": {
+            "type": "array",
+            "items": {
+              "type": "integer"
+            }
+          },
+          "winners": {
+            "type": "array",
+            "items": {
+              "type": "integer"
+            }
+          },
+          "hands": {
+            "type": "array",
+            "items": {
+              "type": "array",
+              "items": {
+                "$ref": "#/components/schemas/Card"
+              }
+            }
+          }
+        }
+      },
+      "GameInfo": {
+        "type": "object",
+        "description": "Public game details, the seed is only revealed once the game ended",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "name": {
+            "type": "string"
+          },
+          "owner": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "state": {
+            "type": "integer",
+            "enum": [
+              0,
+              1,
+              2
+            ],
+            "description": "0 waiting for players, 1 ongoing, 2 ended"
+          },
+          "maxPlayers": {
+            "type": "integer"
+          },
+          "visibility": {
+            "type": "string",
+            "enum": [
+              "public",
+              "unlisted",
+              "friends"
+            ]
+          },
+          "rules": {
+            "$ref": "#/components/schemas/Ruleset"
+          },
+          "players": {
+            "type": "array",
+            "items": {
+              "type": "string",
+              "description": "Object id, 24 hex characters",
+              "pattern": "^[0-9a-f]{24}$"
+            }
+          },
+          "bots": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "seedCommitment": {
+            "type": "string"
+          },
+          "entropy": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "seed": {
+            "type": "string"
+          }
+        }
+      },
+      "Lobby": {
+        "type": "object",
+        "description": "Games waiting for players",
+        "properties": {
+          "games": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/LobbyGame"
+            }
+          }
+        }
+      },
+      "LobbyGame": {
+        "type": "object",
+        "description": "A game waiting for players",
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "name": {
+            "type": "string"
+          },
+          "owner": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "players": {
+            "type": "integer"
+          },
+          "maxPlayers": {
+            "type": "integer"
+          },
+          "visibility": {
+            "type": "string",
+            "enum": [
+              "public",
+              "unlisted",
+              "friends"
+            ]
+          },
+          "password": {
+            "type": "boolean",
+            "description": "Joining requires a password"
+          },
+          "rules": {
+            "$ref": "#/components/schemas/Ruleset"
+          }
+        }
+      },
+      "LobbySettingsRequest": {
+        "type": "object",
+        "description": "Lobby settings to change, omitted fields are left unchanged",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "name": {
+            "type": "string"
+          },
+          "maxPlayers": {
+            "type": "integer"
+          },
+          "visibility": {
+            "type": "string",
+            "enum": [
+              "public",
+              "unlisted",
+              "friends"
+            ]
+          }
+        }
+      },
+      "KickPlayerRequest": {
+        "type": "object",
+        "description": "Player to remove",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "userId": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "ban": {
+            "type": "boolean"
+          }
+        }
+      },
+      "TransferOwnershipRequest": {
+        "type": "object",
+        "description": "The new owner",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "userId": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          }
+        }
+      },
+      "Replay": {
+        "type": "object",
+        "description": "Everything needed to replay an ended game",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "seed": {
+            "type": "string"
+          },
+          "seedCommitment": {
+            "type": "string"
+          },
+          "entropy": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "shuffleSeed": {
+            "type": "string"
+          },
+          "seats": {
+            "type": "array",
+            "items": {
+              "type": "string",
+              "description": "Object id, 24 hex characters",
+              "pattern": "^[0-9a-f]{24}$"
+            }
+          },
+          "actions": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Action"
+            }
+          },
+          "log": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/ReplayEntry"
+            }
+          }
+        }
+      },
+      "ReplayEntry": {
+        "type": "object",
+        "description": "An action and its events, the deal has no action",
+        "properties": {
+          "seq": {
+            "type": "integer"
+          },
+          "action": {
+            "$ref": "#/components/schemas/Action"
+          },
+          "events": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Event"
+            }
+          }
+        }
+      },
+      "ConnectionStats": {
+        "type": "object",
+        "description": "Bytes exchanged over a websocket connection, including framing and after compression",
+        "properties": {
+          "bytesSent": {
+            "type": "integer"
+          },
+          "bytesReceived": {
+            "type": "integer"
+          }
+        }
+      },
+      "ReplayStep": {
+        "type": "object",
+        "description": "Game state after a replay step",
+        "properties": {
+          "gameid": {
+            "type": "string",
+            "description": "Object id, 24 hex characters",
+            "pattern": "^[0-9a-f]{24}$"
+          },
+          "step": {
+            "type": "integer"
+          },
+          "steps": {
+            "type": "integer"
+          },
+          "action": {
+            "$ref": "#/components/schemas/Action"
+          },
+          "events": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Event"
+            }
+          },
+          "state": {
+            "$ref": "#/components/schemas/Snapshot"
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// handleOpenAPISpec serves the API specification
+func (a *API) handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(openAPISpec))
+}
+
+// handleProtocolSchema serves the JSON Schema of the websocket protocol
+func (a *API) handleProtocolSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(websocket.ProtocolSchema))
+}
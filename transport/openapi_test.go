@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+)
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref"`
+	Type                 string                    `json:"type"`
+	Properties           map[string]*openAPISchema `json:"properties"`
+	Items                *openAPISchema            `json:"items"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties"`
+}
+
+// specSchemas the request and response structs described by each schema
+var specSchemas = map[string][]interface{}{
+	"Error":                    {apierror.Error{}},
+	"FieldError":               {models.FieldError{}},
+	"Success":                  {successRes{}, joinGameRes{}},
+	"GameRequest":              {spectateGameReq{}, startGameReq{}, leaveGameReq{}},
+	"CreateGameRequest":        {createGameReq{}},
+	"CreatedGame":              {createGameRes{}},
+	"JoinGameRequest":          {joinGameReq{}},
+	"MuteUserRequest":          {muteUserReq{}},
+	"ChatHistory":              {chatHistoryRes{}},
+	"JoinMatchmakingRequest":   {joinMatchmakingReq{}},
+	"ConnectionStats":          {websocket.ConnectionStats{}},
+	"Profile":                  {profileRes{}},
+	"UpdateProfileRequest":     {updateProfileReq{}},
+	"State":                    {stateRes{}},
+	"FriendRequest":            {friendReq{}},
+	"FriendResponseRequest":    {friendResponseReq{}},
+	"FriendRequestStatus":      {friendRequestRes{}},
+	"Friends":                  {friendsRes{}},
+	"Invite":                   {inviteReq{}, inviteRes{}},
+	"History":                  {historyRes{}},
+	"Stats":                    {statsRes{}},
+	"Leaderboard":              {leaderboardRes{}},
+	"AddBotRequest":            {addBotReq{}},
+	"AddBotResponse":           {addBotRes{}},
+	"GameActionRequest":        {gameActionReq{}},
+	"GameInfo":                 {gameInfoRes{}},
+	"Lobby":                    {lobbyRes{}},
+	"LobbySettingsRequest":     {lobbySettingsReq{}},
+	"KickPlayerRequest":        {kickPlayerReq{}},
+	"TransferOwnershipRequest": {transferOwnershipReq{}},
+	"Replay":                   {gameReplayRes{}},
+	"ReplayStep":               {replayStepRes{}},
+}
+
+var pathVariablePattern = regexp.MustCompile(`\{([^}:]+):[^}]+\}`)
+
+func loadOpenAPISpec(t *testing.T) *openAPIDocument {
+	var spec openAPIDocument
+	if err := json.Unmarshal([]byte(openAPISpec), &spec); err != nil {
+		t.Fatalf("Spec isn't valid JSON, %v", err)
+	}
+	return &spec
+}
+
+func Test_OpenAPISpecMatchesRouter(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	prefix := fmt.Sprintf("/api/v%s", apiVersion)
+	registered := map[string]bool{}
+	testServer(t).router().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil || route.GetHandler() == nil {
+			return nil
+		}
+		path := pathVariablePattern.ReplaceAllString(strings.TrimPrefix(template, prefix), "{$1}")
+		registered[path] = true
+		operations, ok := spec.Paths[path]
+		if !ok {
+			t.Errorf("Route %v isn't documented", path)
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Routes without a method restriction must document at least one operation
+			if len(operations) == 0 {
+				t.Errorf("Route %v has no documented operations", path)
+			}
+			return nil
+		}
+		for _, method := range methods {
+			if _, ok := operations[strings.ToLower(method)]; !ok {
+				t.Errorf("%v %v isn't documented", method, path)
+			}
+		}
+		return nil
+	})
+	for path := range spec.Paths {
+		if !registered[path] {
+			t.Errorf("Documented path %v isn't routed", path)
+		}
+	}
+}
+
+func Test_OpenAPISpecMatchesStructs(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	checked := map[string]bool{}
+	for name, values := range specSchemas {
+		checked[name] = true
+		for _, value := range values {
+			checkSchema(t, spec, name, spec.Components.Schemas[name], reflect.TypeOf(value), checked)
+		}
+	}
+	for name := range spec.Components.Schemas {
+		if !checked[name] {
+			t.Errorf("Schema %v doesn't describe any struct", name)
+		}
+	}
+}
+
+// checkSchema compares a schema with the JSON encoding of goType, following references and nested types
+func checkSchema(t *testing.T, spec *openAPIDocument, at string, schema *openAPISchema, goType reflect.Type, checked map[string]bool) {
+	if schema == nil {
+		t.Errorf("%v: missing schema", at)
+		return
+	}
+	for goType.Kind() == reflect.Ptr {
+		goType = goType.Elem()
+	}
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		checked[name] = true
+		checkSchema(t, spec, name, spec.Components.Schemas[name], goType, checked)
+		return
+	}
+	if goType.Kind() == reflect.Interface {
+		return
+	}
+	if goType == reflect.TypeOf(time.Time{}) {
+		if schema.Type != "string" {
+			t.Errorf("%v: times are encoded as strings, documented as %v", at, schema.Type)
+		}
+		return
+	}
+	switch goType.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(goType)
+		var documented, actual []string
+		for name := range schema.Properties {
+			documented = append(documented, name)
+		}
+		for name := range fields {
+			actual = append(actual, name)
+		}
+		sort.Strings(documented)
+		sort.Strings(actual)
+		if !reflect.DeepEqual(documented, actual) {
+			t.Errorf("%v: documented properties %v don't match %v fields %v", at, documented, goType, actual)
+			return
+		}
+		for name, field := range fields {
+			checkSchema(t, spec, at+"."+name, schema.Properties[name], field, checked)
+		}
+	case reflect.Slice, reflect.Array:
+		if schema.Type != "array" {
+			t.Errorf("%v: %v is documented as %v", at, goType, schema.Type)
+			return
+		}
+		checkSchema(t, spec, at+"[]", schema.Items, goType.Elem(), checked)
+	case reflect.Map:
+		if schema.Type != "object" {
+			t.Errorf("%v: %v is documented as %v", at, goType, schema.Type)
+			return
+		}
+		checkSchema(t, spec, at+"{}", schema.AdditionalProperties, goType.Elem(), checked)
+	default:
+		expected := map[reflect.Kind]string{
+			reflect.String: "string", reflect.Bool: "boolean", reflect.Float64: "number",
+			reflect.Int: "integer", reflect.Int64: "integer", reflect.Uint64: "integer",
+		}[goType.Kind()]
+		if schema.Type != expected {
+			t.Errorf("%v: %v should be documented as %v, not %v", at, goType, expected, schema.Type)
+		}
+	}
+}
+
+// jsonFields returns the JSON encoded fields of a struct, promoting the fields of embedded structs
+func jsonFields(goType reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < goType.NumField(); i++ {
+		field := goType.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && tag == "" {
+			for name, embedded := range jsonFields(field.Type) {
+				fields[name] = embedded
+			}
+			continue
+		}
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		fields[tag] = field.Type
+	}
+	return fields
+}
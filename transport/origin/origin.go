@@ -0,0 +1,110 @@
+// Package origin decides which web origins may use the API from a browser. The same allowlist
+// is applied to the REST CORS headers and to websocket upgrades so both accept the same sites.
+package origin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Any allows every origin, meant for local development only
+	Any = "*"
+	// subdomainWildcard prefixes a host to allow all of its subdomains, e.g. https://*.example.com
+	subdomainWildcard = "*."
+)
+
+var (
+	// ErrInvalidOrigin an allowed origin isn't a scheme and host, optionally with a port
+	ErrInvalidOrigin = errors.New("Invalid allowed origin")
+)
+
+// Allowlist the origins allowed besides the API's own
+type Allowlist struct {
+	any        bool
+	origins    map[string]bool
+	subdomains []subdomains
+}
+
+// subdomains every subdomain of a domain over a scheme
+type subdomains struct {
+	scheme string
+	// suffix the domain with a leading dot, e.g. .example.com
+	suffix string
+}
+
+// Parse returns an allowlist of the given origins. An origin is a scheme and host like
+// https://kaboo.example.com or http://localhost:3000, a leading *. in the host allows every
+// subdomain and * allows any origin. An empty list only allows same origin requests
+func Parse(origins []string) (*Allowlist, error) {
+	allowlist := &Allowlist{origins: make(map[string]bool)}
+	for _, origin := range origins {
+		origin = normalize(origin)
+		if origin == Any {
+			allowlist.any = true
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return nil, fmt.Errorf("%w %q, expected scheme://host[:port]", ErrInvalidOrigin, origin)
+		}
+		if strings.HasPrefix(u.Host, subdomainWildcard) {
+			domain := strings.TrimPrefix(u.Host, subdomainWildcard)
+			if domain == "" || strings.Contains(domain, "*") {
+				return nil, fmt.Errorf("%w %q, wildcards are only allowed as the first label", ErrInvalidOrigin, origin)
+			}
+			allowlist.subdomains = append(allowlist.subdomains, subdomains{u.Scheme, "." + domain})
+			continue
+		}
+		if strings.Contains(u.Host, "*") {
+			return nil, fmt.Errorf("%w %q, wildcards are only allowed as the first label", ErrInvalidOrigin, origin)
+		}
+		allowlist.origins[origin] = true
+	}
+	return allowlist, nil
+}
+
+// Allows returns whether a browser on the given origin may use the API, as sent in the Origin header
+func (a *Allowlist) Allows(origin string) bool {
+	if a.any {
+		return true
+	}
+	origin = normalize(origin)
+	if a.origins[origin] {
+		return true
+	}
+	if len(a.subdomains) == 0 {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range a.subdomains {
+		if u.Scheme == allowed.scheme && strings.HasSuffix(u.Host, allowed.suffix) && len(u.Host) > len(allowed.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRequest returns whether the request may be served. Requests without an Origin header aren't
+// sent by browsers and are allowed, as are requests from the API's own origin
+func (a *Allowlist) CheckRequest(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return a.Allows(origin)
+}
+
+func normalize(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
@@ -0,0 +1,85 @@
+package origin
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ParseRejectsInvalidOrigins(t *testing.T) {
+	invalid := []string{
+		"kaboo.example.com",
+		"ftp://kaboo.example.com",
+		"https://",
+		"https://kaboo.example.com/path",
+		"https://kaboo.example.com?query",
+		"https://user@kaboo.example.com",
+		"https://*",
+		"https://a.*.example.com",
+		"https://*.*.example.com",
+	}
+	for _, origin := range invalid {
+		if _, err := Parse([]string{origin}); !errors.Is(err, ErrInvalidOrigin) {
+			t.Errorf("Origin %q should be rejected, got %v", origin, err)
+		}
+	}
+}
+
+func Test_Allows(t *testing.T) {
+	allowlist, err := Parse([]string{"https://kaboo.example.com/", "http://localhost:3000", "https://*.kaboo.dev"})
+	if err != nil {
+		t.Fatalf("Failed parsing origins, %v", err)
+	}
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://kaboo.example.com", true},
+		{"HTTPS://Kaboo.Example.com", true},
+		{"http://localhost:3000", true},
+		{"https://beta.kaboo.dev", true},
+		{"https://a.b.kaboo.dev", true},
+		{"", false},
+		{"null", false},
+		{"http://kaboo.example.com", false},
+		{"https://kaboo.example.com:8443", false},
+		{"https://kaboo.example.com.evil.com", false},
+		{"http://localhost:3001", false},
+		{"https://kaboo.dev", false},
+		{"https://evilkaboo.dev", false},
+		{"http://beta.kaboo.dev", false},
+	}
+	for _, test := range tests {
+		if allowlist.Allows(test.origin) != test.allowed {
+			t.Errorf("Origin %q allowed should be %v", test.origin, test.allowed)
+		}
+	}
+	if any, _ := Parse([]string{Any}); !any.Allows("https://anywhere.example.com") {
+		t.Errorf("* should allow any origin")
+	}
+}
+
+func Test_CheckRequest(t *testing.T) {
+	allowlist, _ := Parse(nil)
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		// Non browser clients don't send an origin
+		{"", true},
+		{"https://api.kaboo.example.com", true},
+		{"https://API.kaboo.example.com", true},
+		{"https://kaboo.example.com", false},
+		{"https://evil.example.com", false},
+		{"null", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "https://api.kaboo.example.com/ws", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if allowlist.CheckRequest(r) != test.allowed {
+			t.Errorf("Request from %q allowed should be %v by default", test.origin, test.allowed)
+		}
+	}
+}
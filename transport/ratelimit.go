@@ -0,0 +1,140 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// ipRate requests per second an IP address may send over time, shared by every user behind it
+	ipRate = rate.Limit(20)
+	// ipBurst requests an IP address may send in a quick burst
+	ipBurst = 60
+	// userRate requests per second an authenticated user may send over time
+	userRate = rate.Limit(5)
+	// userBurst requests an authenticated user may send in a quick burst
+	userBurst = 20
+	// lobbyRate games a user may create or join per second over time
+	lobbyRate = rate.Limit(0.2)
+	// lobbyBurst games a user may create or join in a quick burst
+	lobbyBurst = 5
+	// limiterIdleTimeout how long an unused limiter is kept, idle limiters are full anyway
+	limiterIdleTimeout = 10 * time.Minute
+)
+
+// keyedLimiter a token bucket per key, e.g. per user or IP address
+type keyedLimiter struct {
+	limit     rate.Limit
+	burst     int
+	limiters  map[string]*idleLimiter
+	lastSweep time.Time
+	mtx       sync.Mutex
+}
+
+// idleLimiter a limiter and when it was last used
+type idleLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+func newKeyedLimiter(limit rate.Limit, burst int) *keyedLimiter {
+	return &keyedLimiter{
+		limit:     limit,
+		burst:     burst,
+		limiters:  make(map[string]*idleLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from the key's bucket, retryAfter is how long until one is available when it's empty
+func (l *keyedLimiter) allow(key string) (ok bool, retryAfter time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > limiterIdleTimeout {
+		for key, limiter := range l.limiters {
+			if now.Sub(limiter.lastSeen) > limiterIdleTimeout {
+				delete(l.limiters, key)
+			}
+		}
+		l.lastSweep = now
+	}
+	limiter := l.limiters[key]
+	if limiter == nil {
+		limiter = &idleLimiter{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = limiter
+	}
+	limiter.lastSeen = now
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// rateLimits the limits applied to incoming REST and websocket upgrade requests
+type rateLimits struct {
+	ip    *keyedLimiter
+	user  *keyedLimiter
+	lobby *keyedLimiter
+}
+
+func newRateLimits() *rateLimits {
+	return &rateLimits{
+		ip:    newKeyedLimiter(ipRate, ipBurst),
+		user:  newKeyedLimiter(userRate, userBurst),
+		lobby: newKeyedLimiter(lobbyRate, lobbyBurst),
+	}
+}
+
+// limitIP is a mux middleware limiting requests per client IP address. The address the request
+// came from is used, forwarding headers are ignored since clients can forge them
+func (l *rateLimits) limitIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if ok, retryAfter := l.ip.allow(ip); !ok {
+			writeRateLimited(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitUser limits every authenticated request per user
+func (l *rateLimits) limitUser(next func(w http.ResponseWriter, r *http.Request, user *models.User)) func(w http.ResponseWriter, r *http.Request, user *models.User) {
+	return limitByUser(l.user, next)
+}
+
+// limitLobby limits creating and joining games per user, on top of the limit of every request
+func (l *rateLimits) limitLobby(next func(w http.ResponseWriter, r *http.Request, user *models.User)) func(w http.ResponseWriter, r *http.Request, user *models.User) {
+	return limitByUser(l.lobby, next)
+}
+
+func limitByUser(limiter *keyedLimiter, next func(w http.ResponseWriter, r *http.Request, user *models.User)) func(w http.ResponseWriter, r *http.Request, user *models.User) {
+	return func(w http.ResponseWriter, r *http.Request, user *models.User) {
+		if user != nil {
+			if ok, retryAfter := limiter.allow(user.ID.Hex()); !ok {
+				writeRateLimited(w, retryAfter)
+				return
+			}
+		}
+		next(w, r, user)
+	}
+}
+
+// writeRateLimited responds with 429 telling the client when to retry
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	writeError(w, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests").
+		WithRetryAfter(retryAfter))
+}
@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/backend"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/time/rate"
+)
+
+func Test_KeyedLimiter(t *testing.T) {
+	limiter := newKeyedLimiter(rate.Limit(1), 3)
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.allow("a"); !ok {
+			t.Fatalf("Burst of 3 requests should be allowed")
+		}
+	}
+	ok, retryAfter := limiter.allow("a")
+	if ok || retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("Should have been limited for up to a second, got %v %v", ok, retryAfter)
+	}
+	if ok, _ := limiter.allow("b"); !ok {
+		t.Errorf("Keys should be limited separately")
+	}
+}
+
+func Test_RateLimitedRequests(t *testing.T) {
+	limits := newRateLimits()
+	handler := limits.limitIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/games", nil)
+	var w *httptest.ResponseRecorder
+	for i := 0; i <= ipBurst; i++ {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("IP should be limited after %d requests, got %v", ipBurst, w.Code)
+	}
+	if seconds, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || seconds < 1 {
+		t.Errorf("Retry-After should be set in whole seconds, got %q", w.Header().Get("Retry-After"))
+	}
+	other := httptest.NewRequest(http.MethodGet, "/api/v1/games", nil)
+	other.RemoteAddr = "10.0.0.1:1234"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, other)
+	if w.Code != http.StatusOK {
+		t.Errorf("Other IP addresses shouldn't be limited, got %v", w.Code)
+	}
+
+	user := &models.User{ID: primitive.NewObjectID()}
+	join := limits.limitLobby(func(w http.ResponseWriter, r *http.Request, user *models.User) {})
+	for i := 0; i <= lobbyBurst; i++ {
+		w = httptest.NewRecorder()
+		join(w, request, user)
+	}
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("User should be limited after joining %d games, got %v", lobbyBurst, w.Code)
+	}
+}
+
+func Test_PasswordLockoutRetryAfter(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeError(w, &backend.LockoutError{Wait: 1500 * time.Millisecond})
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Lockout should be reported as 429 retrying after 2 seconds, got %v %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if apiErr := toAPIError(backend.ErrWrongGamePassword); apiErr.RetryAfter != 0 || apiErr.Code != apierror.CodeWrongPassword {
+		t.Errorf("Wrong password shouldn't set Retry-After, got %+v", apiErr)
+	}
+}
@@ -2,10 +2,10 @@ package transport
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
-	"os"
+	"strconv"
 
 	"github.com/ngutman/kaboo-server-go/transport/websocket"
 
@@ -14,7 +14,11 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/ngutman/kaboo-server-go/backend"
+	"github.com/ngutman/kaboo-server-go/engine"
 	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+	"github.com/ngutman/kaboo-server-go/transport/origin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const (
@@ -27,53 +31,158 @@ type Server struct {
 	api            API
 	hub            *websocket.Hub
 	restPort       int
+	limits         *rateLimits
+	origins        *origin.Allowlist
 }
 
 // API wires incoming requests to their respective backend engines
 type API struct {
+	db             *models.Db
 	gameController *backend.GameController
+	matchmaker     *backend.Matchmaker
+	avatars        *backend.AvatarStore
+	hub            *websocket.Hub
 }
 
-// NewServer initializes a new kaboo server
-func NewServer(restPort int, auth0Domain string, auth0Audience string) Server {
+// NewServer initializes a new kaboo server, browsers on the allowed origins may use the REST API and websocket
+func NewServer(restPort int, auth0Domain string, auth0Audience string, allowedOrigins *origin.Allowlist, wsConfig websocket.Config) Server {
 	var db models.Db
 	db.Open("mongodb://localhost:27017/", "kaboo")
-	hub := websocket.NewHub()
+	hub := websocket.NewHub(wsConfig)
+	gameController := backend.NewGameController(&db, hub)
+	api := API{
+		db:             &db,
+		gameController: gameController,
+		matchmaker:     backend.NewMatchmaker(gameController, hub, backend.DefaultBotFillTimeout),
+		hub:            hub,
+	}
+	hub.RegisterCommandHandler(websocket.WSCommandTypeChat, api.handleChatCommand)
+	hub.RegisterCommandHandler(websocket.WSCommandTypeSync, api.handleSyncCommand)
+	hub.SetPresenceHandler(gameController.HandlePresenceChange)
+	hub.SetErrorTranslator(toAPIError)
+	hub.SetOriginChecker(allowedOrigins.CheckRequest)
+	gameController.SetPresenceTracker(hub)
 	go hub.Run()
+	go api.matchmaker.Run()
+	limits := newRateLimits()
 	return Server{
 		JWTAuthMiddleware{
 			&db,
 			auth0Domain,
 			auth0Audience,
+			limits,
 		},
-		API{
-			gameController: backend.NewGameController(&db, hub),
-		},
+		api,
 		hub,
 		restPort,
+		limits,
+		allowedOrigins,
 	}
 }
 
-// Start starts the server
-func (s *Server) Start() {
-	r := mux.NewRouter()
-	if os.Getenv("DEBUG") != "" {
-		r.Use(handlers.CORS(
-			handlers.AllowedOrigins([]string{"*"}),
-			handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
-			handlers.AllowCredentials(),
-		))
+// SetProfanityFilter sets the filter applied to chat messages
+func (s *Server) SetProfanityFilter(filter backend.ProfanityFilter) {
+	s.api.gameController.SetProfanityFilter(filter)
+}
+
+// SetAvatarStore sets where uploaded avatars are stored, uploads are disabled until it's set
+func (s *Server) SetAvatarStore(store *backend.AvatarStore) {
+	s.api.avatars = store
+}
+
+// Start serves the API until the server fails, config must be valid
+func (s *Server) Start(config HTTPConfig) error {
+	server, err := newHTTPServer(fmt.Sprintf(":%d", s.restPort), handlers.CombinedLoggingHandler(log.StandardLogger().Out, s.handler()), config)
+	if err != nil {
+		return err
 	}
+	if !config.TLS() {
+		log.Infof("Starting API server (:%v)\n", s.restPort)
+		return server.ListenAndServe()
+	}
+	if config.RedirectPort != 0 {
+		redirect, _ := newHTTPServer(fmt.Sprintf(":%d", config.RedirectPort), redirectToHTTPS(s.restPort), DefaultHTTPConfig())
+		log.Infof("Redirecting HTTP to HTTPS (:%v)\n", config.RedirectPort)
+		go func() {
+			if err := redirect.ListenAndServe(); err != nil {
+				log.Errorf("HTTPS redirect stopped, %v\n", err)
+			}
+		}()
+	}
+	log.Infof("Starting API server with TLS (:%v)\n", s.restPort)
+	return server.ListenAndServeTLS("", "")
+}
+
+// handler the router behind the CORS middleware, wrapping the router rather than using it as route
+// middleware lets preflight requests through to routes restricted to other methods
+func (s *Server) handler() http.Handler {
+	cors := handlers.CORS(
+		handlers.AllowedOriginValidator(s.origins.Allows),
+		handlers.AllowedMethods([]string{http.MethodGet, http.MethodPost, http.MethodPut}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
+		handlers.ExposedHeaders([]string{"Retry-After"}),
+	)(s.router())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses differ by origin, caches must not share them across origins
+		w.Header().Add("Vary", "Origin")
+		cors.ServeHTTP(w, r)
+	})
+}
+
+// router routes every API request to its handler, openapi.json documents each route
+func (s *Server) router() *mux.Router {
+	r := mux.NewRouter()
+	r.Use(s.limits.limitIP)
 	apiRouter := r.PathPrefix(fmt.Sprintf("/api/v%s", apiVersion)).Subrouter()
-	apiRouter.HandleFunc("/game/new", s.authMiddleware.Handle(s.api.handleNewGame))
-	apiRouter.HandleFunc("/game/join", s.authMiddleware.Handle(s.api.handleJoinGame))
+	apiRouter.HandleFunc("/games", s.authMiddleware.Handle(s.api.handleListGames)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/game/new", s.authMiddleware.Handle(s.limits.limitLobby(s.api.handleNewGame)))
+	apiRouter.HandleFunc("/game/join", s.authMiddleware.Handle(s.limits.limitLobby(s.api.handleJoinGame)))
 	apiRouter.HandleFunc("/game/leave", s.authMiddleware.Handle(s.api.handleLeaveGame))
+	apiRouter.HandleFunc("/game/spectate", s.authMiddleware.Handle(s.api.handleSpectateGame))
+	apiRouter.HandleFunc("/game/unspectate", s.authMiddleware.Handle(s.api.handleStopSpectating))
+	apiRouter.HandleFunc("/game/mute", s.authMiddleware.Handle(s.api.handleMuteUser))
+	apiRouter.HandleFunc("/game/{id}/chat", s.authMiddleware.Handle(s.api.handleChatHistory)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/game/settings", s.authMiddleware.Handle(s.api.handleLobbySettings))
+	apiRouter.HandleFunc("/game/kick", s.authMiddleware.Handle(s.api.handleKickPlayer))
+	apiRouter.HandleFunc("/game/transfer", s.authMiddleware.Handle(s.api.handleTransferOwnership))
+	apiRouter.HandleFunc("/game/bot", s.authMiddleware.Handle(s.api.handleAddBot))
+	apiRouter.HandleFunc("/game/start", s.authMiddleware.Handle(s.api.handleStartGame))
+	apiRouter.HandleFunc("/game/action", s.authMiddleware.Handle(s.api.handleGameAction))
+	apiRouter.HandleFunc("/game/{id}", s.authMiddleware.Handle(s.api.handleGameInfo)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/game/{id}/replay", s.authMiddleware.Handle(s.api.handleGameReplay)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/game/{id}/replay/{step:[0-9]+}", s.authMiddleware.Handle(s.api.handleReplayStep)).Methods(http.MethodGet)
+
+	apiRouter.HandleFunc("/matchmaking/join", s.authMiddleware.Handle(s.api.handleJoinMatchmaking))
+	apiRouter.HandleFunc("/matchmaking/leave", s.authMiddleware.Handle(s.api.handleLeaveMatchmaking))
+
+	apiRouter.HandleFunc("/users/me/profile", s.authMiddleware.Handle(s.api.handleMyProfile)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/users/me/profile", s.authMiddleware.Handle(s.api.handleUpdateProfile)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/users/me/avatar", s.authMiddleware.Handle(s.api.handleUploadAvatar)).Methods(http.MethodPost)
+	// Avatars are loaded by image tags which can't send the authorization header
+	apiRouter.HandleFunc("/avatars/{file}", s.api.handleAvatar).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/friends", s.authMiddleware.Handle(s.api.handleFriends)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/friends/request", s.authMiddleware.Handle(s.api.handleFriendRequest))
+	apiRouter.HandleFunc("/friends/respond", s.authMiddleware.Handle(s.api.handleFriendResponse))
+	apiRouter.HandleFunc("/friends/remove", s.authMiddleware.Handle(s.api.handleRemoveFriend))
+	apiRouter.HandleFunc("/friends/block", s.authMiddleware.Handle(s.api.handleBlockUser))
+	apiRouter.HandleFunc("/friends/unblock", s.authMiddleware.Handle(s.api.handleUnblockUser))
+	apiRouter.HandleFunc("/friends/invite", s.authMiddleware.Handle(s.api.handleInviteFriend))
+	apiRouter.HandleFunc("/invites/accept", s.authMiddleware.Handle(s.api.handleAcceptInvite))
+	apiRouter.HandleFunc("/invites/decline", s.authMiddleware.Handle(s.api.handleDeclineInvite))
+
+	apiRouter.HandleFunc("/leaderboard", s.authMiddleware.Handle(s.api.handleLeaderboard)).Methods(http.MethodGet)
 
-	apiRouter.HandleFunc("/state", s.authMiddleware.Handle(notImplemented))
+	apiRouter.HandleFunc("/users/me/history", s.authMiddleware.Handle(s.api.handleMyHistory)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/users/me/stats", s.authMiddleware.Handle(s.api.handleMyStats)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/users/{id}/profile", s.authMiddleware.Handle(s.api.handleUserProfile)).Methods(http.MethodGet)
+
+	apiRouter.HandleFunc("/state", s.authMiddleware.Handle(s.api.handleState))
 	apiRouter.HandleFunc("/ws", s.authMiddleware.Handle(s.hub.HandleWSUpgradeRequest))
+	apiRouter.HandleFunc("/ws/schema.json", s.api.handleProtocolSchema).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/users/me/connection", s.authMiddleware.Handle(s.api.handleConnectionStats)).Methods(http.MethodGet)
 
-	log.Infof("Starting API server (:%v)\n", s.restPort)
-	http.ListenAndServe(fmt.Sprintf(":%d", s.restPort), handlers.CombinedLoggingHandler(log.StandardLogger().Out, r))
+	apiRouter.HandleFunc("/openapi.json", s.api.handleOpenAPISpec).Methods(http.MethodGet)
+	return r
 }
 
 func (a *API) handleNewGame(w http.ResponseWriter, r *http.Request, user *models.User) {
@@ -81,12 +190,35 @@ func (a *API) handleNewGame(w http.ResponseWriter, r *http.Request, user *models
 	if tryToDecodeOrFail(w, r, &req) != nil {
 		return
 	}
-	gameID, err := a.gameController.NewGame(user, req.Name, req.MaxPlayersCount, req.Password)
+	options := backend.GameOptions{
+		Entropy:    req.Entropy,
+		Unrated:    req.Unrated,
+		Visibility: models.GameVisibility(req.Visibility),
+		Spectators: models.SpectatorSettings{
+			Disabled:      req.Spectators.Disabled,
+			MaxSpectators: req.Spectators.MaxSpectators,
+			DelaySeconds:  req.Spectators.DelaySeconds,
+		},
+	}
+	if req.Rules != nil {
+		options.Rules = &req.Rules.Ruleset
+	}
+	gameID, err := a.gameController.NewGame(user, req.Name, req.MaxPlayersCount, req.Password, options)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	a.writeCreatedGame(w, r, gameID)
+}
+
+// writeCreatedGame responds with the id and seed commitment of a game the user created or joined
+func (a *API) writeCreatedGame(w http.ResponseWriter, r *http.Request, gameID string) {
+	game, err := a.gameController.FetchGame(gameID)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
-	tryToWriteJSONResponse(w, r, &createGameRes{GameID: gameID})
+	tryToWriteJSONResponse(w, r, &createGameRes{GameID: gameID, SeedCommitment: game.SeedCommitment})
 }
 
 func (a *API) handleJoinGame(w http.ResponseWriter, r *http.Request, user *models.User) {
@@ -94,27 +226,698 @@ func (a *API) handleJoinGame(w http.ResponseWriter, r *http.Request, user *model
 	if tryToDecodeOrFail(w, r, &req) != nil {
 		return
 	}
-	success, err := a.gameController.JoinGameByGameID(user, req.GameID, req.Password)
+	success, err := a.gameController.JoinGameByGameID(user, req.GameID, req.Password, req.Entropy)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeError(w, err)
 		return
 	}
 	tryToWriteJSONResponse(w, r, &joinGameRes{Success: success})
 }
 
+// handleListGames serves the games waiting for players that the user may join
+func (a *API) handleListGames(w http.ResponseWriter, r *http.Request, user *models.User) {
+	games, err := a.gameController.ListGames(user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res := lobbyRes{Games: []lobbyGameRes{}}
+	for _, game := range games {
+		res.Games = append(res.Games, lobbyGameRes{
+			GameID:     game.ID.Hex(),
+			Name:       game.Name,
+			Owner:      game.Owner.Hex(),
+			Players:    game.PlayerCount(),
+			MaxPlayers: game.MaxPlayers,
+			Visibility: string(game.GameVisibility()),
+			Password:   game.Password != "",
+			Rules:      game.Ruleset(),
+		})
+	}
+	tryToWriteJSONResponse(w, r, &res)
+}
+
+func (a *API) handleLobbySettings(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req lobbySettingsReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	settings := backend.LobbySettings{Name: req.Name, MaxPlayers: req.MaxPlayers}
+	if req.Visibility != nil {
+		visibility := models.GameVisibility(*req.Visibility)
+		settings.Visibility = &visibility
+	}
+	if err := a.gameController.UpdateLobbySettings(user, req.GameID, settings); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleKickPlayer(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req kickPlayerReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.KickPlayer(user, req.GameID, req.UserID, req.Ban); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleTransferOwnership(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req transferOwnershipReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.TransferOwnership(user, req.GameID, req.UserID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleSpectateGame(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req spectateGameReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.SpectateGame(user, req.GameID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleStopSpectating(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req spectateGameReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.StopSpectating(user, req.GameID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleMuteUser(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req muteUserReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.MuteUser(user, req.GameID, req.UserID, req.Muted); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleChatHistory(w http.ResponseWriter, r *http.Request, user *models.User) {
+	messages, err := a.gameController.FetchChatHistory(user, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res := chatHistoryRes{Messages: []websocket.ChatMessage{}}
+	for i := range messages {
+		res.Messages = append(res.Messages, websocket.NewChatMessage(&messages[i]))
+	}
+	tryToWriteJSONResponse(w, r, &res)
+}
+
+// handleChatCommand sends a chat message received over websocket
+func (a *API) handleChatCommand(userID primitive.ObjectID, command websocket.WSCommand) error {
+	user, err := a.db.UserDAO.FetchUserByID(userID)
+	if err != nil {
+		return err
+	}
+	return a.gameController.SendChatMessage(user, command.GameID, command.Text)
+}
+
+// handleSyncCommand sends a snapshot of a running game to a player who missed a delta
+func (a *API) handleSyncCommand(userID primitive.ObjectID, command websocket.WSCommand) error {
+	return a.gameController.SyncGame(userID, command.GameID)
+}
+
+func (a *API) handleAddBot(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req addBotReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	botID, err := a.gameController.AddBot(user, req.GameID, req.Difficulty)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &addBotRes{BotID: botID})
+}
+
+func (a *API) handleStartGame(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req startGameReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.StartGame(user, req.GameID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleGameAction(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req gameActionReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.ApplyAction(user, req.GameID, req.Action); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+// handleGameInfo serves the public game details, the seed is only revealed once the game ended
+func (a *API) handleGameInfo(w http.ResponseWriter, r *http.Request, user *models.User) {
+	game, err := a.gameController.FetchVisibleGame(user, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res := gameInfoRes{
+		GameID:         game.ID.Hex(),
+		Name:           game.Name,
+		Owner:          game.Owner.Hex(),
+		State:          int(game.State),
+		MaxPlayers:     game.MaxPlayers,
+		Visibility:     string(game.GameVisibility()),
+		Rules:          game.Ruleset(),
+		Players:        []string{},
+		Bots:           []string{},
+		SeedCommitment: game.SeedCommitment,
+		Entropy:        game.EntropyValues(),
+		Seed:           game.RevealedSeed(),
+	}
+	for _, player := range game.Players {
+		res.Players = append(res.Players, player.Hex())
+	}
+	for _, bot := range game.Bots {
+		res.Bots = append(res.Bots, bot.Name)
+	}
+	tryToWriteJSONResponse(w, r, &res)
+}
+
+func (a *API) handleGameReplay(w http.ResponseWriter, r *http.Request, user *models.User) {
+	game, entries, err := a.gameController.FetchReplay(user, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res := gameReplayRes{
+		GameID:         game.ID.Hex(),
+		Seed:           game.RevealedSeed(),
+		SeedCommitment: game.SeedCommitment,
+		Entropy:        game.EntropyValues(),
+		ShuffleSeed:    game.ShuffleSeed(),
+		Actions:        models.LogActions(entries),
+		Log:            make([]replayEntryRes, len(entries)),
+	}
+	for _, seat := range game.Seats() {
+		res.Seats = append(res.Seats, seat.Hex())
+	}
+	for i, entry := range entries {
+		res.Log[i] = replayEntryRes{Seq: entry.Seq, Action: entry.Action, Events: entry.Events}
+	}
+	tryToWriteJSONResponse(w, r, &res)
+}
+
+// handleReplayStep serves the state after a step, ?perspective=<seat> limits it to what that seat knew
+func (a *API) handleReplayStep(w http.ResponseWriter, r *http.Request, user *models.User) {
+	vars := mux.Vars(r)
+	step, _ := strconv.Atoi(vars["step"])
+	perspective := engine.Omniscient
+	if value := r.URL.Query().Get("perspective"); value != "" && value != "omniscient" {
+		seat, err := strconv.Atoi(value)
+		if err != nil {
+			writeError(w, apierror.New(http.StatusBadRequest, apierror.CodeInvalidArgument, "Perspective must be a seat number or \"omniscient\""))
+			return
+		}
+		perspective = seat
+	}
+	replayStep, err := a.gameController.FetchReplayStep(user, vars["id"], step, perspective)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &replayStepRes{
+		GameID: vars["id"],
+		Step:   replayStep.Step,
+		Steps:  replayStep.Steps,
+		Action: replayStep.Action,
+		Events: replayStep.Events,
+		State:  replayStep.State,
+	})
+}
+
+func (a *API) handleJoinMatchmaking(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req joinMatchmakingReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.matchmaker.Enqueue(user, req.Players, req.Ruleset); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleLeaveMatchmaking(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if err := a.matchmaker.Dequeue(user); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleMyProfile(w http.ResponseWriter, r *http.Request, user *models.User) {
+	tryToWriteJSONResponse(w, r, newMyProfileRes(user))
+}
+
+// handleUpdateProfile updates the fields present in the request, every field is validated before any is saved
+func (a *API) handleUpdateProfile(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req updateProfileReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	var displayName string
+	var err error
+	if req.Username != nil {
+		err = models.ValidateUsername(*req.Username)
+	}
+	if err == nil && req.DisplayName != nil {
+		displayName, err = models.NormalizeDisplayName(*req.DisplayName)
+	}
+	if err == nil && req.Avatar != nil {
+		err = models.ValidateAvatar(*req.Avatar)
+	}
+	preferences := user.UserPreferences()
+	if req.Preferences != nil {
+		if req.Preferences.CardBack != nil {
+			preferences.CardBack = *req.Preferences.CardBack
+		}
+		if req.Preferences.Sound != nil {
+			preferences.Sound = *req.Preferences.Sound
+		}
+		if req.Preferences.Language != nil {
+			preferences.Language = *req.Preferences.Language
+		}
+		if err == nil {
+			err = preferences.Validate()
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if req.Username != nil && *req.Username != user.Username {
+		err = a.db.UserDAO.UpdateUsername(user, *req.Username)
+	}
+	if err == nil && req.DisplayName != nil {
+		err = a.db.UserDAO.UpdateDisplayName(user, displayName)
+	}
+	if err == nil && req.Avatar != nil {
+		err = a.db.UserDAO.UpdateAvatar(user, *req.Avatar)
+	}
+	if err == nil && req.Preferences != nil {
+		err = a.db.UserDAO.UpdatePreferences(user, preferences)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, newMyProfileRes(user))
+}
+
+// handleUploadAvatar stores the image sent as the request body and makes it the user's avatar
+func (a *API) handleUploadAvatar(w http.ResponseWriter, r *http.Request, user *models.User) {
+	if a.avatars == nil {
+		writeError(w, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Avatar uploads are disabled"))
+		return
+	}
+	image, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, backend.MaxAvatarSize+1))
+	if err != nil || len(image) > backend.MaxAvatarSize {
+		writeError(w, backend.ErrAvatarTooLarge)
+		return
+	}
+	fileName, err := a.avatars.Save(user.ID, image)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := a.db.UserDAO.SetUploadedAvatar(user, fileName); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, newMyProfileRes(user))
+}
+
+func (a *API) handleAvatar(w http.ResponseWriter, r *http.Request) {
+	if a.avatars == nil {
+		http.NotFound(w, r)
+		return
+	}
+	path, err := a.avatars.Path(mux.Vars(r)["file"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, path)
+}
+
+// handleState serves the user's profile, preferences and active game along with the available profile options
+func (a *API) handleState(w http.ResponseWriter, r *http.Request, user *models.User) {
+	res := stateRes{
+		User:      *newMyProfileRes(user),
+		Avatars:   models.Avatars,
+		CardBacks: models.CardBacks,
+		Languages: models.Languages,
+	}
+	game, err := a.db.GamesDAO.FetchActiveGameOfPlayer(user.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if game != nil {
+		res.ActiveGameID = game.ID.Hex()
+	}
+	tryToWriteJSONResponse(w, r, &res)
+}
+
+func (a *API) handleUserProfile(w http.ResponseWriter, r *http.Request, user *models.User) {
+	userID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, backend.ErrUserNotFound)
+		return
+	}
+	profile, err := a.db.UserDAO.FetchUserByID(userID)
+	if err != nil {
+		writeError(w, backend.ErrUserNotFound)
+		return
+	}
+	tryToWriteJSONResponse(w, r, newProfileRes(profile))
+}
+
+// handleMyHistory serves a page of the user's ended games, ?offset=&limit= select the page
+func (a *API) handleMyHistory(w http.ResponseWriter, r *http.Request, user *models.User) {
+	offset, limit := 0, 20
+	query := r.URL.Query()
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, apierror.New(http.StatusBadRequest, apierror.CodeInvalidArgument, "Offset must be a non negative number"))
+			return
+		}
+		offset = parsed
+	}
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > models.MaxHistoryPageSize {
+			writeError(w, apierror.New(http.StatusBadRequest, apierror.CodeInvalidArgument,
+				fmt.Sprintf("Limit must be between 1 and %d", models.MaxHistoryPageSize)))
+			return
+		}
+		limit = parsed
+	}
+	games, total, err := a.db.GamesDAO.FetchPlayerHistory(user.ID, offset, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res := historyRes{Games: []historyEntryRes{}, Total: total, Offset: offset, Limit: limit}
+	for _, game := range games {
+		result := game.ResultFor(user.ID)
+		if result == nil {
+			continue
+		}
+		res.Games = append(res.Games, historyEntryRes{
+			GameID:    game.ID.Hex(),
+			Name:      game.Name,
+			EndedAt:   game.EndedAt,
+			Players:   len(game.Results),
+			Placement: result.Placement,
+			Score:     result.Score,
+			Won:       result.Won,
+		})
+	}
+	tryToWriteJSONResponse(w, r, &res)
+}
+
+func (a *API) handleMyStats(w http.ResponseWriter, r *http.Request, user *models.User) {
+	stats, err := a.db.GamesDAO.AggregatePlayerStats(user.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &statsRes{
+		GamesPlayed:      stats.GamesPlayed,
+		Wins:             stats.Wins,
+		WinRate:          stats.WinRate(),
+		AverageScore:     stats.AverageScore,
+		KabooCalls:       stats.KabooCalls,
+		KabooSuccesses:   stats.KabooSuccesses,
+		KabooSuccessRate: stats.KabooSuccessRate(),
+		BestRound:        stats.BestRound,
+	})
+}
+
+// handleLeaderboard serves a precomputed leaderboard, ?window=weekly|monthly|all_time,
+// ?metric=rating|wins|average_score, ?minGames= and ?limit= are optional
+func (a *API) handleLeaderboard(w http.ResponseWriter, r *http.Request, user *models.User) {
+	query := r.URL.Query()
+	window, metric := models.LeaderboardAllTime, models.LeaderboardByRating
+	if value := query.Get("window"); value != "" {
+		window = models.LeaderboardWindow(value)
+	}
+	if value := query.Get("metric"); value != "" {
+		metric = models.LeaderboardMetric(value)
+	}
+	minGames, limit := 1, 50
+	if value := query.Get("minGames"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			writeError(w, apierror.New(http.StatusBadRequest, apierror.CodeInvalidArgument, "minGames must be a positive number"))
+			return
+		}
+		minGames = parsed
+	}
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > models.MaxLeaderboardSize {
+			writeError(w, apierror.New(http.StatusBadRequest, apierror.CodeInvalidArgument,
+				fmt.Sprintf("Limit must be between 1 and %d", models.MaxLeaderboardSize)))
+			return
+		}
+		limit = parsed
+	}
+	entries, err := a.db.LeaderboardDAO.FetchLeaderboard(window, metric, minGames, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res := leaderboardRes{Window: string(window), Metric: string(metric), MinGames: minGames, Entries: []leaderboardEntryRes{}}
+	for i, entry := range entries {
+		res.Entries = append(res.Entries, leaderboardEntryRes{
+			Rank:         i + 1,
+			UserID:       entry.UserID.Hex(),
+			Username:     entry.Username,
+			Rating:       entry.Rating,
+			Games:        entry.Games,
+			Wins:         entry.Wins,
+			AverageScore: entry.AverageScore,
+			UpdatedAt:    entry.UpdatedAt,
+		})
+	}
+	tryToWriteJSONResponse(w, r, &res)
+}
+
+// newMyProfileRes the user's own profile, which includes his preferences
+func newMyProfileRes(user *models.User) *profileRes {
+	res := newProfileRes(user)
+	preferences := websocket.NewPreferences(user.UserPreferences())
+	res.Preferences = &preferences
+	return res
+}
+
+func (a *API) handleFriends(w http.ResponseWriter, r *http.Request, user *models.User) {
+	friends, err := a.gameController.FetchFriends(user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res := friendsRes{Friends: []friendRes{}}
+	for _, friend := range friends {
+		res.Friends = append(res.Friends, friendRes{
+			User:     websocket.NewUser(friend.User),
+			Status:   string(friend.Status),
+			Incoming: friend.Incoming,
+			Online:   friend.Online,
+		})
+	}
+	tryToWriteJSONResponse(w, r, &res)
+}
+
+func (a *API) handleFriendRequest(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req friendReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	status, err := a.gameController.SendFriendRequest(user, req.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &friendRequestRes{Status: string(status)})
+}
+
+func (a *API) handleFriendResponse(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req friendResponseReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.RespondToFriendRequest(user, req.UserID, req.Accept); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleRemoveFriend(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req friendReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.RemoveFriend(user, req.UserID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleBlockUser(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req friendReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.BlockUser(user, req.UserID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleUnblockUser(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req friendReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.UnblockUser(user, req.UserID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func (a *API) handleInviteFriend(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req friendReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	inviteID, err := a.gameController.InviteFriend(user, req.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &inviteRes{InviteID: inviteID})
+}
+
+func (a *API) handleAcceptInvite(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req inviteReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	gameID, err := a.gameController.AcceptGameInvite(user, req.InviteID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	a.writeCreatedGame(w, r, gameID)
+}
+
+func (a *API) handleDeclineInvite(w http.ResponseWriter, r *http.Request, user *models.User) {
+	var req inviteReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.DeclineGameInvite(user, req.InviteID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
+}
+
+func newProfileRes(user *models.User) *profileRes {
+	res := &profileRes{
+		UserID:        user.ID.Hex(),
+		Username:      user.Username,
+		DisplayName:   user.Name(),
+		Avatar:        user.UserAvatar(),
+		Rating:        user.CurrentRating(),
+		RatedGames:    user.RatedGames,
+		RatingHistory: []ratingChangeRes{},
+	}
+	if fileName := user.UploadedAvatar(); fileName != "" {
+		res.AvatarURL = fmt.Sprintf("/api/v%s/avatars/%s", apiVersion, fileName)
+	}
+	for _, change := range user.RatingHistory {
+		res.RatingHistory = append(res.RatingHistory, ratingChangeRes{
+			GameID: change.GameID.Hex(),
+			Before: change.Before,
+			After:  change.After,
+			At:     change.At,
+		})
+	}
+	return res
+}
+
+// handleConnectionStats returns the bytes exchanged over the user's current websocket connection,
+// the totals of every connection are also logged when it closes
+func (a *API) handleConnectionStats(w http.ResponseWriter, r *http.Request, user *models.User) {
+	stats, ok := a.hub.ConnectionStats(user.ID)
+	if !ok {
+		writeError(w, apierror.New(http.StatusNotFound, apierror.CodeNotConnected, "User isn't connected"))
+		return
+	}
+	tryToWriteJSONResponse(w, r, &stats)
+}
+
 func (a *API) handleLeaveGame(w http.ResponseWriter, r *http.Request, user *models.User) {
-	notImplemented(w, r, user)
+	var req leaveGameReq
+	if tryToDecodeOrFail(w, r, &req) != nil {
+		return
+	}
+	if err := a.gameController.LeaveGame(user, req.GameID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tryToWriteJSONResponse(w, r, &successRes{Success: true})
 }
 
 func tryToDecodeOrFail(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 	if err := decodeJSONBody(w, r, dst); err != nil {
-		var mr *malformedRequest
-		if errors.As(err, &mr) {
-			http.Error(w, mr.msg, mr.status)
-		} else {
-			log.Println(err.Error())
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		}
+		writeError(w, err)
 		return err
 	}
 	return nil
@@ -123,13 +926,9 @@ func tryToDecodeOrFail(w http.ResponseWriter, r *http.Request, dst interface{})
 func tryToWriteJSONResponse(w http.ResponseWriter, r *http.Request, res interface{}) error {
 	jsonRes, err := json.Marshal(res)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return err
 	}
 	w.Write([]byte(jsonRes))
 	return nil
 }
-
-func notImplemented(w http.ResponseWriter, r *http.Request, user *models.User) {
-	http.Error(w, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
-}
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+	"github.com/ngutman/kaboo-server-go/transport/origin"
+	"github.com/ngutman/kaboo-server-go/transport/websocket"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testServer a server routing requests without a database, enough for routes that don't authenticate
+func testServer(t *testing.T, allowedOrigins ...string) *Server {
+	allowlist, err := origin.Parse(allowedOrigins)
+	if err != nil {
+		t.Fatalf("Failed parsing origins, %v", err)
+	}
+	limits := newRateLimits()
+	return &Server{authMiddleware: JWTAuthMiddleware{limits: limits}, limits: limits, origins: allowlist}
+}
+
+func Test_CORS(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		origin  string
+		method  string
+		// expected the Access-Control-Allow-Origin header, empty when the origin is rejected
+		expected string
+	}{
+		// Production default, no cross origin access
+		{nil, "https://evil.example.com", http.MethodGet, ""},
+		{nil, "https://evil.example.com", http.MethodPost, ""},
+		{[]string{"https://kaboo.example.com"}, "https://kaboo.example.com", http.MethodGet, "https://kaboo.example.com"},
+		{[]string{"https://kaboo.example.com"}, "https://kaboo.example.com", http.MethodPut, "https://kaboo.example.com"},
+		{[]string{"https://kaboo.example.com"}, "http://kaboo.example.com", http.MethodGet, ""},
+		{[]string{"https://kaboo.example.com"}, "https://kaboo.example.com.evil.com", http.MethodGet, ""},
+		{[]string{"https://*.example.com"}, "https://beta.example.com", http.MethodPost, "https://beta.example.com"},
+		{[]string{origin.Any}, "https://anywhere.example.com", http.MethodGet, "https://anywhere.example.com"},
+	}
+	for _, test := range tests {
+		handler := testServer(t, test.allowed...).handler()
+
+		// Preflight requests are answered for routes restricted to other methods as well
+		preflight := httptest.NewRequest(http.MethodOptions, "/api/v1/openapi.json", nil)
+		preflight.Header.Set("Origin", test.origin)
+		preflight.Header.Set("Access-Control-Request-Method", test.method)
+		preflight.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, preflight)
+		if actual := w.Header().Get("Access-Control-Allow-Origin"); actual != test.expected {
+			t.Errorf("Origins %v, preflight %v from %v should allow %q, got %q", test.allowed, test.method, test.origin, test.expected, actual)
+		}
+		if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Errorf("Credentials aren't needed with bearer tokens and must not be allowed")
+		}
+
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+		request.Header.Set("Origin", test.origin)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		if actual := w.Header().Get("Access-Control-Allow-Origin"); actual != test.expected {
+			t.Errorf("Origins %v, request from %v should allow %q, got %q", test.allowed, test.origin, test.expected, actual)
+		}
+		if test.expected != "" && w.Header().Get("Access-Control-Expose-Headers") != "Retry-After" {
+			t.Errorf("Retry-After should be exposed to allowed origins, got %q", w.Header().Get("Access-Control-Expose-Headers"))
+		}
+		if w.Header().Get("Vary") != "Origin" {
+			t.Errorf("Responses should vary by origin")
+		}
+	}
+}
+
+func Test_ConnectionStatsOfDisconnectedUser(t *testing.T) {
+	api := &API{hub: websocket.NewHub(websocket.DefaultConfig())}
+	w := httptest.NewRecorder()
+	api.handleConnectionStats(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/me/connection", nil), &models.User{ID: primitive.NewObjectID()})
+	var res apierror.Error
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil || w.Code != http.StatusNotFound || res.Code != apierror.CodeNotConnected {
+		t.Errorf("Disconnected users should get %v, got %v %v", apierror.CodeNotConnected, w.Code, res.Code)
+	}
+}
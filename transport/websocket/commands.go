@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CommandType name of a client command type, sent as the command "type" field
+type CommandType string
+
+// Client command types, protocol version 1 clients send the integers in legacyCommandTypes
+const (
+	WSCommandTypeChat CommandType = "chat"
+	// WSCommandTypeSync requests a game snapshot, sent by clients missing a delta version
+	WSCommandTypeSync CommandType = "sync"
+)
+
+const (
+	// MaxChatTextLength longest chat text, leaves room for the command envelope within maxMessageSize
+	MaxChatTextLength = maxMessageSize - 128
+)
+
+// WSCommand a command sent by a client
+type WSCommand struct {
+	CommandType CommandType `json:"type"`
+	GameID      string      `json:"gameid"`
+	Text        string      `json:"text"`
+}
+
+// CommandHandler handles a command sent by the given user, a returned error is sent back to the client
+type CommandHandler func(userID primitive.ObjectID, command WSCommand) error
+
+// ErrorTranslator returns the error reported to the client for a command handler error
+type ErrorTranslator func(err error) *apierror.Error
+
+// RegisterCommandHandler routes incoming commands of the given type to handler, must be called before Run
+func (h *Hub) RegisterCommandHandler(commandType CommandType, handler CommandHandler) {
+	h.handlers[commandType] = handler
+}
+
+func (h *Hub) dispatch(clientMessage ClientMessage) {
+	command, err := decodeCommand(clientMessage.data, clientMessage.client.protocol, clientMessage.binary)
+	if err != nil {
+		log.Debugf("Malformed command from %v, %v\n", clientMessage.client.userID, err)
+		clientMessage.client.reply(NewWSMessageError("",
+			apierror.New(http.StatusBadRequest, apierror.CodeMalformedRequest, "Malformed command")))
+		return
+	}
+	handler := h.handlers[command.CommandType]
+	if handler == nil {
+		clientMessage.client.reply(NewWSMessageError(command.CommandType,
+			apierror.New(http.StatusBadRequest, apierror.CodeUnknownCommand, "Unknown command")))
+		return
+	}
+	userID, _ := primitive.ObjectIDFromHex(clientMessage.client.userID)
+	go func() {
+		if err := handler(userID, command); err != nil {
+			clientMessage.client.reply(NewWSMessageError(command.CommandType, h.toAPIError(err)))
+		}
+	}()
+}
+
+// toAPIError translates a command handler error, errors are reported as internal errors when no translator is set
+func (h *Hub) toAPIError(err error) *apierror.Error {
+	if h.translateError != nil {
+		return h.translateError(err)
+	}
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	log.Errorf("Unexpected command error, %v\n", err)
+	return apierror.Internal()
+}
+
+// reply sends a message to this client alone
+func (c *client) reply(message interface{}) {
+	encoded, err := encodeMessage(message)
+	if err != nil {
+		log.Errorf("Failed encoding message %v, %v", message, err)
+		return
+	}
+	data, ok := encoded.forClient(c.protocol, c.encoding)
+	if !ok {
+		return
+	}
+	if !c.trySend(data) {
+		log.Debugf("Dropped reply to %v, send buffer is full or the client disconnected", c.userID)
+	}
+}
+
+// trySend queues data without blocking, it returns false if the send buffer is full or the client
+// was unregistered. Handlers may still reply after their client disconnected
+func (c *client) trySend(data []byte) bool {
+	c.sendMtx.Lock()
+	defer c.sendMtx.Unlock()
+	if c.closed {
+		return false
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes send once no other goroutine is sending on it, closing it again does nothing
+func (c *client) close() {
+	c.sendMtx.Lock()
+	defer c.sendMtx.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
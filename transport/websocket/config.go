@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultMaxBatchSize most JSON messages written in a single frame by default
+	DefaultMaxBatchSize = 32
+	// DefaultFlushInterval JSON messages are written as soon as they're queued by default
+	DefaultFlushInterval = 0
+	// DefaultCommandRate commands per second a client may send over time by default
+	DefaultCommandRate = rate.Limit(5)
+	// DefaultCommandBurst commands a client may send in a quick burst by default
+	DefaultCommandBurst = 10
+	// maxFlushInterval longest a queued message may wait to be batched with later ones
+	maxFlushInterval = time.Second
+)
+
+var (
+	// ErrInvalidConfig the websocket settings are out of range
+	ErrInvalidConfig = errors.New("Invalid websocket settings")
+)
+
+// Config websocket connection settings
+type Config struct {
+	// Compression negotiates permessage-deflate with clients supporting it
+	Compression bool
+	// CompressionLevel flate level of compressed messages, from 1 (fastest) to 9 (smallest)
+	CompressionLevel int
+	// MaxBatchSize most queued JSON messages written newline separated in a single text frame,
+	// 1 writes every message in its own frame. Binary encodings are never batched
+	MaxBatchSize int
+	// FlushInterval how long the first queued JSON message waits for later ones to share its
+	// frame, 0 only batches messages already queued when it's written
+	FlushInterval time.Duration
+	// CommandRate commands per second a client may send over time, faster commands are dropped
+	CommandRate rate.Limit
+	// CommandBurst commands a client may send in a quick burst
+	CommandBurst int
+}
+
+// DefaultConfig compression enabled, messages already queued are batched without waiting for more
+func DefaultConfig() Config {
+	return Config{
+		Compression:      true,
+		CompressionLevel: flate.BestSpeed,
+		MaxBatchSize:     DefaultMaxBatchSize,
+		FlushInterval:    DefaultFlushInterval,
+		CommandRate:      DefaultCommandRate,
+		CommandBurst:     DefaultCommandBurst,
+	}
+}
+
+// Validate returns an error wrapping ErrInvalidConfig if a setting is out of range
+func (c Config) Validate() error {
+	if c.Compression && (c.CompressionLevel < flate.BestSpeed || c.CompressionLevel > flate.BestCompression) {
+		return fmt.Errorf("%w, compression level must be %d-%d", ErrInvalidConfig, flate.BestSpeed, flate.BestCompression)
+	}
+	if c.MaxBatchSize < 1 {
+		return fmt.Errorf("%w, max batch size must be at least 1", ErrInvalidConfig)
+	}
+	if c.FlushInterval < 0 || c.FlushInterval > maxFlushInterval {
+		return fmt.Errorf("%w, flush interval must be 0-%v", ErrInvalidConfig, maxFlushInterval)
+	}
+	if c.CommandRate <= 0 || c.CommandBurst < 1 {
+		return fmt.Errorf("%w, command rate must be positive and command burst at least 1", ErrInvalidConfig)
+	}
+	return nil
+}
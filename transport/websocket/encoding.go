@@ -0,0 +1,52 @@
+package websocket
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// Encoding how messages are serialized on a connection
+type Encoding string
+
+// Message encodings
+const (
+	// EncodingJSON messages are sent as JSON text frames, the default
+	EncodingJSON Encoding = "json"
+	// EncodingMsgPack messages are sent as MessagePack binary frames, one message per frame.
+	// Field names are the JSON ones, it requires protocol version 2
+	EncodingMsgPack Encoding = "msgpack"
+
+	// EncodingQueryParam the /ws query parameter selecting the encoding, e.g. ?protocol=2&encoding=msgpack
+	EncodingQueryParam = "encoding"
+	// EncodingHeader the upgrade response header carrying the negotiated encoding
+	EncodingHeader = "Kaboo-Protocol-Encoding"
+)
+
+// binary returns whether messages of the encoding are sent as binary frames
+func (e Encoding) binary() bool {
+	return e == EncodingMsgPack
+}
+
+// supported returns whether clients speaking version may use the encoding
+func (e Encoding) supported(version int) bool {
+	switch e {
+	case EncodingJSON:
+		return true
+	case EncodingMsgPack:
+		return version >= ProtocolV2
+	}
+	return false
+}
+
+// marshalMsgPack encodes v as MessagePack using the JSON field names
+func marshalMsgPack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := msgpack.NewEncoder(&buf).UseJSONTag(true).UseCompactEncoding(true).Encode(v)
+	return buf.Bytes(), err
+}
+
+// unmarshalMsgPack decodes MessagePack encoded by clients using the JSON field names
+func unmarshalMsgPack(data []byte, v interface{}) error {
+	return msgpack.NewDecoder(bytes.NewReader(data)).UseJSONTag(true).Decode(v)
+}
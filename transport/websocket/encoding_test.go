@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ngutman/kaboo-server-go/engine"
+)
+
+// gameEventsMessage the events and view of a single turn, the most frequent message
+func gameEventsMessage(t testing.TB) WSMessageGameEvents {
+	g, _, err := engine.NewGame("seed", 4, engine.DefaultRuleset())
+	if err != nil {
+		t.Fatalf("Failed creating game, %v", err)
+	}
+	var events []engine.Event
+	for len(events) < 4 && !g.IsOver() {
+		seat := g.CurrentSeat()
+		turn, err := g.Apply(g.LegalActions(seat)[0])
+		if err != nil {
+			t.Fatalf("Failed applying action, %v", err)
+		}
+		events = append(events, turn...)
+	}
+	for i := range events {
+		events[i] = events[i].Masked(0)
+	}
+	return WSMessageGameEvents{
+		MessageType: WSMessageTypeGameEvents,
+		GameID:      "5e8f8f8f8f8f8f8f8f8f8f8f",
+		Events:      events,
+		View:        g.View(0),
+	}
+}
+
+func Test_MsgPackEncoding(t *testing.T) {
+	message := gameEventsMessage(t)
+	encoded, err := encodeMessage(message)
+	if err != nil {
+		t.Fatalf("Failed encoding, %v", err)
+	}
+	data, ok := encoded.forClient(ProtocolV2, EncodingMsgPack)
+	if !ok {
+		t.Fatalf("Message should be sent")
+	}
+	var decoded WSMessageGameEvents
+	if err := unmarshalMsgPack(data, &decoded); err != nil {
+		t.Fatalf("Failed decoding, %v", err)
+	}
+	expected, _ := json.Marshal(message)
+	actual, _ := json.Marshal(decoded)
+	if string(expected) != string(actual) {
+		t.Errorf("MessagePack round trip changed the message\n%s\n%s", expected, actual)
+	}
+
+	var fields map[string]interface{}
+	unmarshalMsgPack(data, &fields)
+	if fields["type"] != string(WSMessageTypeGameEvents) || fields["gameid"] == nil {
+		t.Errorf("MessagePack should use the JSON field names, got %v", fields)
+	}
+	if view := fields["view"].(map[string]interface{}); view["discardTop"].(map[string]interface{})["ID"] != nil {
+		t.Errorf("Card ids must not be sent")
+	}
+}
+
+func Test_DecodeMsgPackCommand(t *testing.T) {
+	data, err := marshalMsgPack(map[string]string{"type": "chat", "gameid": "g", "text": "hi"})
+	if err != nil {
+		t.Fatalf("Failed encoding, %v", err)
+	}
+	command, err := decodeCommand(data, ProtocolV2, true)
+	if err != nil || command != (WSCommand{WSCommandTypeChat, "g", "hi"}) {
+		t.Errorf("Unexpected command %+v, %v", command, err)
+	}
+}
+
+// BenchmarkEncodeGameEvents measures the JSON and MessagePack encoders alone on a game events message
+func BenchmarkEncodeGameEvents(b *testing.B) {
+	message := gameEventsMessage(b)
+	encoders := []struct {
+		encoding Encoding
+		marshal  func(v interface{}) ([]byte, error)
+	}{
+		{EncodingJSON, json.Marshal},
+		{EncodingMsgPack, marshalMsgPack},
+	}
+	for _, encoder := range encoders {
+		b.Run(string(encoder.encoding), func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				data, err := encoder.marshal(message)
+				if err != nil {
+					b.Fatalf("Failed encoding, %v", err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/msg")
+		})
+	}
+}
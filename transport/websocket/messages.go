@@ -1,22 +1,279 @@
 package websocket
 
-import "github.com/ngutman/kaboo-server-go/models"
+import (
+	"time"
 
+	"github.com/ngutman/kaboo-server-go/engine"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+)
+
+// MessageType name of a server message type, sent as the message "type" field
+type MessageType string
+
+// Message a server message, Type returns the type sent as its "type" field
+type Message interface {
+	Type() MessageType
+}
+
+// Server message types, register new types in messageTypes along with the protocol version introducing them
+const (
+	WSMessageTypeUserJoinsGame    MessageType = "user_joined_game"
+	WSMessageTypeGameEvents       MessageType = "game_events"
+	WSMessageTypeGameSeedRevealed MessageType = "game_seed_revealed"
+	WSMessageTypeChat             MessageType = "chat"
+	WSMessageTypeError            MessageType = "error"
+	WSMessageTypeMatchFound       MessageType = "match_found"
+	WSMessageTypeFriendRequest    MessageType = "friend_request"
+	WSMessageTypeFriendAccepted   MessageType = "friend_accepted"
+	WSMessageTypeFriendPresence   MessageType = "friend_presence"
+	WSMessageTypeGameInvite       MessageType = "game_invite"
+	WSMessageTypeLobbyUpdate      MessageType = "lobby_update"
+	WSMessageTypeGameDelta        MessageType = "game_delta"
+	WSMessageTypeGameSnapshot     MessageType = "game_snapshot"
+	WSMessageTypeMatchFailed      MessageType = "match_failed"
+)
+
+// Lobby update kinds
 const (
-	WSMessageTypeUserJoinsGame = iota
+	LobbyUpdatePlayerKicked    = "player_kicked"
+	LobbyUpdatePlayerBanned    = "player_banned"
+	LobbyUpdateOwnerChanged    = "owner_changed"
+	LobbyUpdateSettingsChanged = "settings_changed"
+	// LobbyUpdatePlayerLeft a player left the game, a running game is abandoned and ends
+	LobbyUpdatePlayerLeft = "player_left"
 )
 
 // User websocket user struct
 type User struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Avatar      string       `json:"avatar,omitempty"`
+	Preferences *Preferences `json:"preferences,omitempty"`
+}
+
+// Preferences user client settings
+type Preferences struct {
+	CardBack string `json:"cardBack"`
+	Sound    bool   `json:"sound"`
+	Language string `json:"language"`
+}
+
+// NewUser converts a user along with his profile and preferences
+func NewUser(user *models.User) User {
+	preferences := NewPreferences(user.UserPreferences())
+	return User{
+		ID:          user.ID.Hex(),
+		Name:        user.Username,
+		DisplayName: user.Name(),
+		Avatar:      user.UserAvatar(),
+		Preferences: &preferences,
+	}
+}
+
+// NewPreferences converts user preferences
+func NewPreferences(preferences models.Preferences) Preferences {
+	return Preferences{
+		CardBack: preferences.CardBack,
+		Sound:    preferences.Sound,
+		Language: preferences.Language,
+	}
 }
 
 // WSMessageUserJoinedGame user joined a game message
 type WSMessageUserJoinedGame struct {
-	MessageType int    `json:"type"`
-	GameID      string `json:"gameid"`
-	User        User   `json:"user"`
+	MessageType MessageType `json:"type"`
+	GameID      string      `json:"gameid"`
+	User        User        `json:"user"`
+}
+
+// Type returns the message type
+func (m WSMessageUserJoinedGame) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageGameEvents game events as seen by the receiving player, along with his resulting view
+type WSMessageGameEvents struct {
+	MessageType MessageType       `json:"type"`
+	GameID      string            `json:"gameid"`
+	Events      []engine.Event    `json:"events"`
+	View        engine.PlayerView `json:"view"`
+}
+
+// Type returns the message type
+func (m WSMessageGameEvents) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageGameDelta game events as seen by the receiving player along with the changes to his view.
+// Versions are consecutive, a client missing one must request a snapshot
+type WSMessageGameDelta struct {
+	MessageType MessageType      `json:"type"`
+	GameID      string           `json:"gameid"`
+	Version     int              `json:"version"`
+	Events      []engine.Event   `json:"events"`
+	Changes     engine.ViewDelta `json:"changes"`
+}
+
+// Type returns the message type
+func (m WSMessageGameDelta) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageGameSnapshot the whole game as known by the receiving player, deltas continue from its version
+type WSMessageGameSnapshot struct {
+	MessageType MessageType     `json:"type"`
+	GameID      string          `json:"gameid"`
+	Version     int             `json:"version"`
+	State       engine.Snapshot `json:"state"`
+}
+
+// Type returns the message type
+func (m WSMessageGameSnapshot) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageGameSeedRevealed reveals the game seed once the game ended, allowing players to
+// verify it against the commitment and recompute the deal
+type WSMessageGameSeedRevealed struct {
+	MessageType    MessageType `json:"type"`
+	GameID         string      `json:"gameid"`
+	Seed           string      `json:"seed"`
+	SeedCommitment string      `json:"seedCommitment"`
+	Entropy        []string    `json:"entropy"`
+	ShuffleSeed    string      `json:"shuffleSeed"`
+}
+
+// Type returns the message type
+func (m WSMessageGameSeedRevealed) Type() MessageType {
+	return m.MessageType
+}
+
+// ChatMessage a chat message as sent to clients
+type ChatMessage struct {
+	ID     string    `json:"id"`
+	User   User      `json:"user"`
+	Text   string    `json:"text"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+// WSMessageChat a chat message sent in a game
+type WSMessageChat struct {
+	MessageType MessageType `json:"type"`
+	GameID      string      `json:"gameid"`
+	Message     ChatMessage `json:"message"`
+}
+
+// Type returns the message type
+func (m WSMessageChat) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageError a command sent by the client failed, code and details are the ones used by the REST API
+type WSMessageError struct {
+	MessageType MessageType   `json:"type"`
+	CommandType CommandType   `json:"command,omitempty"`
+	Code        apierror.Code `json:"code"`
+	Message     string        `json:"message"`
+	Details     interface{}   `json:"details,omitempty"`
+}
+
+// Type returns the message type
+func (m WSMessageError) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageMatchFound the matchmaker seated the user in a game
+type WSMessageMatchFound struct {
+	MessageType MessageType `json:"type"`
+	GameID      string      `json:"gameid"`
+	Bots        int         `json:"bots"`
+}
+
+// Type returns the message type
+func (m WSMessageMatchFound) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageMatchFailed the game of a match couldn't be set up, requeued users keep their place in the queue
+type WSMessageMatchFailed struct {
+	MessageType MessageType `json:"type"`
+	Requeued    bool        `json:"requeued"`
+}
+
+// Type returns the message type
+func (m WSMessageMatchFailed) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageFriendRequest the user received a friend request
+type WSMessageFriendRequest struct {
+	MessageType MessageType `json:"type"`
+	From        User        `json:"from"`
+}
+
+// Type returns the message type
+func (m WSMessageFriendRequest) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageFriendAccepted a friend request the user sent was accepted
+type WSMessageFriendAccepted struct {
+	MessageType MessageType `json:"type"`
+	User        User        `json:"user"`
+}
+
+// Type returns the message type
+func (m WSMessageFriendAccepted) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageFriendPresence a friend connected or disconnected
+type WSMessageFriendPresence struct {
+	MessageType MessageType `json:"type"`
+	UserID      string      `json:"userId"`
+	Online      bool        `json:"online"`
+}
+
+// Type returns the message type
+func (m WSMessageFriendPresence) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageGameInvite a friend invited the user to his game, the invite id is enough to join it
+type WSMessageGameInvite struct {
+	MessageType MessageType `json:"type"`
+	InviteID    string      `json:"inviteId"`
+	From        User        `json:"from"`
+	GameID      string      `json:"gameid"`
+	GameName    string      `json:"gameName"`
+	Players     int         `json:"players"`
+	MaxPlayers  int         `json:"maxPlayers"`
+	ExpiresAt   time.Time   `json:"expiresAt"`
+}
+
+// Type returns the message type
+func (m WSMessageGameInvite) Type() MessageType {
+	return m.MessageType
+}
+
+// WSMessageLobbyUpdate the owner changed the lobby, carries the resulting lobby settings.
+// UserID is the affected player for kicks and bans and the new owner for ownership changes
+type WSMessageLobbyUpdate struct {
+	MessageType MessageType `json:"type"`
+	GameID      string      `json:"gameid"`
+	Update      string      `json:"update"`
+	UserID      string      `json:"userId,omitempty"`
+	Name        string      `json:"name"`
+	Owner       string      `json:"owner"`
+	MaxPlayers  int         `json:"maxPlayers"`
+	Visibility  string      `json:"visibility"`
+}
+
+// Type returns the message type
+func (m WSMessageLobbyUpdate) Type() MessageType {
+	return m.MessageType
 }
 
 // NewWSMessageUserJoinedGame create a return a new user joined game message
@@ -24,9 +281,152 @@ func NewWSMessageUserJoinedGame(game *models.KabooGame, user *models.User) WSMes
 	return WSMessageUserJoinedGame{
 		MessageType: WSMessageTypeUserJoinsGame,
 		GameID:      game.ID.Hex(),
+		User:        NewUser(user),
+	}
+}
+
+// NewWSMessageGameEvents create and return a new game events message, events must already be masked
+func NewWSMessageGameEvents(game *models.KabooGame, events []engine.Event, view engine.PlayerView) WSMessageGameEvents {
+	return WSMessageGameEvents{
+		MessageType: WSMessageTypeGameEvents,
+		GameID:      game.ID.Hex(),
+		Events:      events,
+		View:        view,
+	}
+}
+
+// NewWSMessageGameDelta create and return a new game delta message, events must already be masked
+func NewWSMessageGameDelta(game *models.KabooGame, version int, events []engine.Event, changes engine.ViewDelta) WSMessageGameDelta {
+	return WSMessageGameDelta{
+		MessageType: WSMessageTypeGameDelta,
+		GameID:      game.ID.Hex(),
+		Version:     version,
+		Events:      events,
+		Changes:     changes,
+	}
+}
+
+// NewWSMessageGameSnapshot create and return a new game snapshot message
+func NewWSMessageGameSnapshot(game *models.KabooGame, version int, state engine.Snapshot) WSMessageGameSnapshot {
+	return WSMessageGameSnapshot{
+		MessageType: WSMessageTypeGameSnapshot,
+		GameID:      game.ID.Hex(),
+		Version:     version,
+		State:       state,
+	}
+}
+
+// NewWSMessageGameSeedRevealed create and return a new seed revealed message for an ended game
+func NewWSMessageGameSeedRevealed(game *models.KabooGame) WSMessageGameSeedRevealed {
+	return WSMessageGameSeedRevealed{
+		MessageType:    WSMessageTypeGameSeedRevealed,
+		GameID:         game.ID.Hex(),
+		Seed:           game.RevealedSeed(),
+		SeedCommitment: game.SeedCommitment,
+		Entropy:        game.EntropyValues(),
+		ShuffleSeed:    game.ShuffleSeed(),
+	}
+}
+
+// NewChatMessage converts a stored chat message
+func NewChatMessage(message *models.ChatMessage) ChatMessage {
+	return ChatMessage{
+		ID: message.ID.Hex(),
 		User: User{
-			ID:   user.ID.Hex(),
-			Name: user.Username,
+			ID:   message.UserID.Hex(),
+			Name: message.Username,
 		},
+		Text:   message.Text,
+		SentAt: message.SentAt,
+	}
+}
+
+// NewWSMessageChat create and return a new chat message
+func NewWSMessageChat(game *models.KabooGame, message *models.ChatMessage) WSMessageChat {
+	return WSMessageChat{
+		MessageType: WSMessageTypeChat,
+		GameID:      game.ID.Hex(),
+		Message:     NewChatMessage(message),
+	}
+}
+
+// NewWSMessageError create and return a new error reply to a command
+func NewWSMessageError(commandType CommandType, err *apierror.Error) WSMessageError {
+	return WSMessageError{
+		MessageType: WSMessageTypeError,
+		CommandType: commandType,
+		Code:        err.Code,
+		Message:     err.Message,
+		Details:     err.Details,
+	}
+}
+
+// NewWSMessageMatchFound create and return a new match found message
+func NewWSMessageMatchFound(gameID string, bots int) WSMessageMatchFound {
+	return WSMessageMatchFound{
+		MessageType: WSMessageTypeMatchFound,
+		GameID:      gameID,
+		Bots:        bots,
+	}
+}
+
+// NewWSMessageMatchFailed create and return a new match failed message
+func NewWSMessageMatchFailed(requeued bool) WSMessageMatchFailed {
+	return WSMessageMatchFailed{
+		MessageType: WSMessageTypeMatchFailed,
+		Requeued:    requeued,
+	}
+}
+
+// NewWSMessageFriendRequest create and return a new friend request message
+func NewWSMessageFriendRequest(from *models.User) WSMessageFriendRequest {
+	return WSMessageFriendRequest{
+		MessageType: WSMessageTypeFriendRequest,
+		From:        NewUser(from),
+	}
+}
+
+// NewWSMessageFriendAccepted create and return a new friend accepted message
+func NewWSMessageFriendAccepted(user *models.User) WSMessageFriendAccepted {
+	return WSMessageFriendAccepted{
+		MessageType: WSMessageTypeFriendAccepted,
+		User:        NewUser(user),
+	}
+}
+
+// NewWSMessageFriendPresence create and return a new friend presence message
+func NewWSMessageFriendPresence(userID string, online bool) WSMessageFriendPresence {
+	return WSMessageFriendPresence{
+		MessageType: WSMessageTypeFriendPresence,
+		UserID:      userID,
+		Online:      online,
+	}
+}
+
+// NewWSMessageGameInvite create and return a new game invite message
+func NewWSMessageGameInvite(inviteID string, from *models.User, game *models.KabooGame, expiresAt time.Time) WSMessageGameInvite {
+	return WSMessageGameInvite{
+		MessageType: WSMessageTypeGameInvite,
+		InviteID:    inviteID,
+		From:        NewUser(from),
+		GameID:      game.ID.Hex(),
+		GameName:    game.Name,
+		Players:     game.PlayerCount(),
+		MaxPlayers:  game.MaxPlayers,
+		ExpiresAt:   expiresAt,
+	}
+}
+
+// NewWSMessageLobbyUpdate create and return a new lobby update message
+func NewWSMessageLobbyUpdate(game *models.KabooGame, update string, userID string) WSMessageLobbyUpdate {
+	return WSMessageLobbyUpdate{
+		MessageType: WSMessageTypeLobbyUpdate,
+		GameID:      game.ID.Hex(),
+		Update:      update,
+		UserID:      userID,
+		Name:        game.Name,
+		Owner:       game.Owner.Hex(),
+		MaxPlayers:  game.MaxPlayers,
+		Visibility:  string(game.GameVisibility()),
 	}
 }
@@ -0,0 +1,243 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Protocol versions. A client speaking an older version keeps receiving messages the way
+// that version defined them and never receives message types introduced after it
+const (
+	// ProtocolV1 the original protocol, message and command types are integers
+	ProtocolV1 = 1
+	// ProtocolV2 message and command types are names
+	ProtocolV2 = 2
+	// ProtocolV3 game events come with versioned view deltas instead of the whole view
+	ProtocolV3 = 3
+
+	// MinProtocolVersion oldest protocol version still served
+	MinProtocolVersion = ProtocolV1
+	// ProtocolVersion latest protocol version
+	ProtocolVersion = ProtocolV3
+
+	// ProtocolQueryParam the /ws query parameter selecting the protocol version, e.g. ?protocol=2
+	ProtocolQueryParam = "protocol"
+	// SubprotocolPrefix the version may also be offered as a websocket subprotocol, e.g. kaboo.v2
+	SubprotocolPrefix = "kaboo.v"
+	// ProtocolVersionHeader the upgrade response header carrying the negotiated version
+	ProtocolVersionHeader = "Kaboo-Protocol-Version"
+)
+
+var (
+	// ErrUnsupportedProtocol the client asked for a protocol version the server doesn't speak
+	ErrUnsupportedProtocol = errors.New("Unsupported protocol version")
+)
+
+// messageTypeInfo how a message type is sent to older clients
+type messageTypeInfo struct {
+	// since the protocol version introducing the message type
+	since int
+	// legacy the integer type used by protocol version 1, only set for types introduced by it
+	legacy int
+	// until the last protocol version the message type is sent to, 0 when it isn't replaced
+	until int
+}
+
+// messageTypes every server message type. Legacy integers are part of protocol version 1 and
+// must never change, types added later only need the version introducing them. A replaced type
+// keeps being sent to the versions up to until, senders send both it and its replacement
+var messageTypes = map[MessageType]messageTypeInfo{
+	WSMessageTypeUserJoinsGame:    {since: ProtocolV1, legacy: 0},
+	WSMessageTypeGameEvents:       {since: ProtocolV1, legacy: 1, until: ProtocolV2},
+	WSMessageTypeGameSeedRevealed: {since: ProtocolV1, legacy: 2},
+	WSMessageTypeChat:             {since: ProtocolV1, legacy: 3},
+	WSMessageTypeError:            {since: ProtocolV1, legacy: 4},
+	WSMessageTypeMatchFound:       {since: ProtocolV1, legacy: 5},
+	WSMessageTypeFriendRequest:    {since: ProtocolV1, legacy: 6},
+	WSMessageTypeFriendAccepted:   {since: ProtocolV1, legacy: 7},
+	WSMessageTypeFriendPresence:   {since: ProtocolV1, legacy: 8},
+	WSMessageTypeGameInvite:       {since: ProtocolV1, legacy: 9},
+	WSMessageTypeLobbyUpdate:      {since: ProtocolV1, legacy: 10},
+	WSMessageTypeGameDelta:        {since: ProtocolV3},
+	WSMessageTypeGameSnapshot:     {since: ProtocolV3},
+	WSMessageTypeMatchFailed:      {since: ProtocolV3},
+}
+
+// legacyCommandTypes the integer command types of protocol version 1
+var legacyCommandTypes = map[CommandType]int{
+	WSCommandTypeChat: 0,
+}
+
+// negotiated the protocol version and encoding agreed on at upgrade
+type negotiated struct {
+	version  int
+	encoding Encoding
+	// subprotocol the accepted subprotocol to echo, empty when negotiated with query parameters
+	subprotocol string
+}
+
+// negotiateProtocol returns the protocol version and encoding requested by the client. Query parameters
+// take precedence over subprotocols (kaboo.v<version>, optionally suffixed by .<encoding>), of which
+// the newest supported version is picked. Clients asking for neither speak protocol version 1 in JSON
+func negotiateProtocol(r *http.Request) (negotiated, error) {
+	query := r.URL.Query()
+	if query.Get(ProtocolQueryParam) != "" || query.Get(EncodingQueryParam) != "" {
+		version, encoding := ProtocolV1, EncodingJSON
+		if requested := query.Get(ProtocolQueryParam); requested != "" {
+			var err error
+			if version, err = strconv.Atoi(requested); err != nil || version < MinProtocolVersion || version > ProtocolVersion {
+				return negotiated{}, fmt.Errorf("%w %q, supported versions are %d-%d", ErrUnsupportedProtocol, requested, MinProtocolVersion, ProtocolVersion)
+			}
+		}
+		if requested := query.Get(EncodingQueryParam); requested != "" {
+			encoding = Encoding(requested)
+		}
+		if !encoding.supported(version) {
+			return negotiated{}, fmt.Errorf("%w, encoding %q isn't supported by version %d", ErrUnsupportedProtocol, encoding, version)
+		}
+		return negotiated{version: version, encoding: encoding}, nil
+	}
+	offered := websocket.Subprotocols(r)
+	if len(offered) == 0 {
+		return negotiated{version: ProtocolV1, encoding: EncodingJSON}, nil
+	}
+	var best negotiated
+	for _, protocol := range offered {
+		if !strings.HasPrefix(protocol, SubprotocolPrefix) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(protocol, SubprotocolPrefix), ".", 2)
+		version, err := strconv.Atoi(parts[0])
+		encoding := EncodingJSON
+		if len(parts) == 2 {
+			encoding = Encoding(parts[1])
+		}
+		if err != nil || version < MinProtocolVersion || version > ProtocolVersion || !encoding.supported(version) {
+			continue
+		}
+		if version > best.version {
+			best = negotiated{version: version, encoding: encoding, subprotocol: protocol}
+		}
+	}
+	if best.version == 0 {
+		return negotiated{}, fmt.Errorf("%w, offered %v", ErrUnsupportedProtocol, offered)
+	}
+	return best, nil
+}
+
+// encodedMessage a marshalled server message, converted for older protocol versions and other encodings on demand
+type encodedMessage struct {
+	messageType MessageType
+	message     interface{}
+	data        []byte
+	legacy      []byte
+	msgpack     []byte
+}
+
+// encodeMessage marshals a server message in the latest protocol version, message must implement Message
+func encodeMessage(message interface{}) (*encodedMessage, error) {
+	typed, ok := message.(Message)
+	if !ok {
+		return nil, fmt.Errorf("%T isn't a server message", message)
+	}
+	messageType := typed.Type()
+	if _, ok := messageTypes[messageType]; !ok {
+		return nil, fmt.Errorf("unregistered message type %q", messageType)
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return &encodedMessage{messageType: messageType, message: message, data: data}, nil
+}
+
+// forClient returns the message as sent to clients speaking version with encoding, ok is false when
+// the message type was introduced after the version or replaced before it and must not be sent
+func (m *encodedMessage) forClient(version int, encoding Encoding) (data []byte, ok bool) {
+	info := messageTypes[m.messageType]
+	if info.since > version || (info.until != 0 && info.until < version) {
+		return nil, false
+	}
+	if encoding == EncodingMsgPack {
+		if m.msgpack == nil {
+			encoded, err := marshalMsgPack(m.message)
+			if err != nil {
+				return nil, false
+			}
+			m.msgpack = encoded
+		}
+		return m.msgpack, true
+	}
+	if version >= ProtocolV2 {
+		return m.data, true
+	}
+	if m.legacy == nil {
+		legacy, err := toLegacyMessage(m.data, info.legacy)
+		if err != nil {
+			return nil, false
+		}
+		m.legacy = legacy
+	}
+	return m.legacy, true
+}
+
+// toLegacyMessage replaces the type names of a message with their protocol version 1 integers
+func toLegacyMessage(data []byte, legacyType int) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["type"] = json.RawMessage(strconv.Itoa(legacyType))
+	if legacyType == messageTypes[WSMessageTypeError].legacy {
+		var command CommandType
+		json.Unmarshal(fields["command"], &command)
+		fields["command"] = json.RawMessage(strconv.Itoa(legacyCommandType(command)))
+	}
+	return json.Marshal(fields)
+}
+
+// legacyCommandType returns the protocol version 1 integer of a command type, unknown
+// integer commands sent by version 1 clients are echoed back as is
+func legacyCommandType(command CommandType) int {
+	if legacy, ok := legacyCommandTypes[command]; ok {
+		return legacy
+	}
+	if legacy, err := strconv.Atoi(string(command)); err == nil {
+		return legacy
+	}
+	return -1
+}
+
+// decodeCommand parses a command sent by a client speaking version, binary frames are MessagePack
+func decodeCommand(data []byte, version int, binary bool) (WSCommand, error) {
+	var command WSCommand
+	if binary {
+		err := unmarshalMsgPack(data, &command)
+		return command, err
+	}
+	if version >= ProtocolV2 {
+		err := json.Unmarshal(data, &command)
+		return command, err
+	}
+	var legacy struct {
+		WSCommand
+		CommandType int `json:"type"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return command, err
+	}
+	command = legacy.WSCommand
+	command.CommandType = CommandType(strconv.Itoa(legacy.CommandType))
+	for commandType, legacyType := range legacyCommandTypes {
+		if legacyType == legacy.CommandType {
+			command.CommandType = commandType
+		}
+	}
+	return command, nil
+}
@@ -0,0 +1,350 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+)
+
+// frozenLegacyTypes the message types of protocol version 1, released clients depend on them
+var frozenLegacyTypes = map[MessageType]int{
+	"user_joined_game":   0,
+	"game_events":        1,
+	"game_seed_revealed": 2,
+	"chat":               3,
+	"error":              4,
+	"match_found":        5,
+	"friend_request":     6,
+	"friend_accepted":    7,
+	"friend_presence":    8,
+	"game_invite":        9,
+	"lobby_update":       10,
+}
+
+// protocolMessages a message of every server message type
+var protocolMessages = []interface{}{
+	WSMessageUserJoinedGame{MessageType: WSMessageTypeUserJoinsGame},
+	WSMessageGameEvents{MessageType: WSMessageTypeGameEvents},
+	WSMessageGameSeedRevealed{MessageType: WSMessageTypeGameSeedRevealed},
+	WSMessageChat{MessageType: WSMessageTypeChat},
+	WSMessageError{MessageType: WSMessageTypeError},
+	WSMessageMatchFound{MessageType: WSMessageTypeMatchFound},
+	WSMessageFriendRequest{MessageType: WSMessageTypeFriendRequest},
+	WSMessageFriendAccepted{MessageType: WSMessageTypeFriendAccepted},
+	WSMessageFriendPresence{MessageType: WSMessageTypeFriendPresence},
+	WSMessageGameInvite{MessageType: WSMessageTypeGameInvite},
+	WSMessageLobbyUpdate{MessageType: WSMessageTypeLobbyUpdate},
+	WSMessageGameDelta{MessageType: WSMessageTypeGameDelta},
+	WSMessageGameSnapshot{MessageType: WSMessageTypeGameSnapshot},
+	WSMessageMatchFailed{MessageType: WSMessageTypeMatchFailed},
+}
+
+// protocolCommands every client command type
+var protocolCommands = []CommandType{WSCommandTypeChat, WSCommandTypeSync}
+
+type protocolSchema struct {
+	Definitions map[string]*jsonSchema `json:"definitions"`
+}
+
+type jsonSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Const      string                 `json:"const"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	Items      *jsonSchema            `json:"items"`
+	OneOf      []*jsonSchema          `json:"oneOf"`
+	Since      int                    `json:"x-since"`
+	Until      int                    `json:"x-until"`
+	LegacyType *int                   `json:"x-legacyType"`
+}
+
+func Test_NegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		query        string
+		subprotocols string
+		expected     negotiated
+		err          bool
+	}{
+		{"", "", negotiated{ProtocolV1, EncodingJSON, ""}, false},
+		{"?protocol=1", "", negotiated{ProtocolV1, EncodingJSON, ""}, false},
+		{"?protocol=2", "", negotiated{ProtocolV2, EncodingJSON, ""}, false},
+		{"?protocol=2", "kaboo.v1", negotiated{ProtocolV2, EncodingJSON, ""}, false},
+		{"?protocol=2&encoding=msgpack", "", negotiated{ProtocolV2, EncodingMsgPack, ""}, false},
+		{"", "kaboo.v1, kaboo.v2", negotiated{ProtocolV2, EncodingJSON, "kaboo.v2"}, false},
+		{"", "chat, kaboo.v1, kaboo.v99", negotiated{ProtocolV1, EncodingJSON, "kaboo.v1"}, false},
+		{"", "kaboo.v2.msgpack, kaboo.v2", negotiated{ProtocolV2, EncodingMsgPack, "kaboo.v2.msgpack"}, false},
+		{"", "kaboo.v1.msgpack, kaboo.v1", negotiated{ProtocolV1, EncodingJSON, "kaboo.v1"}, false},
+		{"?protocol=0", "", negotiated{}, true},
+		{"?protocol=99", "", negotiated{}, true},
+		{"?protocol=latest", "", negotiated{}, true},
+		{"?encoding=msgpack", "", negotiated{}, true},
+		{"?protocol=2&encoding=xml", "", negotiated{}, true},
+		{"", "kaboo.v99", negotiated{}, true},
+		{"", "kaboo.v2.xml", negotiated{}, true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/ws"+test.query, nil)
+		if test.subprotocols != "" {
+			r.Header.Set("Sec-WebSocket-Protocol", test.subprotocols)
+		}
+		result, err := negotiateProtocol(r)
+		if test.err {
+			if !errors.Is(err, ErrUnsupportedProtocol) {
+				t.Errorf("%q %q should be rejected, got %v", test.query, test.subprotocols, err)
+			}
+			continue
+		}
+		if err != nil || result != test.expected {
+			t.Errorf("%q %q should negotiate %+v, got %+v %v", test.query, test.subprotocols, test.expected, result, err)
+		}
+	}
+}
+
+func Test_LegacyMessageTypes(t *testing.T) {
+	for messageType, legacy := range frozenLegacyTypes {
+		info, ok := messageTypes[messageType]
+		if !ok || info.since != ProtocolV1 || info.legacy != legacy {
+			t.Errorf("Protocol version 1 message type %v changed", messageType)
+		}
+	}
+	for messageType, info := range messageTypes {
+		if info.since > ProtocolVersion || info.since < MinProtocolVersion {
+			t.Errorf("%v is introduced by unknown protocol version %v", messageType, info.since)
+		}
+		if _, frozen := frozenLegacyTypes[messageType]; !frozen && info.since == ProtocolV1 {
+			t.Errorf("%v was added to protocol version 1, new message types need a new version", messageType)
+		}
+	}
+	for _, message := range protocolMessages {
+		encoded, err := encodeMessage(message)
+		if err != nil {
+			t.Fatalf("Failed encoding %T, %v", message, err)
+		}
+		data, ok := encoded.forClient(ProtocolV1, EncodingJSON)
+		if _, frozen := frozenLegacyTypes[encoded.messageType]; !frozen {
+			if ok {
+				t.Errorf("%T was added after protocol version 1 and must not be sent to its clients", message)
+			}
+			continue
+		}
+		var legacy struct {
+			Type int `json:"type"`
+		}
+		if !ok || json.Unmarshal(data, &legacy) != nil || legacy.Type != frozenLegacyTypes[encoded.messageType] {
+			t.Errorf("%T should be sent to version 1 clients as type %v, got %s", message, frozenLegacyTypes[encoded.messageType], data)
+		}
+	}
+}
+
+func Test_ReplacedMessageTypes(t *testing.T) {
+	events, _ := encodeMessage(WSMessageGameEvents{MessageType: WSMessageTypeGameEvents})
+	delta, _ := encodeMessage(WSMessageGameDelta{MessageType: WSMessageTypeGameDelta})
+	for version := MinProtocolVersion; version <= ProtocolVersion; version++ {
+		_, sendsEvents := events.forClient(version, EncodingJSON)
+		_, sendsDelta := delta.forClient(version, EncodingJSON)
+		if sendsEvents == sendsDelta {
+			t.Errorf("Protocol version %v clients should receive either game events or deltas", version)
+		}
+	}
+}
+
+func Test_LegacyErrorCommand(t *testing.T) {
+	tests := []struct {
+		command CommandType
+		legacy  int
+	}{
+		{WSCommandTypeChat, 0},
+		{"", -1},
+		{"7", 7},
+	}
+	for _, test := range tests {
+		encoded, _ := encodeMessage(NewWSMessageError(test.command, apierror.Internal()))
+		data, _ := encoded.forClient(ProtocolV1, EncodingJSON)
+		var legacy struct {
+			Command int `json:"command"`
+		}
+		if err := json.Unmarshal(data, &legacy); err != nil || legacy.Command != test.legacy {
+			t.Errorf("Error for command %q should be sent to version 1 clients with command %v, got %s", test.command, test.legacy, data)
+		}
+	}
+}
+
+func Test_DecodeCommand(t *testing.T) {
+	tests := []struct {
+		data    string
+		version int
+		command WSCommand
+	}{
+		{`{"type":0,"gameid":"g","text":"hi"}`, ProtocolV1, WSCommand{WSCommandTypeChat, "g", "hi"}},
+		{`{"type":7}`, ProtocolV1, WSCommand{CommandType: "7"}},
+		{`{"type":"chat","gameid":"g","text":"hi"}`, ProtocolV2, WSCommand{WSCommandTypeChat, "g", "hi"}},
+	}
+	for _, test := range tests {
+		command, err := decodeCommand([]byte(test.data), test.version, false)
+		if err != nil || command != test.command {
+			t.Errorf("%v decoded as %+v %v, expected %+v", test.data, command, err, test.command)
+		}
+	}
+	if _, err := decodeCommand([]byte(`{"type":"chat"}`), ProtocolV1, false); err == nil {
+		t.Errorf("Version 1 commands must have integer types")
+	}
+}
+
+func Test_NewMessageTypesArentSentToOldClients(t *testing.T) {
+	const future MessageType = "future"
+	messageTypes[future] = messageTypeInfo{since: ProtocolVersion + 1}
+	defer delete(messageTypes, future)
+
+	encoded, err := encodeMessage(WSMessageMatchFound{MessageType: future})
+	if err != nil {
+		t.Fatalf("Failed encoding, %v", err)
+	}
+	for version := MinProtocolVersion; version <= ProtocolVersion; version++ {
+		if _, ok := encoded.forClient(version, EncodingJSON); ok {
+			t.Errorf("Protocol version %v clients received a newer message type", version)
+		}
+	}
+	if _, ok := encoded.forClient(ProtocolVersion+1, EncodingJSON); !ok {
+		t.Errorf("Message should be sent to clients supporting it")
+	}
+	if _, err := encodeMessage(WSMessageMatchFound{MessageType: "unregistered"}); err == nil {
+		t.Errorf("Unregistered message types shouldn't be sent")
+	}
+}
+
+func Test_ProtocolSchemaMatchesMessages(t *testing.T) {
+	var schema protocolSchema
+	if err := json.Unmarshal([]byte(ProtocolSchema), &schema); err != nil {
+		t.Fatalf("Schema isn't valid JSON, %v", err)
+	}
+	byType := map[MessageType]*jsonSchema{}
+	for _, option := range schema.Definitions["ServerMessage"].OneOf {
+		definition := schema.resolve(option)
+		byType[MessageType(definition.Properties["type"].Const)] = definition
+	}
+	if len(byType) != len(messageTypes) || len(protocolMessages) != len(messageTypes) {
+		t.Errorf("Schema describes %v message types and %v are tested, %v are registered", len(byType), len(protocolMessages), len(messageTypes))
+	}
+	for _, message := range protocolMessages {
+		messageType := reflect.ValueOf(message).FieldByName("MessageType").Interface().(MessageType)
+		definition := byType[messageType]
+		if definition == nil {
+			t.Errorf("Message type %v isn't described", messageType)
+			continue
+		}
+		info := messageTypes[messageType]
+		if definition.Since != info.since || definition.Until != info.until || (info.since == ProtocolV1 && (definition.LegacyType == nil || *definition.LegacyType != info.legacy)) {
+			t.Errorf("%v: schema versioning doesn't match the registered message type", messageType)
+		}
+		schema.check(t, string(messageType), definition, reflect.TypeOf(message))
+	}
+	commands := map[CommandType]*jsonSchema{}
+	for _, option := range schema.Definitions["ClientMessage"].OneOf {
+		definition := schema.resolve(option)
+		commands[CommandType(definition.Properties["type"].Const)] = definition
+	}
+	if len(commands) != len(protocolCommands) {
+		t.Errorf("Schema describes %v commands, %v are supported", len(commands), len(protocolCommands))
+	}
+	// Commands share a struct, each one only documents the fields it uses
+	fields := jsonFields(reflect.TypeOf(WSCommand{}))
+	for _, commandType := range protocolCommands {
+		definition := commands[commandType]
+		if definition == nil {
+			t.Errorf("Command %v isn't described", commandType)
+			continue
+		}
+		for name, property := range definition.Properties {
+			if field, ok := fields[name]; !ok {
+				t.Errorf("%v command: %v isn't a command field", commandType, name)
+			} else if name != "type" {
+				schema.check(t, string(commandType)+"."+name, property, field.Type)
+			}
+		}
+	}
+}
+
+func (s *protocolSchema) resolve(schema *jsonSchema) *jsonSchema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.Definitions[strings.TrimPrefix(schema.Ref, "#/definitions/")]
+	}
+	return schema
+}
+
+// check compares a schema with the JSON encoding of goType, following references and nested types
+func (s *protocolSchema) check(t *testing.T, at string, schema *jsonSchema, goType reflect.Type) {
+	schema = s.resolve(schema)
+	if schema == nil {
+		t.Errorf("%v: missing schema", at)
+		return
+	}
+	for goType.Kind() == reflect.Ptr {
+		goType = goType.Elem()
+	}
+	if goType == reflect.TypeOf(time.Time{}) {
+		return
+	}
+	switch goType.Kind() {
+	case reflect.Struct:
+		var documented, actual, required, mandatory []string
+		for name := range schema.Properties {
+			documented = append(documented, name)
+		}
+		for name, field := range jsonFields(goType) {
+			actual = append(actual, name)
+			if !field.omitEmpty {
+				mandatory = append(mandatory, name)
+			}
+			s.check(t, at+"."+name, schema.Properties[name], field.Type)
+		}
+		required = append(required, schema.Required...)
+		for _, names := range [][]string{documented, actual, required, mandatory} {
+			sort.Strings(names)
+		}
+		if !reflect.DeepEqual(documented, actual) {
+			t.Errorf("%v: documented properties %v don't match %v fields %v", at, documented, goType, actual)
+		}
+		if !reflect.DeepEqual(required, mandatory) {
+			t.Errorf("%v: required properties %v don't match the fields always sent %v", at, required, mandatory)
+		}
+	case reflect.Slice:
+		if schema.Type != "array" {
+			t.Errorf("%v: %v is documented as %v", at, goType, schema.Type)
+			return
+		}
+		s.check(t, at+"[]", schema.Items, goType.Elem())
+	}
+}
+
+type jsonField struct {
+	reflect.Type
+	omitEmpty bool
+}
+
+// jsonFields returns the JSON encoded fields of a struct, promoting the fields of embedded structs
+func jsonFields(goType reflect.Type) map[string]jsonField {
+	fields := map[string]jsonField{}
+	for i := 0; i < goType.NumField(); i++ {
+		field := goType.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if field.Anonymous && tag[0] == "" {
+			for name, embedded := range jsonFields(field.Type) {
+				fields[name] = embedded
+			}
+			continue
+		}
+		if tag[0] == "-" || field.PkgPath != "" {
+			continue
+		}
+		fields[tag[0]] = jsonField{field.Type, len(tag) > 1 && tag[1] == "omitempty"}
+	}
+	return fields
+}
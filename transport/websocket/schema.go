@@ -0,0 +1,1000 @@
+package websocket
+
+// ProtocolSchema JSON Schema of every server message and client command of the latest protocol version
+const ProtocolSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Kaboo websocket protocol",
+  "description": "Messages exchanged over /api/v1/ws. Protocol version 2 names message and command types, version 1 clients receive the x-legacyType integers instead. Clients only receive message types whose x-since is at most their version and whose x-until, when set, is at least their version. Version 3 replaces game_events with game_delta. Clients speaking version 2 or later may use MessagePack instead of JSON, with the same field names",
+  "x-protocolVersion": 3,
+  "definitions": {
+    "UserJoinedGame": {
+      "type": "object",
+      "description": "A user joined a game the receiver is in",
+      "properties": {
+        "type": {
+          "const": "user_joined_game"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "user": {
+          "$ref": "#/definitions/User"
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "user"
+      ],
+      "x-since": 1,
+      "x-legacyType": 0
+    },
+    "GameEvents": {
+      "type": "object",
+      "description": "Game events as seen by the receiving player, along with the resulting view",
+      "properties": {
+        "type": {
+          "const": "game_events"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "events": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/Event"
+          }
+        },
+        "view": {
+          "$ref": "#/definitions/PlayerView"
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "events",
+        "view"
+      ],
+      "x-since": 1,
+      "x-legacyType": 1,
+      "x-until": 2
+    },
+    "GameSeedRevealed": {
+      "type": "object",
+      "description": "The seed of an ended game, verifiable against its commitment",
+      "properties": {
+        "type": {
+          "const": "game_seed_revealed"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "seed": {
+          "type": "string"
+        },
+        "seedCommitment": {
+          "type": "string"
+        },
+        "entropy": {
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        "shuffleSeed": {
+          "type": "string"
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "seed",
+        "seedCommitment",
+        "entropy",
+        "shuffleSeed"
+      ],
+      "x-since": 1,
+      "x-legacyType": 2
+    },
+    "Chat": {
+      "type": "object",
+      "description": "A chat message sent in a game",
+      "properties": {
+        "type": {
+          "const": "chat"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "message": {
+          "$ref": "#/definitions/ChatMessage"
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "message"
+      ],
+      "x-since": 1,
+      "x-legacyType": 3
+    },
+    "Error": {
+      "type": "object",
+      "description": "A command sent by the client failed, codes are the ones used by the REST API",
+      "properties": {
+        "type": {
+          "const": "error"
+        },
+        "command": {
+          "type": "string",
+          "description": "Type of the failed command, omitted when it couldn't be parsed. Version 1 sends the integer type, -1 when it couldn't be parsed"
+        },
+        "code": {
+          "type": "string"
+        },
+        "message": {
+          "type": "string"
+        },
+        "details": {
+          "description": "Code specific details, e.g. the invalid fields of a VALIDATION_FAILED error"
+        }
+      },
+      "required": [
+        "type",
+        "code",
+        "message"
+      ],
+      "x-since": 1,
+      "x-legacyType": 4
+    },
+    "MatchFound": {
+      "type": "object",
+      "description": "The matchmaker seated the user in a game",
+      "properties": {
+        "type": {
+          "const": "match_found"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "bots": {
+          "type": "integer",
+          "description": "Seats filled with bots"
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "bots"
+      ],
+      "x-since": 1,
+      "x-legacyType": 5
+    },
+    "FriendRequest": {
+      "type": "object",
+      "description": "The user received a friend request",
+      "properties": {
+        "type": {
+          "const": "friend_request"
+        },
+        "from": {
+          "$ref": "#/definitions/User"
+        }
+      },
+      "required": [
+        "type",
+        "from"
+      ],
+      "x-since": 1,
+      "x-legacyType": 6
+    },
+    "FriendAccepted": {
+      "type": "object",
+      "description": "A friend request the user sent was accepted",
+      "properties": {
+        "type": {
+          "const": "friend_accepted"
+        },
+        "user": {
+          "$ref": "#/definitions/User"
+        }
+      },
+      "required": [
+        "type",
+        "user"
+      ],
+      "x-since": 1,
+      "x-legacyType": 7
+    },
+    "FriendPresence": {
+      "type": "object",
+      "description": "A friend connected or disconnected",
+      "properties": {
+        "type": {
+          "const": "friend_presence"
+        },
+        "userId": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "online": {
+          "type": "boolean"
+        }
+      },
+      "required": [
+        "type",
+        "userId",
+        "online"
+      ],
+      "x-since": 1,
+      "x-legacyType": 8
+    },
+    "GameInvite": {
+      "type": "object",
+      "description": "A friend invited the user to a game, the invite id is enough to join it",
+      "properties": {
+        "type": {
+          "const": "game_invite"
+        },
+        "inviteId": {
+          "type": "string"
+        },
+        "from": {
+          "$ref": "#/definitions/User"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "gameName": {
+          "type": "string"
+        },
+        "players": {
+          "type": "integer"
+        },
+        "maxPlayers": {
+          "type": "integer"
+        },
+        "expiresAt": {
+          "type": "string",
+          "format": "date-time"
+        }
+      },
+      "required": [
+        "type",
+        "inviteId",
+        "from",
+        "gameid",
+        "gameName",
+        "players",
+        "maxPlayers",
+        "expiresAt"
+      ],
+      "x-since": 1,
+      "x-legacyType": 9
+    },
+    "LobbyUpdate": {
+      "type": "object",
+      "description": "The owner changed the lobby, carries the resulting settings",
+      "properties": {
+        "type": {
+          "const": "lobby_update"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "update": {
+          "type": "string",
+          "enum": [
+            "player_kicked",
+            "player_banned",
+            "owner_changed",
+            "settings_changed",
+            "player_left"
+          ],
+          "description": "player_left is also sent when a player leaves a running game, abandoning it"
+        },
+        "userId": {
+          "type": "string",
+          "description": "The affected player for kicks, bans and leaves, the new owner for ownership changes"
+        },
+        "name": {
+          "type": "string"
+        },
+        "owner": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "maxPlayers": {
+          "type": "integer"
+        },
+        "visibility": {
+          "type": "string",
+          "enum": [
+            "public",
+            "unlisted",
+            "friends"
+          ]
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "update",
+        "name",
+        "owner",
+        "maxPlayers",
+        "visibility"
+      ],
+      "x-since": 1,
+      "x-legacyType": 10
+    },
+    "GameDelta": {
+      "type": "object",
+      "description": "Game events as seen by the receiving player along with the changes to his view. Versions are consecutive, a client missing one must send a sync command",
+      "properties": {
+        "type": {
+          "const": "game_delta"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "version": {
+          "type": "integer"
+        },
+        "events": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/Event"
+          }
+        },
+        "changes": {
+          "$ref": "#/definitions/ViewDelta"
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "version",
+        "events",
+        "changes"
+      ],
+      "x-since": 3
+    },
+    "GameSnapshot": {
+      "type": "object",
+      "description": "The whole game as known by the receiving player, sent when the game starts, after a restart and in reply to sync commands. Deltas continue from its version",
+      "properties": {
+        "type": {
+          "const": "game_snapshot"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "version": {
+          "type": "integer"
+        },
+        "state": {
+          "$ref": "#/definitions/Snapshot"
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "version",
+        "state"
+      ],
+      "x-since": 3
+    },
+    "MatchFailed": {
+      "type": "object",
+      "description": "The game of a quick match couldn't be set up. Requeued users keep their place in the queue, the others must ask for a match again",
+      "properties": {
+        "type": {
+          "const": "match_failed"
+        },
+        "requeued": {
+          "type": "boolean"
+        }
+      },
+      "required": [
+        "type",
+        "requeued"
+      ],
+      "x-since": 3
+    },
+    "ChatCommand": {
+      "type": "object",
+      "description": "Sends a chat message to a game",
+      "properties": {
+        "type": {
+          "const": "chat"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "text": {
+          "type": "string",
+          "minLength": 1,
+          "maxLength": 384
+        }
+      },
+      "required": [
+        "type",
+        "gameid",
+        "text"
+      ],
+      "x-since": 1,
+      "x-legacyType": 0
+    },
+    "SyncCommand": {
+      "type": "object",
+      "description": "Requests a snapshot of a running game the user plays",
+      "properties": {
+        "type": {
+          "const": "sync"
+        },
+        "gameid": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        }
+      },
+      "required": [
+        "type",
+        "gameid"
+      ],
+      "x-since": 3
+    },
+    "ServerMessage": {
+      "description": "Any message sent by the server. Clients must ignore message types they don't know",
+      "oneOf": [
+        {
+          "$ref": "#/definitions/UserJoinedGame"
+        },
+        {
+          "$ref": "#/definitions/GameEvents"
+        },
+        {
+          "$ref": "#/definitions/GameSeedRevealed"
+        },
+        {
+          "$ref": "#/definitions/Chat"
+        },
+        {
+          "$ref": "#/definitions/Error"
+        },
+        {
+          "$ref": "#/definitions/MatchFound"
+        },
+        {
+          "$ref": "#/definitions/FriendRequest"
+        },
+        {
+          "$ref": "#/definitions/FriendAccepted"
+        },
+        {
+          "$ref": "#/definitions/FriendPresence"
+        },
+        {
+          "$ref": "#/definitions/GameInvite"
+        },
+        {
+          "$ref": "#/definitions/LobbyUpdate"
+        },
+        {
+          "$ref": "#/definitions/GameDelta"
+        },
+        {
+          "$ref": "#/definitions/GameSnapshot"
+        },
+        {
+          "$ref": "#/definitions/MatchFailed"
+        }
+      ]
+    },
+    "ClientMessage": {
+      "description": "Any command sent by a client",
+      "oneOf": [
+        {
+          "$ref": "#/definitions/ChatCommand"
+        },
+        {
+          "$ref": "#/definitions/SyncCommand"
+        }
+      ]
+    },
+    "User": {
+      "type": "object",
+      "description": "Public user details",
+      "properties": {
+        "id": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "name": {
+          "type": "string"
+        },
+        "displayName": {
+          "type": "string"
+        },
+        "avatar": {
+          "type": "string"
+        },
+        "preferences": {
+          "$ref": "#/definitions/Preferences"
+        }
+      },
+      "required": [
+        "id",
+        "name"
+      ]
+    },
+    "Preferences": {
+      "type": "object",
+      "description": "Client settings",
+      "properties": {
+        "cardBack": {
+          "type": "string"
+        },
+        "sound": {
+          "type": "boolean"
+        },
+        "language": {
+          "type": "string"
+        }
+      },
+      "required": [
+        "cardBack",
+        "sound",
+        "language"
+      ]
+    },
+    "ChatMessage": {
+      "type": "object",
+      "description": "A chat message",
+      "properties": {
+        "id": {
+          "type": "string",
+          "description": "Object id, 24 hex characters",
+          "pattern": "^[0-9a-f]{24}$"
+        },
+        "user": {
+          "$ref": "#/definitions/User"
+        },
+        "text": {
+          "type": "string"
+        },
+        "sentAt": {
+          "type": "string",
+          "format": "date-time"
+        }
+      },
+      "required": [
+        "id",
+        "user",
+        "text",
+        "sentAt"
+      ]
+    },
+    "Event": {
+      "type": "object",
+      "description": "A game event, cards are only included when revealed to the viewer",
+      "properties": {
+        "type": {
+          "type": "string",
+          "enum": [
+            "deal",
+            "turn",
+            "draw",
+            "discard",
+            "replace",
+            "peek",
+            "swap",
+            "kaboo",
+            "snap",
+            "reshuffle",
+            "round_end",
+            "game_end"
+          ]
+        },
+        "round": {
+          "type": "integer"
+        },
+        "seat": {
+          "type": "integer"
+        },
+        "slot": {
+          "type": "integer"
+        },
+        "targetSeat": {
+          "type": "integer"
+        },
+        "targetSlot": {
+          "type": "integer"
+        },
+        "pile": {
+          "type": "string",
+          "enum": [
+            "deck",
+            "discard"
+          ]
+        },
+        "card": {
+          "$ref": "#/definitions/Card"
+        },
+        "revealedTo": {
+          "type": "integer",
+          "description": "Seat the card is revealed to, -1 for everyone"
+        },
+        "discarded": {
+          "$ref": "#/definitions/Card"
+        },
+        "hands": {
+          "type": "array",
+          "items": {
+            "type": "array",
+            "items": {
+              "$ref": "#/definitions/Card"
+            }
+          }
+        },
+        "roundScores": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "scores": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "winners": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "penalty": {
+          "type": "integer"
+        }
+      },
+      "required": [
+        "type",
+        "round",
+        "seat",
+        "slot",
+        "targetSeat",
+        "targetSlot",
+        "revealedTo"
+      ]
+    },
+    "PlayerView": {
+      "type": "object",
+      "description": "The public game state plus the hidden information held by the receiving seat",
+      "properties": {
+        "seat": {
+          "type": "integer"
+        },
+        "players": {
+          "type": "integer"
+        },
+        "round": {
+          "type": "integer"
+        },
+        "turn": {
+          "type": "integer"
+        },
+        "phase": {
+          "type": "string",
+          "enum": [
+            "turn_start",
+            "drawn",
+            "power",
+            "look_decision",
+            "game_over"
+          ]
+        },
+        "currentSeat": {
+          "type": "integer"
+        },
+        "power": {
+          "type": "string",
+          "enum": [
+            "",
+            "peek",
+            "spy",
+            "blind_swap",
+            "look_and_swap"
+          ]
+        },
+        "handSizes": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "deckSize": {
+          "type": "integer"
+        },
+        "discardTop": {
+          "$ref": "#/definitions/Card"
+        },
+        "drawn": {
+          "$ref": "#/definitions/Card"
+        },
+        "drawnFrom": {
+          "type": "string",
+          "enum": [
+            "deck",
+            "discard"
+          ]
+        },
+        "kabooCaller": {
+          "type": "integer"
+        },
+        "scores": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "winners": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        }
+      },
+      "required": [
+        "seat",
+        "players",
+        "round",
+        "turn",
+        "phase",
+        "currentSeat",
+        "handSizes",
+        "deckSize",
+        "kabooCaller",
+        "scores"
+      ]
+    },
+    "ViewDelta": {
+      "type": "object",
+      "description": "The changed fields of the receiver's view, fields removed from the view are listed in cleared",
+      "properties": {
+        "round": {
+          "type": "integer"
+        },
+        "turn": {
+          "type": "integer"
+        },
+        "phase": {
+          "type": "string",
+          "enum": [
+            "turn_start",
+            "drawn",
+            "power",
+            "look_decision",
+            "game_over"
+          ]
+        },
+        "currentSeat": {
+          "type": "integer"
+        },
+        "power": {
+          "type": "string",
+          "enum": [
+            "",
+            "peek",
+            "spy",
+            "blind_swap",
+            "look_and_swap"
+          ]
+        },
+        "handSizes": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "deckSize": {
+          "type": "integer"
+        },
+        "discardTop": {
+          "$ref": "#/definitions/Card"
+        },
+        "drawn": {
+          "$ref": "#/definitions/Card"
+        },
+        "drawnFrom": {
+          "type": "string",
+          "enum": [
+            "deck",
+            "discard"
+          ]
+        },
+        "kabooCaller": {
+          "type": "integer"
+        },
+        "scores": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "winners": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "cleared": {
+          "type": "array",
+          "items": {
+            "type": "string",
+            "enum": [
+              "discardTop",
+              "drawn"
+            ]
+          }
+        }
+      }
+    },
+    "Snapshot": {
+      "type": "object",
+      "description": "The receiver's view along with every hand card he knows, unknown cards are null",
+      "properties": {
+        "seat": {
+          "type": "integer"
+        },
+        "players": {
+          "type": "integer"
+        },
+        "round": {
+          "type": "integer"
+        },
+        "turn": {
+          "type": "integer"
+        },
+        "phase": {
+          "type": "string",
+          "enum": [
+            "turn_start",
+            "drawn",
+            "power",
+            "look_decision",
+            "game_over"
+          ]
+        },
+        "currentSeat": {
+          "type": "integer"
+        },
+        "power": {
+          "type": "string",
+          "enum": [
+            "",
+            "peek",
+            "spy",
+            "blind_swap",
+            "look_and_swap"
+          ]
+        },
+        "handSizes": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "deckSize": {
+          "type": "integer"
+        },
+        "discardTop": {
+          "$ref": "#/definitions/Card"
+        },
+        "drawn": {
+          "$ref": "#/definitions/Card"
+        },
+        "drawnFrom": {
+          "type": "string",
+          "enum": [
+            "deck",
+            "discard"
+          ]
+        },
+        "kabooCaller": {
+          "type": "integer"
+        },
+        "scores": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "winners": {
+          "type": "array",
+          "items": {
+            "type": "integer"
+          }
+        },
+        "hands": {
+          "type": "array",
+          "items": {
+            "type": "array",
+            "items": {
+              "$ref": "#/definitions/Card"
+            }
+          }
+        }
+      },
+      "required": [
+        "seat",
+        "players",
+        "round",
+        "turn",
+        "phase",
+        "currentSeat",
+        "handSizes",
+        "deckSize",
+        "kabooCaller",
+        "scores",
+        "hands"
+      ]
+    },
+    "Card": {
+      "type": "object",
+      "description": "A card",
+      "properties": {
+        "rank": {
+          "type": "integer",
+          "minimum": 0,
+          "maximum": 13,
+          "description": "0 is a joker, 1 an ace and 11-13 the face cards"
+        },
+        "suit": {
+          "type": "integer",
+          "minimum": 0,
+          "maximum": 4,
+          "description": "0 for jokers"
+        }
+      },
+      "required": [
+        "rank",
+        "suit"
+      ]
+    }
+  },
+  "oneOf": [
+    {
+      "$ref": "#/definitions/ServerMessage"
+    },
+    {
+      "$ref": "#/definitions/ClientMessage"
+    }
+  ]
+}
+`
@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConnectionStats bytes exchanged over a connection, including framing and after compression
+type ConnectionStats struct {
+	BytesSent     uint64 `json:"bytesSent"`
+	BytesReceived uint64 `json:"bytesReceived"`
+}
+
+// countingConn counts the bytes read from and written to a connection
+type countingConn struct {
+	// Accessed atomically, kept first for 64 bit alignment
+	sent     uint64
+	received uint64
+	net.Conn
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddUint64(&c.received, uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddUint64(&c.sent, uint64(n))
+	return n, err
+}
+
+func (c *countingConn) stats() ConnectionStats {
+	return ConnectionStats{
+		BytesSent:     atomic.LoadUint64(&c.sent),
+		BytesReceived: atomic.LoadUint64(&c.received),
+	}
+}
+
+// countingResponseWriter hands the upgrader a counting connection when it hijacks the request.
+// Counting starts with the upgrade response, the HTTP request itself isn't included
+type countingResponseWriter struct {
+	http.ResponseWriter
+	conn *countingConn
+}
+
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = &countingConn{Conn: conn}
+	return w.conn, rw, nil
+}
+
+// ConnectionStats returns the bytes exchanged with the user's current connection, ok is false
+// when the user isn't connected
+func (h *Hub) ConnectionStats(userID primitive.ObjectID) (stats ConnectionStats, ok bool) {
+	h.usersMtx.RLock()
+	client := h.usersToClients[userID.Hex()]
+	h.usersMtx.RUnlock()
+	if client == nil || client.counter == nil {
+		return ConnectionStats{}, false
+	}
+	return client.counter.stats(), true
+}
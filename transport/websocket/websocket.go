@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+	"github.com/ngutman/kaboo-server-go/transport/origin"
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/time/rate"
 
 	"github.com/gorilla/websocket"
 )
@@ -33,6 +38,8 @@ var (
 type ClientMessage struct {
 	client *client
 	data   []byte
+	// binary the message was sent in a binary frame
+	binary bool
 }
 
 type client struct {
@@ -40,30 +47,61 @@ type client struct {
 	conn   *websocket.Conn
 	send   chan []byte
 	userID string
+	// protocol the protocol version negotiated by the client
+	protocol int
+	// encoding of the messages sent to the client
+	encoding Encoding
+	// counter counts the bytes exchanged over conn
+	counter *countingConn
+	// limiter limits the commands read from the client
+	limiter *rate.Limiter
+	// sendMtx guards sending on send against closing it, closed is set once it's closed
+	sendMtx sync.Mutex
+	closed  bool
 }
 
 // Hub registers, un-registers and manages websocket lifecycle
 type Hub struct {
+	config         Config
 	upgrader       websocket.Upgrader
 	clients        map[*client]bool
 	usersToClients map[string]*client
+	usersMtx       sync.RWMutex
 	incoming       chan ClientMessage
 	register       chan *client
 	unregister     chan *client
+	handlers       map[CommandType]CommandHandler
+	onPresence     PresenceHandler
+	translateError ErrorTranslator
+	checkOrigin    OriginChecker
 }
 
-// NewHub create a new hub instance
-func NewHub() *Hub {
+// PresenceHandler called whenever a user connects or disconnects
+type PresenceHandler func(userID primitive.ObjectID, online bool)
+
+// OriginChecker returns whether an upgrade request may be accepted given its Origin header
+type OriginChecker func(r *http.Request) bool
+
+// NewHub create a new hub instance, config must be valid
+func NewHub(config Config) *Hub {
+	// An empty allowlist accepts non browser clients and the server's own origin
+	sameOrigin, _ := origin.Parse(nil)
 	return &Hub{
+		config: config,
 		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
+			// Buffer sizes must be set for reads and writes to go through the counting connection
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			EnableCompression: config.Compression,
+			CheckOrigin:       sameOrigin.CheckRequest,
 		},
+		checkOrigin:    sameOrigin.CheckRequest,
 		clients:        make(map[*client]bool),
 		usersToClients: make(map[string]*client),
 		incoming:       make(chan ClientMessage),
 		register:       make(chan *client),
 		unregister:     make(chan *client),
+		handlers:       make(map[CommandType]CommandHandler),
 	}
 }
 
@@ -73,56 +111,164 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
+			h.usersMtx.Lock()
 			h.usersToClients[client.userID] = client
+			h.usersMtx.Unlock()
+			h.notifyPresence(client.userID, true)
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				log.Debugf("Client %v (%v) disconnected\n", client.userID, client.conn.RemoteAddr().String())
+				stats := client.counter.stats()
+				log.Infof("Client %v (%v) disconnected, sent %v bytes, received %v bytes\n", client.userID,
+					client.conn.RemoteAddr().String(), stats.BytesSent, stats.BytesReceived)
 				delete(h.clients, client)
-				delete(h.usersToClients, client.userID)
-				close(client.send)
+				h.usersMtx.Lock()
+				// The user may have reconnected meanwhile, only drop the mapping if it still points to this client
+				current := h.usersToClients[client.userID] == client
+				if current {
+					delete(h.usersToClients, client.userID)
+				}
+				h.usersMtx.Unlock()
+				client.close()
+				if current {
+					h.notifyPresence(client.userID, false)
+				}
 			}
 		case clientMessage := <-h.incoming:
 			log.Tracef("Incoming message from %v - %v", clientMessage.client.userID, clientMessage.data)
-			// TODO: Handle incoming commands
+			h.dispatch(clientMessage)
 		}
 	}
 }
 
-// BroadcastMessageToUsers send a message over WS to the given list of users
+// SetErrorTranslator sets how command handler errors are reported to clients, must be called before Run
+func (h *Hub) SetErrorTranslator(translator ErrorTranslator) {
+	h.translateError = translator
+}
+
+// SetPresenceHandler sets the handler notified when users connect or disconnect, must be called before Run
+func (h *Hub) SetPresenceHandler(handler PresenceHandler) {
+	h.onPresence = handler
+}
+
+// SetOriginChecker sets which origins may open connections, only the server's own origin and clients
+// sending no origin are accepted until it's set. Must be called before Run
+func (h *Hub) SetOriginChecker(checker OriginChecker) {
+	h.checkOrigin = checker
+	h.upgrader.CheckOrigin = checker
+}
+
+// IsOnline returns whether the user is currently connected
+func (h *Hub) IsOnline(userID primitive.ObjectID) bool {
+	h.usersMtx.RLock()
+	defer h.usersMtx.RUnlock()
+	return h.usersToClients[userID.Hex()] != nil
+}
+
+func (h *Hub) notifyPresence(userID string, online bool) {
+	if h.onPresence == nil {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return
+	}
+	go h.onPresence(id, online)
+}
+
+// BroadcastMessageToUsers send a message over WS to the given list of users without blocking, clients
+// whose send buffer is full are disconnected rather than left with a gap in their messages
 func (h *Hub) BroadcastMessageToUsers(users []primitive.ObjectID, message interface{}) {
-	rawJSON, err := json.Marshal(message)
+	encoded, err := encodeMessage(message)
 	if err != nil {
-		log.Errorf("Failed marshalling json, %v", message)
+		log.Errorf("Failed encoding message %v, %v", message, err)
 		return
 	}
 
+	h.usersMtx.RLock()
+	clients := make([]*client, 0, len(users))
 	for _, userID := range users {
-		if h.usersToClients[userID.Hex()] != nil {
-			h.usersToClients[userID.Hex()].send <- rawJSON
-			log.Debugf("Sent message to %v", userID)
+		if client := h.usersToClients[userID.Hex()]; client != nil {
+			clients = append(clients, client)
+		}
+	}
+	h.usersMtx.RUnlock()
+	for _, client := range clients {
+		data, ok := encoded.forClient(client.protocol, client.encoding)
+		if !ok {
+			continue
 		}
+		if !client.trySend(data) {
+			client.disconnectSlow()
+			continue
+		}
+		log.Debugf("Sent message to %v", client.userID)
+	}
+}
+
+// disconnectSlow disconnects a client that doesn't keep up with its messages, readPump then unregisters it
+func (c *client) disconnectSlow() {
+	c.sendMtx.Lock()
+	closed := c.closed
+	c.sendMtx.Unlock()
+	if !closed {
+		log.Infof("Disconnecting client %v, send buffer is full\n", c.userID)
+		c.conn.Close()
 	}
 }
 
 // HandleWSUpgradeRequest attempt to upgrade the given connection to websocket and register the user
 func (h *Hub) HandleWSUpgradeRequest(w http.ResponseWriter, r *http.Request, user *models.User) {
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if !h.checkOrigin(r) {
+		log.Debugf("Rejected connection of %v from origin %v\n", user, r.Header.Get("Origin"))
+		writeUpgradeError(w, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Origin not allowed"))
+		return
+	}
+	protocol, err := negotiateProtocol(r)
+	if err != nil {
+		writeUpgradeError(w, apierror.New(http.StatusBadRequest, apierror.CodeUnsupportedProtocol, err.Error()))
+		return
+	}
+	responseHeader := http.Header{
+		ProtocolVersionHeader: {strconv.Itoa(protocol.version)},
+		EncodingHeader:        {string(protocol.encoding)},
+	}
+	if protocol.subprotocol != "" {
+		responseHeader.Set("Sec-WebSocket-Protocol", protocol.subprotocol)
+	}
+	counting := &countingResponseWriter{ResponseWriter: w}
+	conn, err := h.upgrader.Upgrade(counting, r, responseHeader)
 	if err != nil {
 		log.Errorf("Error upgrading client connection, %v\n", err)
+		return
+	}
+	if h.config.Compression {
+		conn.SetCompressionLevel(h.config.CompressionLevel)
 	}
 	client := &client{
-		hub:    h,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		userID: user.ID.Hex(),
+		hub:      h,
+		conn:     conn,
+		send:     make(chan []byte, 256),
+		userID:   user.ID.Hex(),
+		protocol: protocol.version,
+		encoding: protocol.encoding,
+		counter:  counting.conn,
+		limiter:  rate.NewLimiter(h.config.CommandRate, h.config.CommandBurst),
 	}
-	log.Debugf("Client %v (%v) connected\n", user, r.RemoteAddr)
+	log.Debugf("Client %v (%v) connected with protocol version %v (%v)\n", user, r.RemoteAddr, protocol.version, protocol.encoding)
 	h.register <- client
 
 	go client.readPump()
 	go client.writePump()
 }
 
+// writeUpgradeError rejects an upgrade request with the JSON error body used by the REST API
+func writeUpgradeError(w http.ResponseWriter, err *apierror.Error) {
+	body, _ := json.Marshal(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	w.Write(body)
+}
+
 func (c *client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
@@ -131,17 +277,32 @@ func (c *client) readPump() {
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	limited := false
 	for {
-		_, message, err := c.conn.ReadMessage()
+		frameType, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("error: %v", err)
 			}
 			break
 		}
-		// TODO: Validate that we only trim the newline
-		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		c.hub.incoming <- ClientMessage{c, message}
+		if !c.limiter.Allow() {
+			// Only the first dropped command of a flood is answered, replies would flood the client back
+			if !limited {
+				log.Debugf("Client %v sends commands too quickly, dropping them\n", c.userID)
+				c.reply(NewWSMessageError("", apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited,
+					"Sending commands too quickly")))
+			}
+			limited = true
+			continue
+		}
+		limited = false
+		binary := frameType == websocket.BinaryMessage
+		if !binary {
+			// TODO: Validate that we only trim the newline
+			message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
+		}
+		c.hub.incoming <- ClientMessage{c, message, binary}
 	}
 }
 
@@ -160,19 +321,21 @@ func (c *client) writePump() {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
+			// Binary messages aren't delimited, each one is sent in its own frame
+			if c.encoding.binary() {
+				if err := c.conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+					return
+				}
+				continue
 			}
-			w.Write(message)
 
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-c.send)
+			batch, open := c.nextBatch(message)
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.writeBatch(batch); err != nil {
+				return
 			}
-
-			if err := w.Close(); err != nil {
+			if !open {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 		case <-ticker.C:
@@ -183,3 +346,45 @@ func (c *client) writePump() {
 		}
 	}
 }
+
+// nextBatch returns the JSON messages written in a single frame along with first. Up to MaxBatchSize
+// queued messages are taken, waiting at most FlushInterval for more. open is false once send is closed
+func (c *client) nextBatch(first []byte) (batch [][]byte, open bool) {
+	batch = [][]byte{first}
+	var flush <-chan time.Time
+	if c.hub.config.FlushInterval > 0 {
+		timer := time.NewTimer(c.hub.config.FlushInterval)
+		defer timer.Stop()
+		flush = timer.C
+	}
+	for len(batch) < c.hub.config.MaxBatchSize {
+		if flush == nil && len(c.send) == 0 {
+			return batch, true
+		}
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, message)
+		case <-flush:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// writeBatch writes JSON messages newline separated in a single text frame
+func (c *client) writeBatch(batch [][]byte) error {
+	w, err := c.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	for i, message := range batch {
+		if i > 0 {
+			w.Write(newline)
+		}
+		w.Write(message)
+	}
+	return w.Close()
+}
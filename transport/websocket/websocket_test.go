@@ -0,0 +1,317 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ngutman/kaboo-server-go/models"
+	"github.com/ngutman/kaboo-server-go/transport/apierror"
+	"github.com/ngutman/kaboo-server-go/transport/origin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_ConfigValidation(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Default config should be valid, %v", err)
+	}
+	invalid := []func(c *Config){
+		func(c *Config) { c.CompressionLevel = 0 },
+		func(c *Config) { c.CompressionLevel = 10 },
+		func(c *Config) { c.MaxBatchSize = 0 },
+		func(c *Config) { c.FlushInterval = -time.Millisecond },
+		func(c *Config) { c.FlushInterval = time.Minute },
+		func(c *Config) { c.CommandRate = 0 },
+		func(c *Config) { c.CommandBurst = 0 },
+	}
+	for i, change := range invalid {
+		config := DefaultConfig()
+		change(&config)
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Config %v should be rejected, got %v", i, err)
+		}
+	}
+	config := DefaultConfig()
+	config.Compression, config.CompressionLevel = false, 0
+	if err := config.Validate(); err != nil {
+		t.Errorf("Compression level is ignored without compression, got %v", err)
+	}
+}
+
+func Test_Batching(t *testing.T) {
+	tests := []struct {
+		maxBatchSize  int
+		flushInterval time.Duration
+		queued        int
+		late          int
+		expected      []int
+	}{
+		// Queued messages are batched up to the max batch size
+		{32, 0, 6, 0, []int{6}},
+		{2, 0, 5, 0, []int{2, 2, 1}},
+		{1, 0, 3, 0, []int{1, 1, 1}},
+		// Messages arriving later are only batched when waiting for them
+		{32, 0, 1, 1, []int{1, 1}},
+		{32, 200 * time.Millisecond, 1, 1, []int{2}},
+	}
+	for _, test := range tests {
+		config := DefaultConfig()
+		config.MaxBatchSize, config.FlushInterval = test.maxBatchSize, test.flushInterval
+		c := &client{hub: NewHub(config), send: make(chan []byte, 256)}
+		for i := 0; i < test.queued; i++ {
+			c.send <- []byte("{}")
+		}
+		go func(late int) {
+			time.Sleep(50 * time.Millisecond)
+			for i := 0; i < late; i++ {
+				c.send <- []byte("{}")
+			}
+		}(test.late)
+		var sizes []int
+		for written := 0; written < test.queued+test.late; {
+			batch, _ := c.nextBatch(<-c.send)
+			sizes = append(sizes, len(batch))
+			written += len(batch)
+		}
+		if !reflect.DeepEqual(sizes, test.expected) {
+			t.Errorf("Max batch %v, flush interval %v, %v queued and %v late messages should be batched as %v, got %v",
+				test.maxBatchSize, test.flushInterval, test.queued, test.late, test.expected, sizes)
+		}
+	}
+}
+
+func Test_CompressionAndConnectionStats(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+	user := &models.User{ID: primitive.NewObjectID(), Username: "tester"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.HandleWSUpgradeRequest(w, r, user)
+	}))
+	defer server.Close()
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, response, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?protocol=2", nil)
+	if err != nil {
+		t.Fatalf("Failed connecting, %v", err)
+	}
+	defer conn.Close()
+	if !strings.Contains(response.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Errorf("Compression should be negotiated, got %v", response.Header)
+	}
+	for deadline := time.Now().Add(time.Second); !hub.IsOnline(user.ID); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("User didn't register")
+		}
+	}
+
+	text := strings.Repeat("kaboo ", 500)
+	hub.BroadcastMessageToUsers([]primitive.ObjectID{user.ID}, WSMessageChat{
+		MessageType: WSMessageTypeChat,
+		Message:     ChatMessage{Text: text},
+	})
+	_, message, err := conn.ReadMessage()
+	if err != nil || !bytes.Contains(message, []byte(text)) {
+		t.Fatalf("Failed receiving message, %v", err)
+	}
+	stats, ok := hub.ConnectionStats(user.ID)
+	if !ok || stats.BytesSent == 0 || stats.BytesSent >= uint64(len(text)) {
+		t.Errorf("Compressed message should be counted, sent %v bytes for %v bytes of text", stats.BytesSent, len(text))
+	}
+
+	command := []byte(`{"type":"unknown"}`)
+	conn.WriteMessage(websocket.TextMessage, command)
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("Failed receiving reply, %v", err)
+	}
+	if stats, _ := hub.ConnectionStats(user.ID); stats.BytesReceived == 0 {
+		t.Errorf("Received command should be counted")
+	}
+	if _, ok := hub.ConnectionStats(primitive.NewObjectID()); ok {
+		t.Errorf("Disconnected users have no stats")
+	}
+}
+
+func Test_CommandRateLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.CommandRate, config.CommandBurst = 0.01, 2
+	hub := NewHub(config)
+	go hub.Run()
+	user := &models.User{ID: primitive.NewObjectID(), Username: "flooder"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.HandleWSUpgradeRequest(w, r, user)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?protocol=2", nil)
+	if err != nil {
+		t.Fatalf("Failed connecting, %v", err)
+	}
+	defer conn.Close()
+	for i := 0; i < 5; i++ {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"unknown"}`))
+	}
+	// Commands within the burst are handled, only the first dropped one is answered
+	expected := []apierror.Code{apierror.CodeUnknownCommand, apierror.CodeUnknownCommand, apierror.CodeRateLimited}
+	var codes []apierror.Code
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		for _, line := range bytes.Split(data, newline) {
+			var message WSMessageError
+			if err := json.Unmarshal(line, &message); err != nil {
+				t.Fatalf("Unexpected message %s", line)
+			}
+			codes = append(codes, message.Code)
+		}
+	}
+	if len(codes) != len(expected) {
+		t.Fatalf("Expected replies %v, got %v", expected, codes)
+	}
+	// Replies of handled commands and the dropped one may arrive in any order
+	limited := 0
+	for _, code := range codes {
+		if code == apierror.CodeRateLimited {
+			limited++
+		}
+	}
+	if limited != 1 {
+		t.Errorf("Expected replies %v, got %v", expected, codes)
+	}
+}
+
+func Test_ReplyAfterDisconnect(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	release, replied := make(chan struct{}), make(chan struct{})
+	hub.RegisterCommandHandler(WSCommandTypeChat, func(userID primitive.ObjectID, command WSCommand) error {
+		defer close(replied)
+		<-release
+		return apierror.New(http.StatusBadRequest, apierror.CodeMalformedRequest, "Too late")
+	})
+	go hub.Run()
+	user := &models.User{ID: primitive.NewObjectID(), Username: "leaver"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.HandleWSUpgradeRequest(w, r, user)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?protocol=2", nil)
+	if err != nil {
+		t.Fatalf("Failed connecting, %v", err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"chat","text":"hi"}`))
+	// Wait for the handler to run before disconnecting
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for hub.IsOnline(user.ID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Client wasn't unregistered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The handler replies once its client was unregistered and its send channel closed
+	close(release)
+	select {
+	case <-replied:
+	case <-time.After(time.Second):
+		t.Fatalf("Handler didn't return")
+	}
+}
+
+func Test_BroadcastDisconnectsSlowClients(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+	user := &models.User{ID: primitive.NewObjectID(), Username: "sleeper"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.HandleWSUpgradeRequest(w, r, user)
+	}))
+	defer server.Close()
+
+	// The client never reads, once the socket buffers fill up its messages queue in the send buffer
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?protocol=2", nil)
+	if err != nil {
+		t.Fatalf("Failed connecting, %v", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for !hub.IsOnline(user.ID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Client wasn't registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	message := NewWSMessageError("", apierror.New(http.StatusBadRequest, apierror.CodeMalformedRequest,
+		strings.Repeat("x", 64*1024)))
+	deadline = time.Now().Add(5 * time.Second)
+	for hub.IsOnline(user.ID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Slow client wasn't disconnected")
+		}
+		// Broadcasting must never block on the slow client
+		hub.BroadcastMessageToUsers([]primitive.ObjectID{user.ID}, message)
+	}
+	// Broadcasting to the unregistered client is dropped
+	hub.BroadcastMessageToUsers([]primitive.ObjectID{user.ID}, message)
+}
+
+func Test_OriginCheck(t *testing.T) {
+	allowlist, _ := origin.Parse([]string{"https://kaboo.example.com"})
+	tests := []struct {
+		allowlist *origin.Allowlist
+		origin    string
+		accepted  bool
+	}{
+		// The server's own origin and clients sending none are accepted by default
+		{nil, "", true},
+		{nil, "http://self", true},
+		{nil, "https://kaboo.example.com", false},
+		{allowlist, "https://kaboo.example.com", true},
+		{allowlist, "", true},
+		{allowlist, "https://evil.example.com", false},
+		{allowlist, "null", false},
+	}
+	for _, test := range tests {
+		hub := NewHub(DefaultConfig())
+		if test.allowlist != nil {
+			hub.SetOriginChecker(test.allowlist.CheckRequest)
+		}
+		go hub.Run()
+		user := &models.User{ID: primitive.NewObjectID(), Username: "tester"}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Host = "self"
+			hub.HandleWSUpgradeRequest(w, r, user)
+		}))
+		header := http.Header{}
+		if test.origin != "" {
+			header.Set("Origin", test.origin)
+		}
+		conn, response, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+		if test.accepted {
+			if err != nil {
+				t.Errorf("Connection from %q should be accepted, %v", test.origin, err)
+			} else {
+				conn.Close()
+			}
+		} else {
+			var body apierror.Error
+			if err == nil || response.StatusCode != http.StatusForbidden || json.NewDecoder(response.Body).Decode(&body) != nil ||
+				body.Code != apierror.CodeForbidden {
+				t.Errorf("Connection from %q should be rejected with %v, got %v", test.origin, apierror.CodeForbidden, err)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+		}
+		server.Close()
+	}
+}